@@ -0,0 +1,60 @@
+package assembler
+
+import (
+	"bufio"
+	"hack/compiler/source"
+	"io"
+)
+
+// Program is a program assembled by Build.
+type Program struct {
+	// Commands are the commands of the program, once optimized if the assembler optimizes
+	Commands     []Command
+	Instructions []Instruction
+	Symbols      *Symbols
+	// Warnings are the warnings of the commands as written, see Warnings
+	Warnings source.ErrorList
+	// Stats counts the instructions Optimize saved, if the assembler optimizes
+	Stats OptimizeStats
+}
+
+// Build reads the assembly of file from r and translates it, file may be empty as for
+// ExpandReader.
+func (a *Assembler) Build(file string, r io.Reader) (*Program, error) {
+	lines, err := ExpandReader(file, r)
+	if err != nil {
+		return nil, err
+	}
+	commands, err := ParseSource(lines)
+	if err != nil {
+		return nil, err
+	}
+	p := &Program{Commands: commands, Warnings: Warnings(commands)}
+	if a.optimize {
+		p.Commands, p.Stats = Optimize(commands)
+	}
+	p.Instructions, p.Symbols, err = a.TranslateWithSymbols(p.Commands)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Assemble reads the assembly of file from r and writes its machine code to w in format, see
+// Build. Warnings aren't reported.
+func (a *Assembler) Assemble(file string, r io.Reader, w io.Writer, format OutputFormat) error {
+	p, err := a.Build(file, r)
+	if err != nil {
+		return err
+	}
+	words, err := OutputWords(p.Instructions)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	err = WriteOutput(bw, format, words)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,44 @@
+package assembler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	code := ".define RESULT R0\n@2\nD=A\n@3\nD=D+A\n@RESULT\nM=D\n"
+	var out bytes.Buffer
+	err := New().Assemble("Add.asm", strings.NewReader(code), &out, HackFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n0000000000000000\n1110001100001000\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+
+	_, err = New().Build("Add.asm", strings.NewReader("@2\nD=A\n@LOOP\nD;JGT\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "Add.asm:3:2: undefined label LOOP\n") {
+		t.Fatalf("expected an undefined label error, got %v", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	code := "@i\nM=1\n@i\nD=M\n(LOOP)\n@LOOP\nD;JGT\n(UNUSED)\n"
+	a := New()
+	a.SetOptimize(true)
+	p, err := a.Build("Loop.asm", strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Warnings) != 1 || !strings.HasPrefix(p.Warnings[0].Error(), "Loop.asm:8:1: label UNUSED is declared but not used\n") {
+		t.Fatalf("expected the unused label warning, got %v", p.Warnings)
+	}
+	if p.Stats.Before != 6 || p.Stats.After != 5 || len(p.Instructions) != 5 {
+		t.Fatalf("expected the optimized program to have 5 instructions instead of 6, got %s and %d instructions", p.Stats, len(p.Instructions))
+	}
+	if p.Symbols.Labels["LOOP"] != 3 || p.Symbols.Variables["i"] != 16 {
+		t.Fatalf("expected LOOP at 3 and i at 16, got %+v", p.Symbols)
+	}
+}
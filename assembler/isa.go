@@ -0,0 +1,88 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The shifts of the extended instruction set, C-instructions starting with ShiftPrefix instead of
+// StandardPrefix. Left shifts are logical, right shifts are arithmetic.
+const (
+	ShiftLeftD  Computation = "0110000"
+	ShiftLeftA  Computation = "0100000"
+	ShiftLeftM  Computation = "1100000"
+	ShiftRightD Computation = "0010000"
+	ShiftRightA Computation = "0000000"
+	ShiftRightM Computation = "1000000"
+)
+
+var shifts = map[string]Computation{
+	"D<<": ShiftLeftD,
+	"A<<": ShiftLeftA,
+	"M<<": ShiftLeftM,
+	"D>>": ShiftRightD,
+	"A>>": ShiftRightA,
+	"M>>": ShiftRightM,
+}
+
+// ISA is an instruction set, the computations its C-instructions may use: the standard ones and
+// its own, encoded with its Prefix.
+type ISA struct {
+	Name         string
+	Prefix       Prefix
+	computations map[string]Computation
+}
+
+// NewISA returns the instruction set extending standard Hack with the computations extra, by
+// their mnemonic, which C-instructions encode with prefix.
+func NewISA(name string, prefix Prefix, extra map[string]Computation) *ISA {
+	isa := &ISA{Name: name, Prefix: prefix, computations: make(map[string]Computation, len(extra))}
+	for m, c := range extra {
+		isa.computations[m] = c
+	}
+	return isa
+}
+
+var (
+	// StandardISA is the instruction set of the Hack computer, the default one.
+	StandardISA = NewISA("standard", StandardPrefix, nil)
+	// ExtendedISA adds shifts to the standard instruction set, such as `D=D<<` and `M=A>>`.
+	ExtendedISA = NewISA("extended", ShiftPrefix, shifts)
+)
+
+// ISAs are the instruction sets LookupISA knows.
+var ISAs = []*ISA{StandardISA, ExtendedISA}
+
+// LookupISA returns the instruction set called name.
+func LookupISA(name string) (*ISA, error) {
+	names := make([]string, len(ISAs))
+	for i, isa := range ISAs {
+		if isa.Name == name {
+			return isa, nil
+		}
+		names[i] = isa.Name
+	}
+	return nil, fmt.Errorf("unknown instruction set %s, expect one of %s", name, strings.Join(names, ", "))
+}
+
+// Computation returns the prefix and the computation of the mnemonic comp, such as "D+M". The
+// prefix is empty for the standard computations.
+func (isa *ISA) Computation(comp string) (Prefix, Computation, bool) {
+	if c, ok := computations[comp]; ok {
+		return "", c, true
+	}
+	c, ok := isa.computations[comp]
+	return isa.Prefix, c, ok
+}
+
+// Mnemonic returns the assembly of c encoded with prefix, empty or StandardPrefix for the
+// standard computations.
+func (isa *ISA) Mnemonic(prefix Prefix, c Computation) (string, bool) {
+	switch prefix {
+	case "", StandardPrefix:
+		return c.Mnemonic()
+	case isa.Prefix:
+		return mnemonic(isa.computations, c)
+	}
+	return "", false
+}
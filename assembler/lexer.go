@@ -0,0 +1,87 @@
+package assembler
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+type tokenType uint8
+
+const (
+	symbolToken tokenType = iota
+	numberToken
+	charToken
+	// punctToken is one of @ ( ) = ; + - ! & | < >
+	punctToken
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	// column is the 1-based column of the token, in runes
+	column int
+}
+
+func (t token) is(punct string) bool {
+	return t.typ == punctToken && t.text == punct
+}
+
+func isPunct(c byte) bool {
+	switch c {
+	case '@', '(', ')', '=', ';', '+', '-', '!', '&', '|', '<', '>':
+		return true
+	}
+	return false
+}
+
+// lexError is an error at a column of the line being lexed.
+type lexError struct {
+	column  int
+	message string
+}
+
+func (e *lexError) Error() string {
+	return e.message
+}
+
+// lex splits a line of assembly into tokens, up to its comment.
+func lex(line string) ([]token, error) {
+	tokens := make([]token, 0)
+	column := 1
+	for i := 0; i < len(line); {
+		c := line[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return tokens, nil
+		case isPunct(c):
+			tokens = append(tokens, token{typ: punctToken, text: line[i : i+1], column: column})
+			i++
+		case c == '\'':
+			if i+2 >= len(line) || line[i+2] != '\'' || line[i+1] < ' ' || line[i+1] > '~' {
+				return tokens, &lexError{column: column, message: "invalid character, expect a printable character such as 'A'"}
+			}
+			tokens = append(tokens, token{typ: charToken, text: line[i : i+3], column: column})
+			i += 3
+		case c >= '0' && c <= '9':
+			i++
+			for i < len(line) && isSymbolPart(line[i]) {
+				i++
+			}
+			tokens = append(tokens, token{typ: numberToken, text: line[start:i], column: column})
+		case isSymbolStart(c):
+			i++
+			for i < len(line) && isSymbolPart(line[i]) {
+				i++
+			}
+			tokens = append(tokens, token{typ: symbolToken, text: line[start:i], column: column})
+		default:
+			r, _ := utf8.DecodeRuneInString(line[i:])
+			return tokens, &lexError{column: column, message: fmt.Sprintf("unexpected character %q", r)}
+		}
+		column += utf8.RuneCountInString(line[start:i])
+	}
+	return tokens, nil
+}
@@ -0,0 +1,78 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxAddress is the largest value of an A-instruction, which has 15 bits.
+const maxAddress = 1<<15 - 1
+
+// evaluate evaluates the operand of an A-instruction: numbers, characters and symbols added or
+// subtracted, such as `SCREEN+32`, `0x4000`, `0b1010` or `'A'`. lookup returns the address of a
+// symbol.
+func evaluate(operand string, lookup func(symbol string) int32) (int32, error) {
+	if operand == "" {
+		return 0, fmt.Errorf("missing operand")
+	}
+	total := int64(0)
+	sign := int64(1)
+	for i := 0; ; {
+		term, n, err := parseTerm(operand[i:], lookup)
+		if err != nil {
+			return 0, err
+		}
+		total += sign * term
+		i += n
+		if i == len(operand) {
+			break
+		}
+		switch operand[i] {
+		case '+':
+			sign = 1
+		case '-':
+			sign = -1
+		default:
+			return 0, fmt.Errorf("unexpected %q in %s", operand[i], operand)
+		}
+		i++
+		if i == len(operand) {
+			return 0, fmt.Errorf("missing term after %q in %s", operand[i-1], operand)
+		}
+	}
+	if total < 0 || total > maxAddress {
+		if operand == strconv.FormatInt(total, 10) {
+			return 0, fmt.Errorf("%s is out of the range 0 to %d", operand, maxAddress)
+		}
+		return 0, fmt.Errorf("%s is %d, out of the range 0 to %d", operand, total, maxAddress)
+	}
+	return int32(total), nil
+}
+
+// parseTerm parses the term at the start of s, it returns its value and length.
+func parseTerm(s string, lookup func(symbol string) int32) (int64, int, error) {
+	switch {
+	case s[0] == '\'':
+		if len(s) < 3 || s[2] != '\'' || s[1] < ' ' || s[1] > '~' {
+			return 0, 0, fmt.Errorf("invalid character %s, expect a printable character such as 'A'", s)
+		}
+		return int64(s[1]), 3, nil
+	case s[0] >= '0' && s[0] <= '9':
+		n := 1
+		for n < len(s) && isSymbolPart(s[n]) {
+			n++
+		}
+		value, err := parseNumber(s[:n])
+		if err != nil {
+			return 0, 0, err
+		}
+		return value, n, nil
+	case isSymbolStart(s[0]):
+		n := 1
+		for n < len(s) && isSymbolPart(s[n]) {
+			n++
+		}
+		return int64(lookup(s[:n])), n, nil
+	}
+	return 0, 0, fmt.Errorf("unexpected %q in operand", s[0])
+}
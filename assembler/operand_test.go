@@ -0,0 +1,76 @@
+package assembler
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	symbols := map[string]int32{"SCREEN": 16384, "LOOP": 10, "i": 16}
+	lookup := func(symbol string) int32 {
+		return symbols[symbol]
+	}
+	tests := []struct {
+		operand  string
+		expected int32
+	}{
+		{"0", 0},
+		{"32767", 32767},
+		{"0x4000", 16384},
+		{"0X7fff", 32767},
+		{"0b1010", 10},
+		{"0B0", 0},
+		{"'A'", 65},
+		{"' '", 32},
+		{"'~'", 126},
+		{"SCREEN+32", 16416},
+		{"LOOP-1", 9},
+		{"SCREEN+0x20+'A'-i", 16465},
+		{"i-i", 0},
+		{"1-2+1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operand, func(t *testing.T) {
+			actual, err := evaluate(tt.operand, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Fatalf("expected %d, got %d", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	lookup := func(symbol string) int32 {
+		return 16384
+	}
+	tests := []struct {
+		operand  string
+		expected string
+	}{
+		{"", "missing operand"},
+		{"32768", "32768 is out of the range 0 to 32767"},
+		{"0x8000", "0x8000 is 32768, out of the range 0 to 32767"},
+		{"SCREEN+SCREEN", "SCREEN+SCREEN is 32768, out of the range 0 to 32767"},
+		{"0-1", "0-1 is -1, out of the range 0 to 32767"},
+		{"'A'-SCREEN", "'A'-SCREEN is -16319, out of the range 0 to 32767"},
+		{"0xffffffff", "invalid number 0xffffffff"},
+		{"99999999999", "invalid number 99999999999"},
+		{"0b2", "invalid number 0b2"},
+		{"12abc", "invalid number 12abc"},
+		{"'A", "invalid character 'A, expect a printable character such as 'A'"},
+		{"1+", "missing term after '+' in 1+"},
+		{"1*2", "unexpected '*' in 1*2"},
+		{"+1", "unexpected '+' in operand"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operand, func(t *testing.T) {
+			_, err := evaluate(tt.operand, lookup)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
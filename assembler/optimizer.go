@@ -0,0 +1,264 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the assembly of the command, such as "@SP", "AM=M-1" or "(LOOP)".
+func (c Command) String() string {
+	switch c.CommandType {
+	case LabelDeclarationCommandType:
+		return "(" + c.Tokens[0] + ")"
+	case AInstructionCommandType:
+		return "@" + c.Tokens[0]
+	}
+	res := c.Tokens[1]
+	if c.Tokens[0] != "" {
+		res = c.Tokens[0] + "=" + res
+	}
+	if c.Tokens[2] != "" {
+		res = res + ";" + c.Tokens[2]
+	}
+	return res
+}
+
+// OptimizeStats counts the instructions of a program before and after Optimize.
+type OptimizeStats struct {
+	Before int
+	After  int
+}
+
+func (s OptimizeStats) String() string {
+	saved := s.Before - s.After
+	percent := 0.0
+	if s.Before > 0 {
+		percent = 100 * float64(saved) / float64(s.Before)
+	}
+	return fmt.Sprintf("%d instructions instead of %d, %d saved (%.1f%%)", s.After, s.Before, saved, percent)
+}
+
+// Optimize rewrites commands to an equivalent program with fewer instructions. The rewrites only
+// look at the instructions between two labels, since a label may be jumped to with any register
+// values, and keep every memory write:
+//
+//	@X ... @X          the second @X is removed when A isn't written in between
+//	@X; @Y             A-instructions and D writes that are overwritten before being read are removed
+//	M=D-M; M=-M        the negation is folded into the computation, M=M-D
+//	M=M+1; AM=M-1      increments and decrements of the same register cancel out, A=M
+//	M=M-1; A=M         are merged, AM=M-1
+//	M=D; D=M           the load of what was just stored is removed
+//	@1; D=A            constants 0 and 1 are computed without A, D=1, and D=0 is folded away
+//	0;JMP ...          the instructions after an unconditional jump, up to a label, are removed
+//
+// The commands keep the position they were parsed from, their memory locations are updated.
+func Optimize(commands []Command) ([]Command, OptimizeStats) {
+	stats := OptimizeStats{Before: countInstructions(commands)}
+	res := commands
+	for changed := true; changed; {
+		res, changed = optimizePass(res)
+	}
+	location := int32(0)
+	for i := range res {
+		res[i].MemoryLocation = location
+		if res[i].CommandType != LabelDeclarationCommandType {
+			location++
+		}
+	}
+	stats.After = countInstructions(res)
+	return res, stats
+}
+
+func countInstructions(commands []Command) int {
+	n := 0
+	for _, command := range commands {
+		if command.CommandType != LabelDeclarationCommandType {
+			n++
+		}
+	}
+	return n
+}
+
+// negations are the computations whose negation is a computation.
+var negations = map[string]string{
+	"0":   "0",
+	"1":   "-1",
+	"-1":  "1",
+	"D":   "-D",
+	"-D":  "D",
+	"A":   "-A",
+	"-A":  "A",
+	"M":   "-M",
+	"-M":  "M",
+	"D-A": "A-D",
+	"A-D": "D-A",
+	"D-M": "M-D",
+	"M-D": "D-M",
+}
+
+// withoutD are the computations reading D and what they compute when D is 0.
+var withoutD = map[string]string{
+	"D":   "0",
+	"-D":  "0",
+	"D+A": "A",
+	"D+M": "M",
+	"D|A": "A",
+	"D|M": "M",
+	"D&A": "0",
+	"D&M": "0",
+	"A-D": "A",
+	"M-D": "M",
+}
+
+func cInstruction(from Command, dest string, comp string, jump string) Command {
+	from.CommandType = CInstructionCommandType
+	from.Tokens = []string{dest, comp, jump}
+	return from
+}
+
+func isC(c Command, dest string, comp string) bool {
+	return c.CommandType == CInstructionCommandType && c.Tokens[0] == dest && c.Tokens[1] == comp && c.Tokens[2] == ""
+}
+
+// optimizePass applies each rewrite once, it reports whether one applied.
+func optimizePass(commands []Command) ([]Command, bool) {
+	res := make([]Command, 0, len(commands))
+	changed := false
+	// a is the operand A was loaded with, empty when unknown
+	a := ""
+	// dZero reports whether D is 0
+	dZero := false
+	unreachable := false
+	for i := 0; i < len(commands); i++ {
+		c := commands[i]
+		if c.CommandType == LabelDeclarationCommandType {
+			a, dZero, unreachable = "", false, false
+			res = append(res, c)
+			continue
+		}
+		if unreachable {
+			changed = true
+			continue
+		}
+		var next *Command
+		if i+1 < len(commands) && commands[i+1].CommandType == CInstructionCommandType {
+			next = &commands[i+1]
+		}
+
+		if c.CommandType == AInstructionCommandType {
+			operand := c.Tokens[0]
+			if operand == a || !isLive(commands[i+1:], "A") {
+				changed = true
+				continue
+			}
+			if (operand == "0" || operand == "1") && next != nil && isC(*next, "D", "A") {
+				// @1; D=A -> @1; D=1, the @1 being removed when A isn't read after
+				res = append(res, c, cInstruction(*next, "D", operand, ""))
+				a, dZero = operand, operand == "0"
+				i++
+				changed = true
+				continue
+			}
+			a = operand
+			res = append(res, c)
+			continue
+		}
+
+		dest, comp, jump := c.Tokens[0], c.Tokens[1], c.Tokens[2]
+		if dZero {
+			if value, ok := withoutD[comp]; ok {
+				comp = value
+				c = cInstruction(c, dest, comp, jump)
+				changed = true
+			}
+		}
+		if dest == "D" && jump == "" && !isLive(commands[i+1:], "D") {
+			changed = true
+			continue
+		}
+		if next != nil && jump == "" && next.Tokens[2] == "" {
+			if fused, ok := fuse(c, *next); ok {
+				i++
+				changed = true
+				if fused == nil {
+					continue
+				}
+				c = *fused
+				dest, comp = c.Tokens[0], c.Tokens[1]
+			}
+		}
+		if strings.Contains(dest, "A") {
+			a = ""
+		}
+		if strings.Contains(dest, "D") {
+			dZero = comp == "0"
+		}
+		res = append(res, c)
+		if jump == "JMP" {
+			unreachable = true
+		}
+	}
+	return res, changed
+}
+
+// fuse rewrites two C-instructions without jumps that follow each other as one, or none when
+// they cancel out.
+func fuse(first Command, second Command) (*Command, bool) {
+	dest, comp := first.Tokens[0], first.Tokens[1]
+	nextDest, nextComp := second.Tokens[0], second.Tokens[1]
+	switch {
+	case (dest == "M" || dest == "D") && nextDest == dest && nextComp == "-"+dest:
+		// M=D-M; M=-M -> M=M-D
+		if negated, ok := negations[comp]; ok {
+			fused := cInstruction(first, dest, negated, "")
+			return &fused, true
+		}
+	case dest == "M" && (comp == "M+1" && nextComp == "M-1" || comp == "M-1" && nextComp == "M+1"):
+		switch nextDest {
+		case "M":
+			// M=M+1; M=M-1
+			return nil, true
+		case "AM":
+			// M=M+1; AM=M-1 -> A=M
+			fused := cInstruction(first, "A", "M", "")
+			return &fused, true
+		}
+	case dest == "M" && (comp == "M+1" || comp == "M-1") && nextDest == "A" && nextComp == "M":
+		// M=M-1; A=M -> AM=M-1
+		fused := cInstruction(first, "AM", comp, "")
+		return &fused, true
+	case dest == "M" && comp == "D" && nextDest == "D" && nextComp == "M",
+		dest == "D" && comp == "M" && nextDest == "M" && nextComp == "D":
+		// M=D; D=M -> M=D
+		return &first, true
+	}
+	return nil, false
+}
+
+// isLive reports whether the register, "A" or "D", may be read by the commands before it is
+// written. Labels and jumps may lead anywhere, so the register is live there.
+func isLive(commands []Command, register string) bool {
+	for _, c := range commands {
+		switch c.CommandType {
+		case LabelDeclarationCommandType:
+			return true
+		case AInstructionCommandType:
+			if register == "A" {
+				return false
+			}
+			continue
+		}
+		dest, comp, jump := c.Tokens[0], c.Tokens[1], c.Tokens[2]
+		reads := strings.Contains(comp, register)
+		if register == "A" {
+			reads = reads || strings.Contains(comp, "M") || strings.Contains(dest, "M")
+		}
+		if reads || jump != "" {
+			return true
+		}
+		if strings.Contains(dest, register) {
+			return false
+		}
+	}
+	return true
+}
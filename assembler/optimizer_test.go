@@ -0,0 +1,153 @@
+package assembler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			"same A-instruction",
+			"@SP\nD=M\n@SP\nM=D+1",
+			"@SP\nD=M\nM=D+1",
+		},
+		{
+			"same A-instruction after a label",
+			"@SP\nD=M\n(L)\n@SP\nM=D+1",
+			"@SP\nD=M\n(L)\n@SP\nM=D+1",
+		},
+		{
+			"same A-instruction after writing A",
+			"@SP\nA=M\n@SP\nM=D",
+			"@SP\nA=M\n@SP\nM=D",
+		},
+		{
+			"overwritten A",
+			"@R0\n@R1\nM=D",
+			"@R1\nM=D",
+		},
+		{
+			"overwritten D",
+			"@5\nD=A\n@6\nD=A\n@R0\nM=D",
+			"@6\nD=A\n@R0\nM=D",
+		},
+		{
+			"D read by a jump",
+			"@5\nD=A\n@END\nD;JGT\n@6\nD=A\n(END)",
+			"@5\nD=A\n@END\nD;JGT\n@6\nD=A\n(END)",
+		},
+		{
+			"negated M",
+			"@R0\nM=D-M\nM=-M",
+			"@R0\nM=M-D",
+		},
+		{
+			"negated D",
+			"@R0\nD=D-A\nD=-D",
+			"@R0\nD=A-D",
+		},
+		{
+			"negation after a label",
+			"@R0\nM=D-M\n(L)\nM=-M",
+			"@R0\nM=D-M\n(L)\nM=-M",
+		},
+		{
+			"increment and decrement",
+			"@SP\nM=M+1\nM=M-1\n@R0\nM=D",
+			"@R0\nM=D",
+		},
+		{
+			"increment and decrement with A",
+			"@SP\nM=M+1\nAM=M-1\nM=D",
+			"@SP\nA=M\nM=D",
+		},
+		{
+			"decrement and load",
+			"@SP\nM=M-1\nA=M\nD=M",
+			"@SP\nAM=M-1\nD=M",
+		},
+		{
+			"decrement and load at a jump target",
+			"@SP\nM=M-1\n(L)\nA=M\nD=M",
+			"@SP\nM=M-1\n(L)\nA=M\nD=M",
+		},
+		{
+			"store and load",
+			"@R0\nM=D\nD=M\n@R1\nM=D",
+			"@R0\nM=D\n@R1\nM=D",
+		},
+		{
+			"load and store",
+			"@R0\nD=M\nM=D\n@R1\nM=D",
+			"@R0\nD=M\n@R1\nM=D",
+		},
+		{
+			"constant 1",
+			"@1\nD=A\n@R0\nM=D",
+			"D=1\n@R0\nM=D",
+		},
+		{
+			"constant 0",
+			"@0\nD=A\n@R0\nM=D+M",
+			"D=0\n@R0\nM=M",
+		},
+		{
+			"constant 1 with A read",
+			"@1\nD=A\nM=D",
+			"@1\nD=1\nM=D",
+		},
+		{
+			"constant 2",
+			"@2\nD=A\n@R0\nM=D",
+			"@2\nD=A\n@R0\nM=D",
+		},
+		{
+			"unreachable",
+			"@END\n0;JMP\n@R0\nM=D\n(END)\n@END\n0;JMP",
+			"@END\n0;JMP\n(END)\n@END\n0;JMP",
+		},
+		{
+			"conditional jump",
+			"@END\nD;JGT\n@R0\nM=D\n(END)\n@END\n0;JMP",
+			"@END\nD;JGT\n@R0\nM=D\n(END)\n@END\n0;JMP",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := Parse(strings.Split(tt.code, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			optimized, stats := Optimize(commands)
+			actual := make([]string, len(optimized))
+			for i, command := range optimized {
+				actual[i] = command.String()
+			}
+			if strings.Join(actual, "\n") != tt.expected {
+				t.Fatalf("expected %q, got %q", strings.Split(tt.expected, "\n"), actual)
+			}
+			if stats.Before != countInstructions(commands) || stats.After != countInstructions(optimized) {
+				t.Fatalf("expected %d instructions instead of %d, got %s", countInstructions(optimized), countInstructions(commands), stats)
+			}
+		})
+	}
+}
+
+func TestOptimize_MemoryLocations(t *testing.T) {
+	commands, err := Parse([]string{"@R0", "@R1", "D=M", "(LOOP)", "@LOOP", "D;JGT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	optimized, _ := Optimize(commands)
+	expected := []int32{0, 1, 2, 2, 3}
+	for i, command := range optimized {
+		if command.MemoryLocation != expected[i] {
+			t.Fatalf("expected %s at %d, got %d", command, expected[i], command.MemoryLocation)
+		}
+	}
+}
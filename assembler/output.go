@@ -1,7 +1,9 @@
 package assembler
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"strconv"
 )
 
@@ -31,9 +33,119 @@ func OutputBinaryCode(instructions []Instruction) ([]string, error) {
 			if !ok {
 				return res, fmt.Errorf("failed to cast A instruction %v", i)
 			}
-			str = "111" + string(c.Comp) + string(c.Dst) + string(c.Jump)
+			prefix := c.Prefix
+			if prefix == "" {
+				prefix = StandardPrefix
+			}
+			str = string(prefix) + string(c.Comp) + string(c.Dst) + string(c.Jump)
 		}
 		res = append(res, str)
 	}
 	return res, nil
 }
+
+// OutputWords returns the machine code of instructions as 16-bit words.
+func OutputWords(instructions []Instruction) ([]uint16, error) {
+	code, err := OutputBinaryCode(instructions)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]uint16, len(code))
+	for i, line := range code {
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil {
+			return res, fmt.Errorf("invalid machine code %s", line)
+		}
+		res[i] = uint16(word)
+	}
+	return res, nil
+}
+
+// OutputFormat is a format the machine code of a program can be written in.
+type OutputFormat string
+
+const (
+	// HackFormat is the text format of the course, a line of 16 '0' and '1' per instruction
+	HackFormat OutputFormat = "hack"
+	// BinaryFormat is a raw image of big-endian 16-bit words
+	BinaryFormat OutputFormat = "bin"
+	// IntelHexFormat is Intel HEX, each word is two bytes at twice its address, big-endian
+	IntelHexFormat OutputFormat = "hex"
+	// ReadmembFormat is a memory file for Verilog's $readmemb and Logisim
+	ReadmembFormat OutputFormat = "mem"
+)
+
+// OutputFormats are the formats WriteOutput supports.
+var OutputFormats = []OutputFormat{HackFormat, BinaryFormat, IntelHexFormat, ReadmembFormat}
+
+// WriteOutput writes the program words to w in format.
+func WriteOutput(w io.Writer, format OutputFormat, words []uint16) error {
+	switch format {
+	case HackFormat:
+		for _, word := range words {
+			if _, err := fmt.Fprintf(w, "%016b\n", word); err != nil {
+				return err
+			}
+		}
+		return nil
+	case BinaryFormat:
+		buf := make([]byte, 2*len(words))
+		for i, word := range words {
+			binary.BigEndian.PutUint16(buf[2*i:], word)
+		}
+		_, err := w.Write(buf)
+		return err
+	case IntelHexFormat:
+		return writeIntelHex(w, words)
+	case ReadmembFormat:
+		if _, err := fmt.Fprintf(w, "// %d words of 16 bits\n@0\n", len(words)); err != nil {
+			return err
+		}
+		for _, word := range words {
+			if _, err := fmt.Fprintf(w, "%016b\n", word); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s", format)
+}
+
+// intelHexRecordSize is the number of data bytes of an Intel HEX record.
+const intelHexRecordSize = 16
+
+func writeIntelHex(w io.Writer, words []uint16) error {
+	data := make([]byte, 2*len(words))
+	for i, word := range words {
+		binary.BigEndian.PutUint16(data[2*i:], word)
+	}
+	upper := 0
+	for offset := 0; offset < len(data); offset += intelHexRecordSize {
+		if offset>>16 != upper {
+			// extended linear address record, for programs larger than 64 KiB
+			upper = offset >> 16
+			if err := writeIntelHexRecord(w, 0, 0x04, []byte{byte(upper >> 8), byte(upper)}); err != nil {
+				return err
+			}
+		}
+		end := min(offset+intelHexRecordSize, len(data))
+		if err := writeIntelHexRecord(w, uint16(offset), 0x00, data[offset:end]); err != nil {
+			return err
+		}
+	}
+	return writeIntelHexRecord(w, 0, 0x01, nil)
+}
+
+// writeIntelHexRecord writes a record `:LLAAAATT<data>CC`, CC being the two's complement of the
+// sum of its bytes.
+func writeIntelHexRecord(w io.Writer, address uint16, recordType byte, data []byte) error {
+	record := []byte{byte(len(data)), byte(address >> 8), byte(address), recordType}
+	record = append(record, data...)
+	sum := byte(0)
+	for _, b := range record {
+		sum += b
+	}
+	record = append(record, -sum)
+	_, err := fmt.Fprintf(w, ":%X\n", record)
+	return err
+}
@@ -0,0 +1,186 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// addWords is the machine code of ch6's Add.asm: @2, D=A, @3, D=D+A, @0, M=D.
+var addWords = []uint16{0x0002, 0xEC10, 0x0003, 0xE090, 0x0000, 0xE308}
+
+func TestOutputWords(t *testing.T) {
+	commands, err := Parse([]string{"@2", "D=A", "@3", "D=D+A", "@0", "M=D"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := OutputWords(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != len(addWords) {
+		t.Fatalf("expected %d words, got %d", len(addWords), len(words))
+	}
+	for i, word := range words {
+		if word != addWords[i] {
+			t.Fatalf("expected %04X at %d, got %04X", addWords[i], i, word)
+		}
+	}
+}
+
+func TestOutputBinaryCode_ISA(t *testing.T) {
+	commands, err := Parse([]string{"D=A", "D=D<<", "0;JMP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	a.SetISA(ExtendedISA)
+	instructions, err := a.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instructions[0] != (CInstruction{Dst: DDestination, Comp: A, Jump: NotJump}) {
+		t.Fatalf("expected D=A without prefix, got %+v", instructions[0])
+	}
+	if instructions[1] != (CInstruction{Prefix: ShiftPrefix, Dst: DDestination, Comp: ShiftLeftD, Jump: NotJump}) {
+		t.Fatalf("expected D=D<< with the shift prefix, got %+v", instructions[1])
+	}
+	if Zero != "0101010" {
+		t.Fatalf("expected the a and c bits for Zero, got %s", Zero)
+	}
+
+	code, err := OutputBinaryCode(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1110110000010000", "1010110000010000", "1110101010000111"}
+	if strings.Join(code, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v, got %v", expected, code)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	tests := []struct {
+		format   OutputFormat
+		expected string
+	}{
+		{
+			HackFormat,
+			"0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n0000000000000000\n1110001100001000\n",
+		},
+		{
+			BinaryFormat,
+			"\x00\x02\xEC\x10\x00\x03\xE0\x90\x00\x00\xE3\x08",
+		},
+		{
+			IntelHexFormat,
+			":0C0000000002EC100003E0900000E30898\n:00000001FF\n",
+		},
+		{
+			ReadmembFormat,
+			"// 6 words of 16 bits\n@0\n0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n0000000000000000\n1110001100001000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var out bytes.Buffer
+			err := WriteOutput(&out, tt.format, addWords)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+
+	err := WriteOutput(&bytes.Buffer{}, "elf", addWords)
+	if err == nil || err.Error() != "unknown output format elf" {
+		t.Fatalf("expected an unknown format error, got %v", err)
+	}
+}
+
+// readIntelHex decodes Intel HEX into words, checking the checksum of every record and that the
+// last one is the end of file record.
+func readIntelHex(t *testing.T, text string) []uint16 {
+	t.Helper()
+	data := make([]byte, 0)
+	upper := 0
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		record, err := hex.DecodeString(strings.TrimPrefix(line, ":"))
+		if err != nil || !strings.HasPrefix(line, ":") || len(record) < 5 || len(record) != 5+int(record[0]) {
+			t.Fatalf("invalid record %s", line)
+		}
+		sum := byte(0)
+		for _, b := range record {
+			sum += b
+		}
+		if sum != 0 {
+			t.Fatalf("invalid checksum in %s", line)
+		}
+		payload := record[4 : len(record)-1]
+		switch record[3] {
+		case 0x00:
+			address := upper<<16 | int(record[1])<<8 | int(record[2])
+			if address != len(data) {
+				t.Fatalf("expected the record at %X, got %X in %s", len(data), address, line)
+			}
+			data = append(data, payload...)
+		case 0x01:
+			if i != len(lines)-1 || line != ":00000001FF" {
+				t.Fatalf("expected the end of file record last, got %s at %d", line, i)
+			}
+		case 0x04:
+			upper = int(payload[0])<<8 | int(payload[1])
+		default:
+			t.Fatalf("unexpected record type in %s", line)
+		}
+	}
+	if lines[len(lines)-1] != ":00000001FF" {
+		t.Fatal("expected the end of file record")
+	}
+	words := make([]uint16, len(data)/2)
+	for i := range words {
+		words[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+	}
+	return words
+}
+
+// TestWriteOutput_IntelHexRoundTrip writes more words than a ROM holds, past the 64 KiB a record
+// address reaches, and reads them back.
+func TestWriteOutput_IntelHexRoundTrip(t *testing.T) {
+	words := make([]uint16, 40000)
+	for i := range words {
+		words[i] = uint16(i*7919 + 1)
+	}
+	var out bytes.Buffer
+	err := WriteOutput(&out, IntelHexFormat, words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), ":020000040001F9\n") {
+		t.Fatal("expected an extended linear address record for the second 64 KiB")
+	}
+	actual := readIntelHex(t, out.String())
+	if len(actual) != len(words) {
+		t.Fatalf("expected %d words, got %d", len(words), len(actual))
+	}
+	for i := range words {
+		if actual[i] != words[i] {
+			t.Fatalf("expected %04X at %d, got %04X", words[i], i, actual[i])
+		}
+	}
+
+	// an empty program is only the end of file record
+	out.Reset()
+	err = WriteOutput(&out, IntelHexFormat, nil)
+	if err != nil || out.String() != ":00000001FF\n" {
+		t.Fatalf("expected only the end of file record, got %q, %v", out.String(), err)
+	}
+}
@@ -2,6 +2,8 @@ package assembler
 
 import (
 	"fmt"
+	"hack/compiler/source"
+	"strconv"
 	"strings"
 )
 
@@ -14,130 +16,209 @@ const (
 )
 
 type Command struct {
-	LineNo         int32
+	// File is the file the command comes from, empty when the program wasn't read from files
+	File string
+	// LineNo and Column are 1-based, Column is the start of the command
+	LineNo int32
+	Column int32
+	// Source is the line the command was parsed from
+	Source         string
 	MemoryLocation int32
 	Tokens         []string
 	CommandType    CommandType
 }
 
-func parseLabelDeclaration(line string) (string, error) {
-	beforeComment := strings.Split(line, "//")[0]
-	spaceRemoved := strings.Replace(beforeComment, " ", "", -1)
-	if !strings.HasSuffix(spaceRemoved, ")") {
-		return "", fmt.Errorf("%v is not a valid label declaration", line)
-	}
-	return spaceRemoved[1 : len(spaceRemoved)-1], nil
+// Pos returns where the command is.
+func (c Command) Pos() source.Pos {
+	return source.Pos{File: c.File, Line: int(c.LineNo), Column: int(c.Column)}
 }
 
-func parseAInstruction(line string) (string, error) {
-	// TODO: only handle simple case for now
-	// @abc
-	beforeComment := strings.Split(line, "//")[0]
-	spaceRemoved := strings.Replace(beforeComment, " ", "", -1)
+// errorf returns an error at column of the command, with its line for context.
+func (c Command) errorf(column int, format string, args ...any) *source.Error {
+	pos := c.Pos()
+	pos.Column = column
+	return source.Errorf(pos, c.Source, format, args...)
+}
 
-	if spaceRemoved == "@" {
-		return "", fmt.Errorf("%v is not a valid A-Instruction", line)
-	}
-	return spaceRemoved[1:], nil
+func Parse(lines []string) ([]Command, error) {
+	return ParseSource(SourceLines("", lines))
 }
 
-func parseCInstruction(line string) ([]string, error) {
-	// TODO: only handle simple case for now
-	beforeComment := strings.Split(line, "//")[0]
-	spaceRemoved := strings.Replace(beforeComment, " ", "", -1)
-	tokens := strings.Split(spaceRemoved, ";")
-	res := make([]string, 0)
-	if len(tokens) == 0 || len(tokens) > 2 {
-		return res, fmt.Errorf("%v is not a valid C-Instruction", line)
+// ParseSource parses lines that may come from several files, such as the output of Expand. The
+// error is a source.ErrorList of every invalid line.
+func ParseSource(lines []SourceLine) ([]Command, error) {
+	res := make([]Command, 0)
+	var errs source.ErrorList
+
+	nextMemoryLocation := int32(0)
+	for _, sourceLine := range lines {
+		c := Command{
+			File:           sourceLine.File,
+			LineNo:         sourceLine.LineNo,
+			Source:         sourceLine.Text,
+			MemoryLocation: nextMemoryLocation,
+		}
+		tokens, err := lex(sourceLine.Text)
+		if err != nil {
+			errs.Add(c.errorf(err.(*lexError).column, "%s", err))
+			continue
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		c.Column = int32(tokens[0].column)
+
+		switch {
+		case tokens[0].is("("):
+			err = parseLabelDeclaration(&c, tokens)
+		case tokens[0].is("@"):
+			err = parseAInstruction(&c, tokens)
+		default:
+			err = parseCInstruction(&c, tokens)
+		}
+		if err != nil {
+			errs.Add(err.(*source.Error))
+			continue
+		}
+		if c.CommandType != LabelDeclarationCommandType {
+			nextMemoryLocation = nextMemoryLocation + 1
+		}
+		res = append(res, c)
 	}
+	return res, errs.Err()
+}
 
-	jump := ""
-	// has jump
-	if len(tokens) == 2 {
-		// TODO: check jump
-		jump = tokens[1]
+// endColumn returns the column right after the last token, where a missing token is reported.
+func endColumn(tokens []token) int {
+	last := tokens[len(tokens)-1]
+	return last.column + len(last.text)
+}
+
+func parseLabelDeclaration(c *Command, tokens []token) error {
+	// (LOOP)
+	c.CommandType = LabelDeclarationCommandType
+	if len(tokens) < 2 || tokens[1].typ != symbolToken {
+		if len(tokens) < 2 {
+			return c.errorf(endColumn(tokens), "expected label name")
+		}
+		return c.errorf(tokens[1].column, "expected label name, got %s", tokens[1].text)
 	}
-	destAndComp := strings.Split(tokens[0], "=")
-	if len(destAndComp) == 0 || len(destAndComp) > 2 {
-		return res, fmt.Errorf("%v is not a valid C-Instruction", line)
+	if len(tokens) < 3 || !tokens[2].is(")") {
+		if len(tokens) < 3 {
+			return c.errorf(endColumn(tokens), "expected ')'")
+		}
+		return c.errorf(tokens[2].column, "expected ')', got %s", tokens[2].text)
 	}
-
-	dest := ""
-	cmp := ""
-	if len(destAndComp) == 2 {
-		dest = destAndComp[0]
-		cmp = destAndComp[1]
-	} else {
-		cmp = destAndComp[0]
+	if len(tokens) > 3 {
+		return c.errorf(tokens[3].column, "unexpected %s after label declaration", tokens[3].text)
 	}
-
-	res = append(res, dest, cmp, jump)
-	return res, nil
-
+	c.Tokens = []string{tokens[1].text}
+	return nil
 }
 
-func Parse(lines []string) ([]Command, error) {
-	res := make([]Command, 0)
-
-	nextMemoryLocation := int32(0)
-	for lineNo, line := range lines {
-		tokens := strings.Split(line, " ")
-		c := Command{
-			LineNo:         int32(lineNo),
-			MemoryLocation: -1,
-			Tokens:         make([]string, 0),
+func parseAInstruction(c *Command, tokens []token) error {
+	// @value, @SCREEN+32
+	c.CommandType = AInstructionCommandType
+	operand := tokens[1:]
+	if len(operand) == 0 {
+		return c.errorf(endColumn(tokens), "expected value or symbol after '@'")
+	}
+	var text strings.Builder
+	for i, t := range operand {
+		if i%2 == 1 {
+			if !t.is("+") && !t.is("-") {
+				return c.errorf(t.column, "expected '+' or '-', got %s", t.text)
+			}
+			text.WriteString(t.text)
+			continue
 		}
-
-		for _, token := range tokens {
-			if len(token) == 0 {
-				continue
+		switch t.typ {
+		case numberToken:
+			value, err := parseNumber(t.text)
+			if err != nil {
+				return c.errorf(t.column, "%s", err)
 			}
-
-			if strings.HasPrefix(token, "//") {
-				break
+			if value > maxAddress {
+				return c.errorf(t.column, "%s is out of the range 0 to %d", t.text, maxAddress)
 			}
+		case symbolToken, charToken:
+		default:
+			return c.errorf(t.column, "expected value or symbol, got %s", t.text)
+		}
+		text.WriteString(t.text)
+	}
+	if len(operand)%2 == 0 {
+		return c.errorf(endColumn(tokens), "expected value or symbol after %s", operand[len(operand)-1].text)
+	}
+	c.Tokens = []string{text.String()}
+	return nil
+}
 
-			if token[0] == '(' {
-				if len(c.Tokens) > 0 {
-					return res, fmt.Errorf("failed to parse line %d, %s is invalid", lineNo, line)
-				}
-
-				label, err := parseLabelDeclaration(line)
-				if err != nil {
-					return res, fmt.Errorf("failed to parse line %d, %s", lineNo, err)
-				}
-				c.Tokens = []string{label}
-				c.CommandType = LabelDeclarationCommandType
-				// TODO: might want to decide it in translator?
-				c.MemoryLocation = nextMemoryLocation
-				res = append(res, c)
-				break
-			} else if token[0] == '@' {
-				aInstruction, err := parseAInstruction(line)
-				if err != nil {
-					return res, fmt.Errorf("failed to parse line %d, %s", lineNo, err)
-				}
-				c.Tokens = []string{aInstruction}
-				c.MemoryLocation = nextMemoryLocation
-				nextMemoryLocation = nextMemoryLocation + 1
-				c.CommandType = AInstructionCommandType
-				res = append(res, c)
-				break
-			} else {
-				cInstruction, err := parseCInstruction(line)
-				if err != nil {
-					return res, fmt.Errorf("failed to parse line %d, %s", lineNo, err)
-				}
-				c.Tokens = cInstruction
-				c.MemoryLocation = nextMemoryLocation
-				nextMemoryLocation = nextMemoryLocation + 1
-				c.CommandType = CInstructionCommandType
-				res = append(res, c)
-				break
+func parseCInstruction(c *Command, tokens []token) error {
+	// dest=comp;jump, dest and jump being optional
+	c.CommandType = CInstructionCommandType
+	end := endColumn(tokens)
+	dest := ""
+	for i, t := range tokens {
+		if t.is("=") {
+			if i != 1 || tokens[0].typ != symbolToken {
+				return c.errorf(tokens[0].column, "invalid destination, expect one of A, D, M, MD, AM, AD or AMD")
+			}
+			dest = tokens[0].text
+			if _, ok := destinations[dest]; !ok || dest == "" {
+				return c.errorf(tokens[0].column, "invalid destination %s, expect one of A, D, M, MD, AM, AD or AMD", dest)
 			}
+			tokens = tokens[2:]
+			break
 		}
+	}
+	if len(tokens) == 0 {
+		return c.errorf(end, "expected computation")
+	}
 
+	compColumn := tokens[0].column
+	var comp strings.Builder
+	jump := ""
+	for i, t := range tokens {
+		if t.is(";") {
+			rest := tokens[i+1:]
+			if len(rest) == 0 {
+				return c.errorf(t.column+1, "expected jump after ';'")
+			}
+			jump = rest[0].text
+			if _, ok := jumps[jump]; !ok || rest[0].typ != symbolToken || jump == "" {
+				return c.errorf(rest[0].column, "invalid jump %s, expect one of JGT, JEQ, JGE, JLT, JNE, JLE or JMP", jump)
+			}
+			if len(rest) > 1 {
+				return c.errorf(rest[1].column, "unexpected %s after jump", rest[1].text)
+			}
+			break
+		}
+		if t.is("=") {
+			return c.errorf(t.column, "unexpected '='")
+		}
+		comp.WriteString(t.text)
+	}
+	if comp.Len() == 0 {
+		return c.errorf(compColumn, "expected computation")
 	}
-	return res, nil
+	c.Tokens = []string{dest, comp.String(), jump}
+	return nil
+}
 
+// parseNumber parses a decimal, 0x hexadecimal or 0b binary number.
+func parseNumber(literal string) (int64, error) {
+	base := 10
+	digits := literal
+	switch {
+	case strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X"):
+		base, digits = 16, literal[2:]
+	case strings.HasPrefix(literal, "0b") || strings.HasPrefix(literal, "0B"):
+		base, digits = 2, literal[2:]
+	}
+	value, err := strconv.ParseInt(digits, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", literal)
+	}
+	return value, nil
 }
@@ -0,0 +1,87 @@
+package assembler
+
+import (
+	"hack/compiler/source"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	code := "// Main.asm\n\n(LOOP) // start\n\t@SCREEN+32\nAM=M-1;JGT\n0;JMP\n@'A'\n"
+	commands, err := Parse(strings.Split(code, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		commandType    CommandType
+		tokens         []string
+		pos            string
+		memoryLocation int32
+	}{
+		{LabelDeclarationCommandType, []string{"LOOP"}, "3:1", 0},
+		{AInstructionCommandType, []string{"SCREEN+32"}, "4:2", 0},
+		{CInstructionCommandType, []string{"AM", "M-1", "JGT"}, "5:1", 1},
+		{CInstructionCommandType, []string{"", "0", "JMP"}, "6:1", 2},
+		{AInstructionCommandType, []string{"'A'"}, "7:1", 3},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i, command := range commands {
+		e := expected[i]
+		if command.CommandType != e.commandType || strings.Join(command.Tokens, ",") != strings.Join(e.tokens, ",") ||
+			command.Pos().String() != e.pos || command.MemoryLocation != e.memoryLocation {
+			t.Fatalf("expected %q at %s, address %d, got %q at %s, address %d",
+				e.tokens, e.pos, e.memoryLocation, command.Tokens, command.Pos(), command.MemoryLocation)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"@1 # 2", "1:4: unexpected character '#'"},
+		{"D=é", "1:3: unexpected character 'é'"},
+		{"@'A", "1:2: invalid character, expect a printable character such as 'A'"},
+		{"(", "1:2: expected label name"},
+		{"(1)", "1:2: expected label name, got 1"},
+		{"(LOOP", "1:6: expected ')'"},
+		{"(LOOP(", "1:6: expected ')', got ("},
+		{"(LOOP) D", "1:8: unexpected D after label declaration"},
+		{"@", "1:2: expected value or symbol after '@'"},
+		{"@1 2", "1:4: expected '+' or '-', got 2"},
+		{"@1+", "1:4: expected value or symbol after +"},
+		{"@=", "1:2: expected value or symbol, got ="},
+		{"@0x1g", "1:2: invalid number 0x1g"},
+		{"@32768", "1:2: 32768 is out of the range 0 to 32767"},
+		{"@0b1000000000000000", "1:2: 0b1000000000000000 is out of the range 0 to 32767"},
+		{"X=D", "1:1: invalid destination X, expect one of A, D, M, MD, AM, AD or AMD"},
+		{"D+1=D", "1:1: invalid destination, expect one of A, D, M, MD, AM, AD or AMD"},
+		{"D=", "1:3: expected computation"},
+		{"D=D=A", "1:4: unexpected '='"},
+		{"0;", "1:3: expected jump after ';'"},
+		{"0;JUMP", "1:3: invalid jump JUMP, expect one of JGT, JEQ, JGE, JLT, JNE, JLE or JMP"},
+		{"0;JMP JMP", "1:7: unexpected JMP after jump"},
+		{";JMP", "1:1: expected computation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := Parse([]string{tt.line})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected+"\n") {
+				t.Fatalf("expected an error starting with %q, got %q", tt.expected, err)
+			}
+		})
+	}
+
+	// every invalid line is reported
+	_, err := ParseSource(SourceLines("Main.asm", []string{"@", "D=A", "0;JUMP"}))
+	errs, ok := err.(source.ErrorList)
+	if !ok || len(errs) != 2 || errs[0].Pos.String() != "Main.asm:1:2" || errs[1].Pos.String() != "Main.asm:3:3" {
+		t.Fatalf("expected the errors of lines 1 and 3, got %v", err)
+	}
+}
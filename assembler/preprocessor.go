@@ -0,0 +1,336 @@
+package assembler
+
+import (
+	"bufio"
+	"hack/compiler/source"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SourceLine is a line of assembly and where it was written, LineNo is 1-based like
+// Command.LineNo.
+type SourceLine struct {
+	File   string
+	LineNo int32
+	Text   string
+}
+
+// SourceLines returns lines as the lines of file.
+func SourceLines(file string, lines []string) []SourceLine {
+	res := make([]SourceLine, len(lines))
+	for i, line := range lines {
+		res[i] = SourceLine{File: file, LineNo: int32(i + 1), Text: line}
+	}
+	return res
+}
+
+// maxMacroDepth bounds the expansion of macros using macros, to catch recursive ones.
+const maxMacroDepth = 64
+
+type macro struct {
+	name       string
+	parameters []string
+	body       []SourceLine
+}
+
+// preprocessor keeps the definitions of an Expand run.
+type preprocessor struct {
+	defines map[string]string
+	macros  map[string]*macro
+	// including are the files being included, to catch include cycles
+	including map[string]bool
+	// expansions numbers the macro expansions for the `%` of local labels
+	expansions int
+}
+
+// ExpandFile reads the assembly file at path and expands its directives, see Expand.
+func ExpandFile(path string) ([]SourceLine, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return Expand(path, lines)
+}
+
+// ExpandReader reads the assembly of file from r and expands its directives, see Expand. file
+// may be empty when the assembly isn't read from a file, includes are then relative to the
+// working directory.
+func ExpandReader(file string, r io.Reader) ([]SourceLine, error) {
+	lines, err := scanLines(r)
+	if err != nil {
+		return nil, err
+	}
+	return Expand(file, lines)
+}
+
+// Expand expands the directives of lines, the content of file:
+//
+//	.include "other.asm"    the lines of other.asm, relative to the directory of file
+//	.define NAME value      NAME is replaced by value in the following lines
+//	.macro NAME a, b        a macro taking the parameters a and b, up to .endm, used
+//	...                     as `NAME x, y`. A `%` in its body is replaced by a number
+//	.endm                   unique to each use, to declare labels local to it.
+//
+// The returned lines keep the file and line they were written at, so errors on them point at
+// the original source.
+func Expand(file string, lines []string) ([]SourceLine, error) {
+	p := &preprocessor{
+		defines:   make(map[string]string),
+		macros:    make(map[string]*macro),
+		including: make(map[string]bool),
+	}
+	if file != "" {
+		p.including[filepath.Clean(file)] = true
+	}
+	return p.expand(SourceLines(file, lines), 0)
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return scanLines(f)
+}
+
+func scanLines(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// errorf returns an error at the directive or macro use of line.
+func errorf(line SourceLine, format string, args ...any) error {
+	column := 1 + utf8.RuneCountInString(line.Text) - utf8.RuneCountInString(strings.TrimLeft(line.Text, " \t"))
+	pos := source.Pos{File: line.File, Line: int(line.LineNo), Column: column}
+	return source.Errorf(pos, line.Text, format, args...)
+}
+
+// fields returns the whitespace separated fields of a line, without its comment.
+func fields(text string) []string {
+	return strings.Fields(strings.Split(text, "//")[0])
+}
+
+// arguments splits the arguments of a directive or macro use, separated by commas or spaces.
+func arguments(fields []string) []string {
+	res := make([]string, 0)
+	for _, field := range fields {
+		for _, arg := range strings.Split(field, ",") {
+			if arg != "" {
+				res = append(res, arg)
+			}
+		}
+	}
+	return res
+}
+
+func (p *preprocessor) expand(lines []SourceLine, depth int) ([]SourceLine, error) {
+	res := make([]SourceLine, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		f := fields(line.Text)
+		if len(f) == 0 {
+			res = append(res, line)
+			continue
+		}
+
+		switch f[0] {
+		case ".include":
+			included, err := p.include(line, f)
+			if err != nil {
+				return res, err
+			}
+			res = append(res, included...)
+		case ".define":
+			if len(f) != 3 {
+				return res, errorf(line, "expect `.define NAME value`")
+			}
+			if !isSymbol(f[1]) {
+				return res, errorf(line, "%s is not a valid name", f[1])
+			}
+			p.defines[f[1]] = p.substitute(f[2], nil)
+		case ".macro":
+			end, err := p.defineMacro(lines, i)
+			if err != nil {
+				return res, err
+			}
+			i = end
+		case ".endm":
+			return res, errorf(line, ".endm without .macro")
+		default:
+			m, ok := p.macros[f[0]]
+			if !ok && isMacroUse(f) {
+				return res, errorf(line, "unknown macro %s", f[0])
+			}
+			if !ok {
+				line.Text = p.substitute(line.Text, nil)
+				res = append(res, line)
+				continue
+			}
+			expanded, err := p.expandMacro(m, line, arguments(f[1:]), depth)
+			if err != nil {
+				return res, err
+			}
+			res = append(res, expanded...)
+		}
+	}
+	return res, nil
+}
+
+// isMacroUse reports whether the fields of a line are a symbol followed by arguments, which no
+// instruction is: the second field of `D = A` or `0 ;JMP` starts with an operator.
+func isMacroUse(f []string) bool {
+	if len(f) < 2 || !isSymbol(f[0]) {
+		return false
+	}
+	return isSymbolPart(f[1][0]) || f[1][0] == '\''
+}
+
+func (p *preprocessor) include(line SourceLine, f []string) ([]SourceLine, error) {
+	if len(f) != 2 {
+		return nil, errorf(line, "expect `.include \"file\"`")
+	}
+	name, err := strconv.Unquote(f[1])
+	if err != nil {
+		return nil, errorf(line, "%s is not a quoted file name", f[1])
+	}
+	path := filepath.Clean(filepath.Join(filepath.Dir(line.File), name))
+	if p.including[path] {
+		return nil, errorf(line, "%s includes itself", path)
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, errorf(line, "%v", err)
+	}
+	p.including[path] = true
+	defer delete(p.including, path)
+	return p.expand(SourceLines(path, lines), 0)
+}
+
+// defineMacro defines the macro starting at lines[start], it returns the index of its .endm.
+func (p *preprocessor) defineMacro(lines []SourceLine, start int) (int, error) {
+	line := lines[start]
+	f := fields(line.Text)
+	if len(f) < 2 || !isSymbol(f[1]) {
+		return start, errorf(line, "expect `.macro NAME parameters...`")
+	}
+	m := &macro{name: f[1], parameters: arguments(f[2:])}
+	for _, parameter := range m.parameters {
+		if !isSymbol(parameter) {
+			return start, errorf(line, "%s is not a valid parameter name", parameter)
+		}
+	}
+	for i := start + 1; i < len(lines); i++ {
+		f := fields(lines[i].Text)
+		if len(f) > 0 && f[0] == ".endm" {
+			p.macros[m.name] = m
+			return i, nil
+		}
+		if len(f) > 1 && f[0] == ".macro" {
+			return i, errorf(lines[i], "macro %s is defined inside macro %s", f[1], m.name)
+		}
+		if len(f) > 0 && f[0] == ".macro" {
+			return i, errorf(lines[i], "macro defined inside macro %s", m.name)
+		}
+		m.body = append(m.body, lines[i])
+	}
+	return start, errorf(line, "macro %s has no .endm", m.name)
+}
+
+func (p *preprocessor) expandMacro(m *macro, use SourceLine, args []string, depth int) ([]SourceLine, error) {
+	if depth >= maxMacroDepth {
+		return nil, errorf(use, "macro %s is expanded more than %d levels deep", m.name, maxMacroDepth)
+	}
+	if len(args) != len(m.parameters) {
+		return nil, errorf(use, "macro %s expects %d arguments but got %d", m.name, len(m.parameters), len(args))
+	}
+	values := make(map[string]string, len(args))
+	for i, parameter := range m.parameters {
+		values[parameter] = args[i]
+	}
+	p.expansions++
+	local := strconv.Itoa(p.expansions)
+
+	body := make([]SourceLine, len(m.body))
+	for i, line := range m.body {
+		line.Text = strings.ReplaceAll(p.substitute(line.Text, values), "%", local)
+		body[i] = line
+	}
+	return p.expand(body, depth+1)
+}
+
+// substitute replaces the symbols of text by their value in values, then in the defines. The
+// comment of text is kept as is.
+func (p *preprocessor) substitute(text string, values map[string]string) string {
+	code, comment, hasComment := strings.Cut(text, "//")
+	var out strings.Builder
+	for i := 0; i < len(code); {
+		if code[i] == '\'' && i+2 < len(code) && code[i+2] == '\'' {
+			// a character such as 'A'
+			out.WriteString(code[i : i+3])
+			i += 3
+			continue
+		}
+		if code[i] >= '0' && code[i] <= '9' {
+			// a number such as 0x4000
+			j := i + 1
+			for j < len(code) && isSymbolPart(code[j]) {
+				j++
+			}
+			out.WriteString(code[i:j])
+			i = j
+			continue
+		}
+		if !isSymbolStart(code[i]) {
+			out.WriteByte(code[i])
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(code) && isSymbolPart(code[j]) {
+			j++
+		}
+		symbol := code[i:j]
+		if value, ok := values[symbol]; ok {
+			symbol = value
+		} else if value, ok := p.defines[symbol]; ok {
+			symbol = value
+		}
+		out.WriteString(symbol)
+		i = j
+	}
+	if hasComment {
+		out.WriteString("//" + comment)
+	}
+	return out.String()
+}
+
+func isSymbolStart(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSymbolPart(c byte) bool {
+	return isSymbolStart(c) || c >= '0' && c <= '9'
+}
+
+// isSymbol reports whether s is a valid Hack symbol: letters, digits, `_`, `.`, `$` and `:`, not
+// starting with a digit.
+func isSymbol(s string) bool {
+	if s == "" || !isSymbolStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isSymbolPart(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package assembler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// expanded returns the text of lines with where each one comes from, as file:line: text.
+func expanded(lines []SourceLine) []string {
+	res := make([]string, len(lines))
+	for i, line := range lines {
+		res[i] = fmt.Sprintf("%s:%d: %s", filepath.Base(line.File), line.LineNo, strings.TrimSpace(line.Text))
+	}
+	return res
+}
+
+func TestExpand(t *testing.T) {
+	code := []string{
+		".define STACK 256",
+		".define TOP STACK+1 // above",
+		".macro JUMPIF cond, target",
+		"\tD;cond",
+		"\t@target // to target",
+		"(SKIP%)",
+		".endm",
+		"@TOP",
+		"JUMPIF JGT, STACK",
+		"JUMPIF JEQ,LOOP",
+		"@'S'",
+	}
+	lines, err := Expand("Main.asm", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Main.asm:8: @256+1",
+		"Main.asm:4: D;JGT",
+		"Main.asm:5: @256 // to target",
+		"Main.asm:6: (SKIP1)",
+		"Main.asm:4: D;JEQ",
+		"Main.asm:5: @LOOP // to target",
+		"Main.asm:6: (SKIP2)",
+		"Main.asm:11: @'S'",
+	}
+	if actual := expanded(lines); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestExpand_NestedMacros(t *testing.T) {
+	code := []string{
+		".macro INC r",
+		"@r",
+		"M=M+1",
+		".endm",
+		".macro INC2 r",
+		"INC r",
+		"INC r",
+		".endm",
+		"INC2 R1",
+	}
+	lines, err := Expand("Main.asm", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Main.asm:2: @R1", "Main.asm:3: M=M+1", "Main.asm:2: @R1", "Main.asm:3: M=M+1"}
+	if actual := expanded(lines); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func writeAsm(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, code := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpandFile_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeAsm(t, dir, map[string]string{
+		"Main.asm":     "@0\n.include \"lib/Push.asm\"\nPUSH\n",
+		"lib/Push.asm": ".include \"Sp.asm\"\n.macro PUSH\n@SP\nM=M+1\nD=D+D\n.endm\n",
+		"lib/Sp.asm":   ".define SP R0\n",
+	})
+	lines, err := ExpandFile(filepath.Join(dir, "Main.asm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Main.asm:1: @0", "Push.asm:3: @R0", "Push.asm:4: M=M+1", "Push.asm:5: D=D+D"}
+	if actual := expanded(lines); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+
+	// errors point at the included file
+	commands, err := ParseSource(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Translate(commands)
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "lib/Push.asm")+":5:1: invalid computation D+D\n") {
+		t.Fatalf("expected an error at lib/Push.asm:5:1, got %v", err)
+	}
+}
+
+func TestExpandFile_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeAsm(t, dir, map[string]string{
+		"Main.asm":  ".include \"A.asm\"\n",
+		"A.asm":     "@1\n.include \"lib/B.asm\"\n",
+		"lib/B.asm": "  .include \"../A.asm\"\n",
+	})
+	_, err := ExpandFile(filepath.Join(dir, "Main.asm"))
+	expected := filepath.Join(dir, "lib/B.asm") + ":1:3: " + filepath.Join(dir, "A.asm") + " includes itself\n"
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Fatalf("expected an error starting with %q, got %v", expected, err)
+	}
+
+	// a file may be included twice when it doesn't include itself
+	writeAsm(t, dir, map[string]string{"Main.asm": ".include \"lib/C.asm\"\n.include \"lib/C.asm\"\n", "lib/C.asm": "@1\n"})
+	lines, err := ExpandFile(filepath.Join(dir, "Main.asm"))
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("expected the lines of C.asm twice, got %v, %v", lines, err)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"define without value", ".define SIZE", "Main.asm:1:1: expect `.define NAME value`"},
+		{"define invalid name", ".define 1SIZE 2", "Main.asm:1:1: 1SIZE is not a valid name"},
+		{"include without file", "  .include", "Main.asm:1:3: expect `.include \"file\"`"},
+		{"include unquoted file", ".include Other.asm", "Main.asm:1:1: Other.asm is not a quoted file name"},
+		{"include missing file", ".include \"Missing.asm\"", "Main.asm:1:1: open Missing.asm: no such file or directory"},
+		{"endm without macro", "@1\n.endm", "Main.asm:2:1: .endm without .macro"},
+		{"macro without name", ".macro", "Main.asm:1:1: expect `.macro NAME parameters...`"},
+		{"macro invalid parameter", ".macro M a, 1b\n.endm", "Main.asm:1:1: 1b is not a valid parameter name"},
+		{"macro without endm", ".macro M\n@1", "Main.asm:1:1: macro M has no .endm"},
+		{"macro inside macro", ".macro M\n.macro N\n.endm", "Main.asm:2:1: macro N is defined inside macro M"},
+		{"unnamed macro inside macro", ".macro M\n.macro\n.endm", "Main.asm:2:1: macro defined inside macro M"},
+		{"wrong number of arguments", ".macro M a\n@a\n.endm\nM 1, 2", "Main.asm:4:1: macro M expects 1 arguments but got 2"},
+		{"recursive macro", ".macro M\nM\n.endm\nM", "Main.asm:2:1: macro M is expanded more than 64 levels deep"},
+		{"unknown macro", ".macro PUSH r\n.endm\nPOP D, 1", "Main.asm:3:1: unknown macro POP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Expand("Main.asm", strings.Split(tt.code, "\n"))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected+"\n") {
+				t.Fatalf("expected an error starting with %q, got %q", tt.expected, err)
+			}
+		})
+	}
+
+	// instructions with spaces aren't macros
+	_, err := Expand("Main.asm", []string{"D = A", "0 ;JMP", "M = M + 1", "D ;JGT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
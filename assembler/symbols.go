@@ -0,0 +1,111 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols are the symbols a program defines, the predefined ones such as SP or SCREEN aren't
+// part of them.
+type Symbols struct {
+	// Labels maps every label to its ROM address
+	Labels map[string]int32
+	// Variables maps every variable to its RAM address
+	Variables map[string]int32
+}
+
+func newSymbols() *Symbols {
+	return &Symbols{Labels: make(map[string]int32), Variables: make(map[string]int32)}
+}
+
+type symbolEntry struct {
+	kind     string
+	name     string
+	location int32
+}
+
+func sortedEntries(kind string, symbols map[string]int32) []symbolEntry {
+	res := make([]symbolEntry, 0, len(symbols))
+	for name, location := range symbols {
+		res = append(res, symbolEntry{kind: kind, name: name, location: location})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].location != res[j].location {
+			return res[i].location < res[j].location
+		}
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+// WriteSymbols writes a symbol file, one `label NAME ADDRESS` or `variable NAME ADDRESS` line
+// per symbol ordered by address, labels first.
+func WriteSymbols(w io.Writer, symbols *Symbols) error {
+	entries := append(sortedEntries("label", symbols.Labels), sortedEntries("variable", symbols.Variables)...)
+	for _, entry := range entries {
+		_, err := fmt.Fprintf(w, "%s %s %d\n", entry.kind, entry.name, entry.location)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSymbols reads a symbol file written by WriteSymbols.
+func ReadSymbols(r io.Reader) (*Symbols, error) {
+	symbols := newSymbols()
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return symbols, fmt.Errorf("line %d: expect `kind name address` but got %q", lineNo, scanner.Text())
+		}
+		location, err := strconv.ParseInt(fields[2], 10, 32)
+		if err != nil {
+			return symbols, fmt.Errorf("line %d: invalid address %s", lineNo, fields[2])
+		}
+		switch fields[0] {
+		case "label":
+			symbols.Labels[fields[1]] = int32(location)
+		case "variable":
+			symbols.Variables[fields[1]] = int32(location)
+		default:
+			return symbols, fmt.Errorf("line %d: unknown symbol kind %s", lineNo, fields[0])
+		}
+	}
+	return symbols, scanner.Err()
+}
+
+// WriteListing writes a listing of a program: one tab separated `ADDRESS BINARY LINE SOURCE`
+// line per command, LINE being 1-based and prefixed by the file when known, such as
+// `fill.asm:12`. Label declarations have the address of the next instruction and no binary.
+// code is the output of OutputBinaryCode for commands.
+func WriteListing(w io.Writer, commands []Command, code []string) error {
+	next := 0
+	for _, command := range commands {
+		binary := ""
+		if command.CommandType != LabelDeclarationCommandType {
+			if next >= len(code) {
+				return fmt.Errorf("only %d instructions for more commands", len(code))
+			}
+			binary = code[next]
+			next++
+		}
+		line := strconv.Itoa(int(command.LineNo))
+		if command.File != "" {
+			line = command.File + ":" + line
+		}
+		_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", command.MemoryLocation, binary, line, strings.TrimSpace(command.Source))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package assembler
+
+import (
+	"bytes"
+	"maps"
+	"strings"
+	"testing"
+)
+
+const countdown = "@10\nD=A\n@n\nM=D\n(LOOP)\n  @n // count down\nMD=M-1\n@LOOP\nD;JGT\n(END)\n@END\n0;JMP"
+
+func TestSymbols_RoundTrip(t *testing.T) {
+	commands, err := ParseSource(SourceLines("Countdown.asm", strings.Split(countdown, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, symbols, err := New().TranslateWithSymbols(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = WriteSymbols(&out, symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "label LOOP 4\nlabel END 8\nvariable n 16\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+
+	read, err := ReadSymbols(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(read.Labels, symbols.Labels) || !maps.Equal(read.Variables, symbols.Variables) {
+		t.Fatalf("expected %+v, got %+v", symbols, read)
+	}
+}
+
+func TestReadSymbols_Errors(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"label LOOP\n", "line 1: expect `kind name address` but got \"label LOOP\""},
+		{"label LOOP 4\n\nvariable n x\n", "line 3: invalid address x"},
+		{"constant N 4\n", "line 1: unknown symbol kind constant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			_, err := ReadSymbols(strings.NewReader(tt.file))
+			if err == nil || err.Error() != tt.expected {
+				t.Fatalf("expected %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestWriteListing(t *testing.T) {
+	commands, err := ParseSource(SourceLines("Countdown.asm", strings.Split(countdown, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := OutputBinaryCode(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = WriteListing(&out, commands, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"0\t0000000000001010\tCountdown.asm:1\t@10",
+		"1\t1110110000010000\tCountdown.asm:2\tD=A",
+		"2\t0000000000010000\tCountdown.asm:3\t@n",
+		"3\t1110001100001000\tCountdown.asm:4\tM=D",
+		"4\t\tCountdown.asm:5\t(LOOP)",
+		"4\t0000000000010000\tCountdown.asm:6\t@n // count down",
+		"5\t1111110010011000\tCountdown.asm:7\tMD=M-1",
+		"6\t0000000000000100\tCountdown.asm:8\t@LOOP",
+		"7\t1110001100000001\tCountdown.asm:9\tD;JGT",
+		"8\t\tCountdown.asm:10\t(END)",
+		"8\t0000000000001000\tCountdown.asm:11\t@END",
+		"9\t1110101010000111\tCountdown.asm:12\t0;JMP",
+	}
+	if out.String() != strings.Join(expected, "\n")+"\n" {
+		t.Fatalf("expected %q, got %q", expected, strings.Split(out.String(), "\n"))
+	}
+
+	err = WriteListing(&bytes.Buffer{}, commands, nil)
+	if err == nil || err.Error() != "only 0 instructions for more commands" {
+		t.Fatalf("expected a missing instructions error, got %v", err)
+	}
+}
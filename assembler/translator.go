@@ -2,10 +2,17 @@ package assembler
 
 import (
 	"fmt"
-	"strconv"
+	"hack/compiler/source"
+	"log"
+	"strings"
 )
 
-var symbolTable = map[string]int32{
+// romSize is the number of instructions the Hack ROM holds.
+const romSize = 32768
+
+// predefinedSymbols are the symbols every program starts with, each run copies them into its own
+// table.
+var predefinedSymbols = map[string]int32{
 	"R0":     0,
 	"R1":     1,
 	"R2":     2,
@@ -63,6 +70,15 @@ const (
 	AMDDestination  Destination = "111"
 )
 
+// Prefix is the bits 15 to 13 of a C-instruction, they identify its instruction set.
+type Prefix string
+
+const (
+	StandardPrefix Prefix = "111"
+	// ShiftPrefix marks the shifts of ExtendedISA.
+	ShiftPrefix Prefix = "101"
+)
+
 type Computation string
 
 const (
@@ -110,167 +126,231 @@ const (
 )
 
 type CInstruction struct {
-	Dst  Destination
-	Comp Computation
-	Jump Jump
+	// Prefix is empty for the standard instruction set.
+	Prefix Prefix
+	Dst    Destination
+	Comp   Computation
+	Jump   Jump
 }
 
 func (receiver CInstruction) Type() InstructionType {
 	return CInstructionType
 }
 
-func buildCInstruction(command Command) (CInstruction, error) {
-	var dst Destination
-	switch command.Tokens[0] {
-	case "":
-		dst = NullDestination
-	case "M":
-		dst = MDestination
-	case "D":
-		dst = DDestination
-	case "MD":
-		dst = MDDestination
-	case "A":
-		dst = ADestination
-	case "AM":
-		dst = AMDestination
-	case "AD":
-		dst = ADDestination
-	case "AMD":
-		dst = AMDDestination
-	default:
-		return CInstruction{}, fmt.Errorf("invalid dst: %v", command.Tokens[0])
+// destinations, computations and jumps map the mnemonics of the C-instruction fields to their
+// bits.
+var destinations = map[string]Destination{
+	"":    NullDestination,
+	"M":   MDestination,
+	"D":   DDestination,
+	"MD":  MDDestination,
+	"A":   ADestination,
+	"AM":  AMDestination,
+	"AD":  ADDestination,
+	"AMD": AMDDestination,
+}
+
+var computations = map[string]Computation{
+	"0":   Zero,
+	"1":   One,
+	"-1":  NegativeOne,
+	"D":   D,
+	"A":   A,
+	"M":   M,
+	"!D":  NotD,
+	"!A":  NotA,
+	"!M":  NotM,
+	"-D":  NegativeD,
+	"-A":  NegativeA,
+	"-M":  NegativeM,
+	"D+1": DPlusOne,
+	"A+1": APlusOne,
+	"M+1": MPlusOne,
+	"D-1": DMinusOne,
+	"A-1": AMinusOne,
+	"M-1": MMinusOne,
+	"D+A": DPlusA,
+	"D+M": DPlusM,
+	"D-A": DMinusA,
+	"D-M": DMinusM,
+	"A-D": AMinusD,
+	"M-D": MMinusD,
+	"D&A": DAndA,
+	"D&M": DAndM,
+	"D|A": DOrA,
+	"D|M": DOrM,
+}
+
+var jumps = map[string]Jump{
+	"":    NotJump,
+	"JGT": GreatJump,
+	"JEQ": EqualJump,
+	"JGE": GreatEqualJump,
+	"JLT": LessJump,
+	"JNE": NotEqualJump,
+	"JLE": LessEqualJump,
+	"JMP": AlwaysJump,
+}
+
+func mnemonic[T comparable](table map[string]T, value T) (string, bool) {
+	for m, v := range table {
+		if v == value {
+			return m, true
+		}
 	}
+	return "", false
+}
+
+// Mnemonic returns the assembly of d, such as "AM", empty for NullDestination.
+func (d Destination) Mnemonic() (string, bool) {
+	return mnemonic(destinations, d)
+}
+
+// Mnemonic returns the assembly of c, such as "D+M".
+func (c Computation) Mnemonic() (string, bool) {
+	return mnemonic(computations, c)
+}
+
+// Mnemonic returns the assembly of j, such as "JGT", empty for NotJump.
+func (j Jump) Mnemonic() (string, bool) {
+	return mnemonic(jumps, j)
+}
 
-	var comp Computation
-	switch command.Tokens[1] {
-	case "0":
-		comp = Zero
-	case "1":
-		comp = One
-	case "-1":
-		comp = NegativeOne
-	case "D":
-		comp = D
-	case "A":
-		comp = A
-	case "M":
-		comp = M
-	case "!D":
-		comp = NotD
-	case "!A":
-		comp = NotA
-	case "!M":
-		comp = NotM
-	case "-D":
-		comp = NegativeD
-	case "-A":
-		comp = NegativeA
-	case "-M":
-		comp = NegativeM
-
-	case "D+1":
-		comp = DPlusOne
-	case "A+1":
-		comp = APlusOne
-	case "M+1":
-		comp = MPlusOne
-	case "D-1":
-		comp = DMinusOne
-	case "A-1":
-		comp = AMinusOne
-	case "M-1":
-		comp = MMinusOne
-	case "D+A":
-		comp = DPlusA
-	case "D+M":
-		comp = DPlusM
-	case "D-A":
-		comp = DMinusA
-	case "D-M":
-		comp = DMinusM
-	case "A-D":
-		comp = AMinusD
-	case "M-D":
-		comp = MMinusD
-	case "D&A":
-		comp = DAndA
-	case "D&M":
-		comp = DAndM
-	case "D|A":
-		comp = DOrA
-	case "D|M":
-		comp = DOrM
-	default:
-		return CInstruction{}, fmt.Errorf("invalid comp: %v", command.Tokens[1])
+func (a *Assembler) buildCInstruction(command Command) (CInstruction, error) {
+	dst, ok := destinations[command.Tokens[0]]
+	if !ok {
+		return CInstruction{}, fmt.Errorf("invalid dst: %v", command.Tokens[0])
 	}
-	var jump Jump
-	switch command.Tokens[2] {
-	case "":
-		jump = NotJump
-	case "JGT":
-		jump = GreatJump
-	case "JEQ":
-		jump = EqualJump
-	case "JGE":
-		jump = GreatEqualJump
-	case "JLT":
-		jump = LessJump
-	case "JNE":
-		jump = NotEqualJump
-	case "JLE":
-		jump = LessEqualJump
-	case "JMP":
-		jump = AlwaysJump
-	default:
+	prefix, comp, ok := a.isa.Computation(command.Tokens[1])
+	if !ok {
+		if _, _, ok := ExtendedISA.Computation(command.Tokens[1]); ok && a.isa != ExtendedISA {
+			return CInstruction{}, fmt.Errorf("computation %s needs the extended instruction set", command.Tokens[1])
+		}
+		return CInstruction{}, fmt.Errorf("invalid computation %s", command.Tokens[1])
+	}
+	jump, ok := jumps[command.Tokens[2]]
+	if !ok {
 		return CInstruction{}, fmt.Errorf("invalid jump: %v", command.Tokens[2])
 	}
 
-	return CInstruction{Dst: dst, Comp: comp, Jump: jump}, nil
+	return CInstruction{Prefix: prefix, Dst: dst, Comp: comp, Jump: jump}, nil
+
+}
 
+// Assembler translates parsed commands to instructions. It keeps no state between runs, so an
+// Assembler may be used by several goroutines at once.
+type Assembler struct {
+	logger   *log.Logger
+	isa      *ISA
+	optimize bool
 }
 
+func New() *Assembler {
+	return &Assembler{isa: StandardISA}
+}
+
+// SetISA sets the instruction set of the program, StandardISA by default. It must be called
+// before the assembler is used.
+func (a *Assembler) SetISA(isa *ISA) {
+	a.isa = isa
+}
+
+// SetOptimize makes Build and Assemble optimize the program, see Optimize. It must be called
+// before the assembler is used.
+func (a *Assembler) SetOptimize(optimize bool) {
+	a.optimize = optimize
+}
+
+// SetLogger makes the assembler log the address of every label and variable, it must be called
+// before the assembler is used.
+func (a *Assembler) SetLogger(logger *log.Logger) {
+	a.logger = logger
+}
+
+func (a *Assembler) logf(format string, args ...any) {
+	if a.logger != nil {
+		a.logger.Printf(format, args...)
+	}
+}
+
+// Translate translates commands with a new Assembler.
 func Translate(commands []Command) ([]Instruction, error) {
+	return New().Translate(commands)
+}
+
+func (a *Assembler) Translate(commands []Command) ([]Instruction, error) {
+	res, _, err := a.TranslateWithSymbols(commands)
+	return res, err
+}
+
+// TranslateWithSymbols translates commands like Translate and also returns the addresses the
+// program's labels and variables got. The error is a source.ErrorList of every invalid command:
+// labels declared twice or named like a predefined symbol, jumps to labels that are never
+// declared and operands out of range. A program that doesn't fit in the ROM is a single error
+// instead, since its labels would be out of range too.
+func (a *Assembler) TranslateWithSymbols(commands []Command) ([]Instruction, *Symbols, error) {
+	if n := countInstructions(commands); n > romSize {
+		return nil, nil, fmt.Errorf("program has %d instructions but the ROM only holds %d", n, romSize)
+	}
 	res := make([]Instruction, 0)
+	var errs source.ErrorList
+	symbols := newSymbols()
+	symbolTable := make(map[string]int32, len(predefinedSymbols))
+	for symbol, location := range predefinedSymbols {
+		symbolTable[symbol] = location
+	}
 	//add labels to symbol table
+	declarations := make(map[string]Command)
 	for _, command := range commands {
 		if command.CommandType == LabelDeclarationCommandType {
 			label := command.Tokens[0]
-			_, ok := symbolTable[label]
-			if ok {
-				return res, fmt.Errorf("can't define label %v multiple times", label)
+			if first, ok := declarations[label]; ok {
+				errs.Add(command.errorf(int(command.Column), "label %s is already declared at %s", label, first.Pos()))
+				continue
+			}
+			if _, ok := predefinedSymbols[label]; ok {
+				errs.Add(command.errorf(int(command.Column), "label %s redefines a predefined symbol", label))
+				continue
 			}
-			fmt.Println("label", label, "location", command.MemoryLocation)
+			a.logf("label %s location %d", label, command.MemoryLocation)
+			declarations[label] = command
 			symbolTable[label] = command.MemoryLocation
+			symbols.Labels[label] = command.MemoryLocation
 		}
 	}
 
 	nextMemoryLocation := int32(16)
-	for _, command := range commands {
+	for i, command := range commands {
 		switch command.CommandType {
 		case AInstructionCommandType:
-			token := command.Tokens[0]
-			location, err := strconv.ParseInt(token, 10, 32)
-			if err != nil {
-				// is variable
-				location, ok := symbolTable[token]
+			operand := command.Tokens[0]
+			if _, ok := symbolTable[operand]; !ok && isSymbol(operand) && jumpsTo(commands[i+1:]) {
+				errs.Add(command.errorf(int(command.Column)+1, "undefined label %s", operand))
+				continue
+			}
+			location, err := evaluate(operand, func(symbol string) int32 {
+				location, ok := symbolTable[symbol]
 				if !ok {
+					// is variable
 					location = nextMemoryLocation
-					symbolTable[token] = location
+					symbolTable[symbol] = location
+					symbols.Variables[symbol] = location
 					nextMemoryLocation = nextMemoryLocation + 1
-					fmt.Println("variable", token, "location", command.MemoryLocation)
+					a.logf("variable %s location %d", symbol, location)
 				}
-				i := AInstruction{Location: location}
-				res = append(res, i)
-			} else {
-				i := AInstruction{Location: int32(location)}
-				res = append(res, i)
+				return location
+			})
+			if err != nil {
+				errs.Add(command.errorf(int(command.Column)+1, "%s", err))
+				continue
 			}
+			res = append(res, AInstruction{Location: location})
 
 		case CInstructionCommandType:
-			i, err := buildCInstruction(command)
+			i, err := a.buildCInstruction(command)
 			if err != nil {
-				return res, fmt.Errorf("failed to translate line %d, %v", command.LineNo, err)
+				errs.Add(command.errorf(int(command.Column), "%s", err))
+				continue
 			}
 
 			res = append(res, i)
@@ -279,5 +359,59 @@ func Translate(commands []Command) ([]Instruction, error) {
 		}
 	}
 
-	return res, nil
+	errs.Sort()
+	return res, symbols, errs.Err()
+}
+
+// jumpsTo reports whether the instruction after an A-instruction, the first of next, jumps to
+// the address it loaded. A variable is never jumped to, so its symbol must be a missing label.
+func jumpsTo(next []Command) bool {
+	for _, command := range next {
+		switch command.CommandType {
+		case LabelDeclarationCommandType:
+			continue
+		case CInstructionCommandType:
+			dest, jump := command.Tokens[0], command.Tokens[2]
+			return jump != "" && !strings.Contains(dest, "A")
+		}
+		return false
+	}
+	return false
+}
+
+// Warnings returns the warnings of commands, the labels that are declared but never used.
+func Warnings(commands []Command) source.ErrorList {
+	used := make(map[string]bool)
+	for _, command := range commands {
+		if command.CommandType != AInstructionCommandType {
+			continue
+		}
+		operand := command.Tokens[0]
+		for i := 0; i < len(operand); {
+			if !isSymbolStart(operand[i]) {
+				if operand[i] == '\'' {
+					i += 3
+					continue
+				}
+				for i < len(operand) && operand[i] != '+' && operand[i] != '-' {
+					i++
+				}
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(operand) && isSymbolPart(operand[j]) {
+				j++
+			}
+			used[operand[i:j]] = true
+			i = j
+		}
+	}
+	var warnings source.ErrorList
+	for _, command := range commands {
+		if command.CommandType == LabelDeclarationCommandType && !used[command.Tokens[0]] {
+			warnings.Add(command.errorf(int(command.Column), "label %s is declared but not used", command.Tokens[0]))
+		}
+	}
+	return warnings
 }
@@ -0,0 +1,144 @@
+package assembler
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseCode(t *testing.T, code string) []Command {
+	t.Helper()
+	commands, err := ParseSource(SourceLines("Main.asm", strings.Split(code, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commands
+}
+
+// positions returns the first line of each error of err, its position and message.
+func positions(err error) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if strings.HasPrefix(line, "Main.asm:") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestTranslateWithSymbols(t *testing.T) {
+	commands := parseCode(t, "@i\nM=1\n(LOOP)\n@j\nM=D\n@LOOP\n0;JMP\n@i\nD=M")
+	instructions, symbols, err := New().TranslateWithSymbols(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instructions) != 8 {
+		t.Fatalf("expected 8 instructions, got %d", len(instructions))
+	}
+	if symbols.Labels["LOOP"] != 2 || symbols.Variables["i"] != 16 || symbols.Variables["j"] != 17 {
+		t.Fatalf("expected LOOP at 2, i at 16 and j at 17, got %+v", symbols)
+	}
+	if instructions[4] != (AInstruction{Location: 2}) || instructions[6] != (AInstruction{Location: 16}) {
+		t.Fatalf("expected @LOOP and @i to load 2 and 16, got %v and %v", instructions[4], instructions[6])
+	}
+	expected := CInstruction{Dst: DDestination, Comp: M, Jump: NotJump}
+	if instructions[7] != expected {
+		t.Fatalf("expected %v, got %v", expected, instructions[7])
+	}
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			"duplicate label",
+			"(LOOP)\n@LOOP\n0;JMP\n(LOOP)",
+			[]string{"Main.asm:4:1: label LOOP is already declared at Main.asm:1:1"},
+		},
+		{
+			"predefined label",
+			"(SCREEN)\n@SCREEN\n0;JMP",
+			[]string{"Main.asm:1:1: label SCREEN redefines a predefined symbol"},
+		},
+		{
+			"undefined label",
+			"@END\nD;JGT",
+			[]string{"Main.asm:1:2: undefined label END"},
+		},
+		{
+			"undefined label after a label",
+			"@END\n(HERE)\n0;JMP",
+			[]string{"Main.asm:1:2: undefined label END"},
+		},
+		{
+			"operand out of range",
+			"@SCREEN+16384\nD=A",
+			[]string{"Main.asm:1:2: SCREEN+16384 is 32768, out of the range 0 to 32767"},
+		},
+		{
+			"negative operand",
+			"@1-2\nD=A",
+			[]string{"Main.asm:1:2: 1-2 is -1, out of the range 0 to 32767"},
+		},
+		{
+			"extended computation",
+			"D=D<<",
+			[]string{"Main.asm:1:1: computation D<< needs the extended instruction set"},
+		},
+		{
+			"invalid computation",
+			"D=D+D",
+			[]string{"Main.asm:1:1: invalid computation D+D"},
+		},
+		{
+			"errors in line order",
+			"@END\n0;JMP\n(LOOP)\n@KBD+KBD\n(LOOP)",
+			[]string{
+				"Main.asm:1:2: undefined label END",
+				"Main.asm:4:2: KBD+KBD is 49152, out of the range 0 to 32767",
+				"Main.asm:5:1: label LOOP is already declared at Main.asm:3:1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Translate(parseCode(t, tt.code))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if actual := positions(err); strings.Join(actual, "\n") != strings.Join(tt.expected, "\n") {
+				t.Fatalf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	commands := parseCode(t, "(START)\n(UNUSED)\n@START+1\n0;JMP\n(DATA)\n@'A'\n@DATA\n(AFTER)")
+	warnings := Warnings(commands)
+	expected := []string{
+		"Main.asm:2:1: label UNUSED is declared but not used",
+		"Main.asm:8:1: label AFTER is declared but not used",
+	}
+	if actual := positions(warnings.Err()); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestTranslate_tooLarge(t *testing.T) {
+	code := make([]string, 0, romSize+3)
+	for i := 0; i < romSize; i++ {
+		code = append(code, "D=D+1")
+	}
+	code = append(code, "(END)", "@END", "0;JMP")
+	commands, err := Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Translate(commands)
+	if err == nil || err.Error() != "program has 32770 instructions but the ROM only holds 32768" {
+		t.Fatalf("expected a single ROM size error, got %v", err)
+	}
+}
@@ -2,55 +2,114 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"hack/assembler"
+	"io"
 	"log"
 	"os"
+	"slices"
 )
 
-// read xxx.asm and output xxx.hack
+// read xxx.asm, or the standard input, and output xxx.hack to the standard output or -o
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Please specify the asm file")
-	}
-	inputFilePath := os.Args[1]
-	lines, err := ReadInputFile(inputFilePath)
+	outputPath := flag.String("o", "", "write the machine code to this file instead of the standard output")
+	verbose := flag.Bool("v", false, "log the address of every label and variable to stderr")
+	listingPath := flag.String("listing", "", "write a listing of the address, binary and source line of every instruction to this file")
+	symbolsPath := flag.String("symbols", "", "write the address of every label and variable to this file")
+	optimize := flag.Bool("optimize", false, "remove redundant instructions and report how many were saved to stderr")
+	format := flag.String("format", string(assembler.HackFormat), "output format: hack (text), bin (raw big-endian words), hex (Intel HEX) or mem ($readmemb)")
+	isaName := flag.String("isa", assembler.StandardISA.Name, "instruction set of the program: standard or extended, which adds shifts such as D=D<<")
+	flag.Parse()
+	isa, err := assembler.LookupISA(*isaName)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	commands, err := assembler.Parse(lines)
+	if flag.NArg() > 1 {
+		log.Fatal("Please specify a single asm file, or none to read the standard input")
+	}
+	if !slices.Contains(assembler.OutputFormats, assembler.OutputFormat(*format)) {
+		log.Fatalf("unknown output format %s", *format)
+	}
+	a := assembler.New()
+	a.SetISA(isa)
+	a.SetOptimize(*optimize)
+	if *verbose {
+		a.SetLogger(log.New(os.Stderr, "", 0))
+	}
+	input, file := io.Reader(os.Stdin), ""
+	if inputFilePath := flag.Arg(0); inputFilePath != "" && inputFilePath != "-" {
+		f, err := os.Open(inputFilePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		input, file = f, inputFilePath
+	}
+	program, err := a.Build(file, input)
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, warning := range program.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	if *optimize {
+		fmt.Fprintf(os.Stderr, "optimized: %s\n", program.Stats)
+	}
 
-	instructions, err := assembler.Translate(commands)
-
+	code, err := assembler.OutputBinaryCode(program.Instructions)
 	if err != nil {
 		log.Fatal(err)
 	}
-	code, err := assembler.OutputBinaryCode(instructions)
+	if *listingPath != "" {
+		err = writeFile(*listingPath, func(w io.Writer) error {
+			return assembler.WriteListing(w, program.Commands, code)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *symbolsPath != "" {
+		err = writeFile(*symbolsPath, func(w io.Writer) error {
+			return assembler.WriteSymbols(w, program.Symbols)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	words, err := assembler.OutputWords(program.Instructions)
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, c := range code {
-		fmt.Println(c)
+	writeCode := func(w io.Writer) error {
+		return assembler.WriteOutput(w, assembler.OutputFormat(*format), words)
+	}
+	if *outputPath != "" && *outputPath != "-" {
+		err = writeFile(*outputPath, writeCode)
+	} else {
+		out := bufio.NewWriter(os.Stdout)
+		err = writeCode(out)
+		if err == nil {
+			err = out.Flush()
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
-
 }
 
-func ReadInputFile(inputFilePath string) ([]string, error) {
-	inputFile, err := os.Open(inputFilePath)
-	lines := make([]string, 0)
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
 	if err != nil {
-		return lines, err
+		return err
 	}
-	scanner := bufio.NewScanner(bufio.NewReader(inputFile))
-	for scanner.Scan() {
-		line := scanner.Text()
-		lines = append(lines, line)
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
 	}
-	return lines, nil
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
-
-// parser
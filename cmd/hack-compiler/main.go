@@ -50,7 +50,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		engine := compiler.NewEngine(inputFile)
+		engine := compiler.NewFileEngine(filePath, inputFile)
 		class, err := engine.CompileClass()
 
 		if err != nil {
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hack/assembler"
+	"hack/disassembler"
+	"log"
+	"os"
+)
+
+// read xxx.hack and output its assembly
+func main() {
+	symbolsPath := flag.String("symbols", "", "symbol file written by hack-assembler -symbols, to put the labels back")
+	isaName := flag.String("isa", assembler.StandardISA.Name, "instruction set of the program: standard or extended, which adds shifts")
+	flag.Parse()
+	isa, err := assembler.LookupISA(*isaName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() < 1 {
+		log.Fatal("Please specify the hack file")
+	}
+	inputFile, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	words, err := disassembler.ReadHack(inputFile)
+	inputFile.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var symbols *assembler.Symbols
+	if *symbolsPath != "" {
+		symbolsFile, err := os.Open(*symbolsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		symbols, err = assembler.ReadSymbols(symbolsFile)
+		symbolsFile.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	lines, err := disassembler.DisassembleISA(words, symbols, isa)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
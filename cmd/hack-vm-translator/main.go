@@ -2,13 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"hack/assembler"
+	"hack/compiler/source"
 	"hack/vm/translator"
+	"io"
 	"log"
 	"os"
-	"sort"
+	"os/signal"
+	"path/filepath"
 	"strings"
 )
 
@@ -19,15 +24,16 @@ func buildAsmFileName(input string) string {
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	bootstrap := flag.Bool("bootstrap", false, "whether to bootstrap or not, by default only when Sys.init is defined")
+	shared := flag.Bool("shared", false, "share the call, return and comparison code between call sites")
+	outputPath := flag.String("o", "", "write the assembly to this file, - for the standard output to pipe it to hack-assembler (default <input>.asm)")
+	optimize := flag.Bool("optimize", false, "optimize the VM code and the assembly, reporting what the VM and assembly optimizers saved to stderr")
+	flag.Parse()
+	if flag.NArg() < 1 {
 		log.Fatal("Please specify the vm file")
 	}
-	inputStr := os.Args[len(os.Args)-1]
-	f, err := os.Open(inputStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fileInfo, err := f.Stat()
+	inputStr := flag.Arg(0)
+	fileInfo, err := os.Stat(inputStr)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -44,141 +50,135 @@ func main() {
 				inputFilePaths = append(inputFilePaths, inputStr+"/"+file.Name())
 			}
 		}
-		sort.SliceStable(inputFilePaths, func(i, j int) bool {
-			return strings.HasSuffix(inputFilePaths[i], "/Sys.vm")
-		})
 		outputFileName = buildAsmFileName(inputStr)
 	} else {
 		inputFilePaths = append(inputFilePaths, inputStr)
 		outputFileName = buildAsmFileName(inputStr)
 	}
 
-	output, err := os.Create(outputFileName)
-	w := bufio.NewWriter(output)
-	shouldBootstrap := flag.Bool("bootstrap", false, "whether to bootstrap or not")
-	flag.Parse()
-	if shouldBootstrap != nil && *shouldBootstrap {
-		// TODO: improve it
-		boostrapCmds := []string{
-			"@256",
-			"D=A",
-			"@SP",
-			"M=D",
-		}
-		for _, cmd := range boostrapCmds {
-			_, err = w.WriteString(fmt.Sprintln(cmd))
-			if err != nil {
-				log.Fatal(err)
-			}
+	shouldBootstrap := *bootstrap
+	if !isFlagSet("bootstrap") {
+		shouldBootstrap, err = definesSysInit(inputFilePaths)
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
 
-	ctx := context.Background()
-	asmCh := parsePipeline(ctx, inputFilePaths)
-	writerCompleted := writeFilePipeline(ctx, asmCh, w)
-
-	select {
-	case <-ctx.Done():
-		log.Fatal(ctx.Err())
+	if *outputPath != "" {
+		outputFileName = *outputPath
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// a bootstrapped program is complete, its calls and jumps can be checked
+	opts := translator.Options{Bootstrap: shouldBootstrap, Shared: *shared, Optimize: *optimize, Link: shouldBootstrap}
+	opts.Warn = func(warning *source.Error) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	opts.Optimized = func(stats translator.OptimizeStats) {
+		fmt.Fprintf(os.Stderr, "optimized VM code: %s\n", stats)
+	}
+	switch {
+	case *optimize:
+		// the optimizer needs the whole program
+		var asm bytes.Buffer
+		srcs, closeSources := sources(inputFilePaths)
+		err = translator.Translate(ctx, srcs, &asm, opts)
+		closeSources()
+		if err == nil {
+			err = writeOptimized(outputFileName, strings.Split(asm.String(), "\n"))
+		}
+	case outputFileName == "-":
+		srcs, closeSources := sources(inputFilePaths)
+		err = translator.Translate(ctx, srcs, os.Stdout, opts)
+		closeSources()
+	default:
+		err = translator.TranslateProgram(ctx, inputFilePaths, outputFileName, opts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	case <-writerCompleted:
-		err = w.Flush()
+// sources opens the files at paths for translator.Translate and returns a function closing them,
+// the process exits on error.
+func sources(paths []string) ([]translator.Source, func()) {
+	res := make([]translator.Source, 0, len(paths))
+	files := make([]*os.File, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
 		if err != nil {
 			log.Fatal(err)
 		}
+		files = append(files, f)
+		res = append(res, translator.Source{Name: filepath.Base(path), Reader: f})
 	}
-}
-
-type WritePipelineResult struct {
-	counter int64
-}
-
-func writePipeline(parentCtx context.Context, cmdCh <-chan translator.VmCommand, writerName string, counter int64, asmCh chan<- string) chan WritePipelineResult {
-	ctx, cancel := context.WithCancelCause(parentCtx)
-	writer := translator.NewWriter(writerName, counter)
-	completed := make(chan WritePipelineResult)
-	go func() {
-		for cmd := range cmdCh {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				asms, err := writer.Write(cmd)
-				if err != nil {
-					cancel(err)
-					return
-				}
-				// write vm comment
-				asmCh <- fmt.Sprintf("// %s\n", cmd.String())
-				// write asm
-				for _, a := range asms {
-					asmCh <- a
-				}
-			}
+	return res, func() {
+		for _, f := range files {
+			f.Close()
 		}
-		completed <- WritePipelineResult{counter: writer.Counter()}
-	}()
-	return completed
+	}
 }
 
-func parsePipeline(parentCtx context.Context, inputFilePaths []string) <-chan string {
-	counter := int64(0)
-	ctx, cancel := context.WithCancelCause(parentCtx)
-	asmCh := make(chan string)
-	go func() {
-		for _, inputFilePath := range inputFilePaths {
-			inputFile, err := os.Open(inputFilePath)
+// writeOptimized writes the assembly asm, once optimized, to the file at path or the standard
+// output for -.
+func writeOptimized(path string, asm []string) error {
+	commands, err := assembler.Parse(asm)
+	if err != nil {
+		return err
+	}
+	commands, stats := assembler.Optimize(commands)
+	write := func(output io.Writer) error {
+		w := bufio.NewWriter(output)
+		for _, command := range commands {
+			_, err := w.WriteString(fmt.Sprintln(command))
 			if err != nil {
-				cancel(err)
-				return
+				return err
 			}
-
-			parser := translator.NewParser(inputFile)
-			tokens := strings.Split(inputFilePath, "/")
-			cmdCh := make(chan translator.VmCommand)
-			completed := writePipeline(ctx, cmdCh, tokens[len(tokens)-1], counter, asmCh)
-
-			for parser.HasMoreCommands() {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					err = parser.Advance()
-					if err != nil {
-						cancel(err)
-						return
-					}
-					cmd := parser.CurrentCommand()
-					cmdCh <- cmd
-				}
-			}
-			close(cmdCh)
-			res := <-completed
-			counter = res.counter
 		}
-		close(asmCh)
-	}()
-	return asmCh
+		return w.Flush()
+	}
+	if path == "-" {
+		err = write(os.Stdout)
+	} else {
+		err = translator.WriteFile(path, write)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "optimized assembly: %s\n", stats)
+	return nil
 }
 
-func writeFilePipeline(parentCtx context.Context, asmCh <-chan string, w *bufio.Writer) <-chan struct{} {
-	ctx, cancel := context.WithCancelCause(parentCtx)
-	writerCompleted := make(chan struct{})
-	go func() {
-		for a := range asmCh {
-			select {
-			case <-ctx.Done():
-				return
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-			default:
-				_, err := w.WriteString(fmt.Sprintln(a))
-				if err != nil {
-					cancel(err)
-					return
-				}
+// definesSysInit reports whether one of the files defines Sys.init, the program entry point the
+// bootstrap code calls.
+func definesSysInit(inputFilePaths []string) (bool, error) {
+	for _, inputFilePath := range inputFilePaths {
+		inputFile, err := os.Open(inputFilePath)
+		if err != nil {
+			return false, err
+		}
+		scanner := bufio.NewScanner(inputFile)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "function" && fields[1] == "Sys.init" {
+				inputFile.Close()
+				return true, nil
 			}
 		}
-		writerCompleted <- struct{}{}
-	}()
-	return writerCompleted
+		inputFile.Close()
+		if err := scanner.Err(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
 }
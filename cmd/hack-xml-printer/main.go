@@ -18,7 +18,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	engine := compiler.NewEngine(inputFile)
+	engine := compiler.NewFileEngine(inputFilePath, inputFile)
 	class, err := engine.CompileClass()
 
 	if err != nil {
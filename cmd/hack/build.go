@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"hack/assembler"
+	"hack/compiler"
+	"hack/compiler/source"
+	"hack/vm/translator"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type buildOptions struct {
+	dir    string
+	osDir  string
+	output string
+	// keepVm and keepAsm write the intermediate .vm and .asm files next to the output
+	keepVm  bool
+	keepAsm bool
+	// shared translates with the shared call, return and comparison routines
+	shared bool
+	// optimize runs the VM optimizer before translating and the peephole optimizer on the
+	// assembly
+	optimize bool
+}
+
+// vmFile is a compiled class.
+type vmFile struct {
+	className string
+	code      []byte
+}
+
+func runBuild(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: hack build [flags] <dir>")
+		flags.PrintDefaults()
+	}
+	opts := buildOptions{}
+	flags.StringVar(&opts.output, "o", "", "output .hack file (default <dir>/<dir name>.hack)")
+	flags.StringVar(&opts.osDir, "os", "", "directory of the standard library .jack classes (default the first os directory with a Sys.jack in <dir> or its parents)")
+	flags.BoolVar(&opts.keepVm, "vm", false, "also write the .vm file of every class")
+	flags.BoolVar(&opts.keepAsm, "asm", false, "also write the .asm file")
+	flags.BoolVar(&opts.shared, "shared", true, "share the call, return and comparison code, most programs don't fit in the ROM otherwise")
+	flags.BoolVar(&opts.optimize, "optimize", true, "optimize the VM code, removing the functions Sys.init doesn't use, and the assembly")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("hack build: expect exactly one directory")
+	}
+	opts.dir = flags.Arg(0)
+	if opts.output == "" {
+		abs, err := filepath.Abs(opts.dir)
+		if err != nil {
+			return err
+		}
+		opts.output = filepath.Join(opts.dir, filepath.Base(abs)+".hack")
+	}
+	if opts.osDir == "" {
+		opts.osDir, err = findOsDir(opts.dir)
+		if err != nil {
+			return err
+		}
+	}
+	return build(opts)
+}
+
+// findOsDir returns the first directory named os with a Sys.jack file in dir or its parents, so
+// that a program of the repository builds from any working directory.
+func findOsDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		osDir := filepath.Join(current, "os")
+		if _, err := os.Stat(filepath.Join(osDir, "Sys.jack")); err == nil {
+			return osDir, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("hack build: no os directory with a Sys.jack in %s or its parents, set it with -os", dir)
+		}
+	}
+}
+
+// build compiles every .jack file of opts.dir along with the standard library classes they use,
+// then translates and assembles the whole program into opts.output.
+func build(opts buildOptions) error {
+	paths, err := filepath.Glob(filepath.Join(opts.dir, "*.jack"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s: no .jack file", opts.dir)
+	}
+	if info, err := os.Stat(opts.osDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("hack build: the standard library directory %s doesn't exist", opts.osDir)
+	}
+	files, err := compileFiles(paths)
+	if err != nil {
+		return err
+	}
+	files, err = linkLibrary(files, opts.osDir)
+	if err != nil {
+		return err
+	}
+
+	if opts.keepVm {
+		for _, file := range files {
+			err = os.WriteFile(filepath.Join(filepath.Dir(opts.output), file.className+".vm"), file.code, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	asm, err := translate(files, opts.shared, opts.optimize)
+	if err != nil {
+		return err
+	}
+	asmPath := strings.TrimSuffix(opts.output, filepath.Ext(opts.output)) + ".asm"
+	if opts.keepAsm {
+		err = os.WriteFile(asmPath, asm, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	a := assembler.New()
+	a.SetOptimize(opts.optimize)
+	err = translator.WriteFile(opts.output, func(w io.Writer) error {
+		return a.Assemble("", bytes.NewReader(asm), w, assembler.HackFormat)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(asmPath), err)
+	}
+	return nil
+}
+
+// compileFiles compiles every file of paths, the syntax errors of all of them are returned
+// together as a source.ErrorList.
+func compileFiles(paths []string) ([]vmFile, error) {
+	files := make([]vmFile, 0, len(paths))
+	var errs source.ErrorList
+	for _, path := range paths {
+		file, err := compileFile(path)
+		var list source.ErrorList
+		if errors.As(err, &list) {
+			errs = append(errs, list...)
+			continue
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+	return files, errs.Err()
+}
+
+func compileFile(path string) (vmFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return vmFile{}, err
+	}
+	defer f.Close()
+	class, err := compiler.NewFileEngine(path, f).CompileClass()
+	if err != nil {
+		return vmFile{}, err
+	}
+	var code bytes.Buffer
+	err = compiler.NewVmWriter(&code, class).Write()
+	if err != nil {
+		return vmFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return vmFile{className: class.Name().Name(), code: code.Bytes()}, nil
+}
+
+// linkLibrary adds to files the classes of osDir they call, directly or not. Sys is always
+// linked since the bootstrap code calls Sys.init. A class of files replaces the library class
+// of the same name.
+func linkLibrary(files []vmFile, osDir string) ([]vmFile, error) {
+	defined := make(map[string]bool)
+	for _, file := range files {
+		defined[file.className] = true
+	}
+
+	// callers is the file of the first call to each class, to report undefined classes
+	callers := map[string]string{"Sys": "bootstrap"}
+	pending := []string{"Sys"}
+	for i := 0; i < len(files) || len(pending) > 0; {
+		if len(pending) == 0 {
+			for _, className := range calledClasses(files[i].code) {
+				if _, ok := callers[className]; !ok {
+					callers[className] = files[i].className + ".vm"
+					pending = append(pending, className)
+				}
+			}
+			i++
+			continue
+		}
+		className := pending[0]
+		pending = pending[1:]
+		if defined[className] {
+			continue
+		}
+		path := filepath.Join(osDir, className+".jack")
+		if _, err := os.Stat(path); err != nil {
+			return files, fmt.Errorf("%s: class %s is not defined in the program nor in %s", callers[className], className, osDir)
+		}
+		file, err := compileFile(path)
+		if err != nil {
+			return files, err
+		}
+		defined[className] = true
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// calledClasses returns the classes of the subroutines called by code, sorted.
+func calledClasses(code []byte) []string {
+	classes := make(map[string]bool)
+	for _, line := range strings.Split(string(code), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "call" {
+			continue
+		}
+		if className, _, ok := strings.Cut(fields[1], "."); ok {
+			classes[className] = true
+		}
+	}
+	res := make([]string, 0, len(classes))
+	for className := range classes {
+		res = append(res, className)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// translate translates files into a single assembly program starting with the bootstrap code.
+func translate(files []vmFile, shared bool, optimize bool) ([]byte, error) {
+	sources := make([]translator.Source, len(files))
+	for i, file := range files {
+		sources[i] = translator.Source{Name: file.className + ".vm", Reader: bytes.NewReader(file.code)}
+	}
+	var asm bytes.Buffer
+	err := translator.Translate(context.Background(), sources, &asm, translator.Options{Bootstrap: true, Shared: shared, Optimize: optimize, Link: true})
+	if err != nil {
+		return nil, err
+	}
+	return asm.Bytes(), nil
+}
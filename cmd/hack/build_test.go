@@ -0,0 +1,79 @@
+package main
+
+import (
+	"hack/cpu"
+	"hack/testscript"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuild_Chapter12 builds the ch12 test programs with the standard library and runs their
+// scripts on the CPU, until the program halts instead of for a number of VM steps.
+func TestBuild_Chapter12(t *testing.T) {
+	for _, name := range []string{"ArrayTest", "MathTest", "MemoryTest"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			err := build(buildOptions{
+				dir:      filepath.Join("../../ch12", name),
+				osDir:    "../../os",
+				output:   filepath.Join(dir, name+".hack"),
+				shared:   true,
+				optimize: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmp, err := os.ReadFile(filepath.Join("../../ch12", name, name+".cmp"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(filepath.Join(dir, name+".cmp"), cmp, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tst, err := os.ReadFile(filepath.Join("../../ch12", name, name+".tst"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			script := strings.Replace(string(tst), "load,", "load "+name+".hack,", 1)
+			script = strings.Replace(script, "repeat 1000000", "repeat", 1)
+			script = strings.Replace(script, "vmstep", "ticktock", 1)
+			s, err := testscript.Parse(strings.NewReader(script))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := testscript.NewRunner(testscript.NewCPUTarget(cpu.New()), dir)
+			r.SetMaxSteps(100000000)
+			err = r.Run(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFindOsDir(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"os", "a/os", "a/b/c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "os", "Sys.jack"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a/os has no Sys.jack, so it's skipped
+	osDir, err := findOsDir(filepath.Join(root, "a/b/c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if osDir != filepath.Join(root, "os") {
+		t.Fatalf("expected %s, got %s", filepath.Join(root, "os"), osDir)
+	}
+}
@@ -1,7 +1,9 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
+	"hack/compiler/source"
 	"io"
 	"strconv"
 	"strings"
@@ -10,14 +12,23 @@ import (
 
 type Engine struct {
 	tokenizer *Tokenizer
+	// errors are the syntax errors recovered from so far
+	errors source.ErrorList
 }
 
 func NewEngine(reader io.Reader) *Engine {
+	return NewFileEngine("", reader)
+}
+
+// NewFileEngine returns an engine whose nodes and errors are positioned in file.
+func NewFileEngine(file string, reader io.Reader) *Engine {
 	return &Engine{
-		tokenizer: NewTokenizer(reader),
+		tokenizer: NewFileTokenizer(file, reader),
 	}
 }
 
+// CompileError is an error at token, rendered as `Main.jack:12:7: message` followed by the
+// source line and a caret under the column.
 type CompileError struct {
 	line    string
 	token   Token
@@ -26,13 +37,33 @@ type CompileError struct {
 }
 
 func (e CompileError) Error() string {
-	if e.err == nil {
-		return fmt.Sprintf("%s line: %s, token: %s", e.message, e.line, e.token)
+	return e.sourceError().Error()
+}
+
+func (e CompileError) Unwrap() error {
+	return e.err
+}
+
+func (e CompileError) Pos() source.Pos {
+	return e.token.Pos()
+}
+
+func (e CompileError) sourceError() *source.Error {
+	message := e.message
+	if e.err != nil {
+		message = e.err.Error()
 	}
-	return fmt.Sprintf("%s line: %s, token: %s", e.err, e.line, e.token)
+	return &source.Error{Pos: e.token.Pos(), Message: message, Source: e.line}
 }
 
-func NewCompileError(err error, line string, token Token, message string) CompileError {
+// NewCompileError returns an error at token, line is the source line of token. An err which
+// already has a position is returned as is, since it's closer to the cause.
+func NewCompileError(err error, line string, token Token, message string) error {
+	var compileError CompileError
+	var sourceError *source.Error
+	if errors.As(err, &compileError) || errors.As(err, &sourceError) {
+		return err
+	}
 	return CompileError{
 		line:    line,
 		token:   token,
@@ -41,7 +72,105 @@ func NewCompileError(err error, line string, token Token, message string) Compil
 	}
 }
 
+func (engine *Engine) newError(err error, token Token, message string) error {
+	return NewCompileError(err, engine.tokenizer.Line(token.Pos().Line), token, message)
+}
+
+// CompileClass compiles a whole class. It recovers from syntax errors to report all of them, so
+// on errors it returns what could be compiled of the class along with a source.ErrorList.
 func (engine *Engine) CompileClass() (Class, error) {
+	engine.errors = nil
+	class, err := engine.compileClass()
+	if err != nil {
+		engine.addError(err)
+	}
+	engine.errors.Sort()
+	return class, engine.errors.Err()
+}
+
+// addError records err, positioned at the last token read unless it already has a position.
+func (engine *Engine) addError(err error) {
+	err = engine.newError(err, engine.tokenizer.currentToken, "")
+	var compileError CompileError
+	var sourceError *source.Error
+	if errors.As(err, &compileError) {
+		sourceError = compileError.sourceError()
+	} else if !errors.As(err, &sourceError) {
+		sourceError = &source.Error{Message: err.Error()}
+	}
+	engine.errors.Add(sourceError)
+}
+
+// nextRecovering returns the next token like Tokenizer.Next, recording the tokenizer errors
+// instead of returning them. The only error returned is io.EOF.
+func (engine *Engine) nextRecovering() (Token, error) {
+	for {
+		token, err := engine.tokenizer.Next()
+		if err == nil || err == io.EOF {
+			return token, err
+		}
+		engine.addError(err)
+	}
+}
+
+var statementKeywords = map[string]bool{
+	"let":    true,
+	"if":     true,
+	"while":  true,
+	"do":     true,
+	"return": true,
+	"var":    true,
+}
+
+// synchronize skips the rest of the statement starting at start, which has a syntax error, depth
+// being the brace depth at start. It stops right after a `;`, or at a `}` or a keyword starting a
+// statement, ignoring those inside the blocks the statement opened, so that an error in a while
+// or if condition skips its whole body.
+func (engine *Engine) synchronize(start Token, depth int) {
+	token := engine.tokenizer.currentToken
+	var err error
+	if token.Pos() == start.Pos() {
+		// nothing was consumed, the statement failed on its first token
+		token, err = engine.nextRecovering()
+	}
+	for err == nil {
+		if token.Type() == SymbolTokenType && token.Content() == "}" && engine.tokenizer.depth < depth {
+			return
+		}
+		if engine.tokenizer.depth == depth {
+			if token.Type() == SymbolTokenType && token.Content() == ";" {
+				engine.nextRecovering()
+				return
+			}
+			if token.Type() == KeywordTokenType && statementKeywords[token.Content()] {
+				return
+			}
+		}
+		token, err = engine.nextRecovering()
+	}
+}
+
+// skipToClassMember skips tokens up to the keyword starting the next classVarDec or
+// subroutineDec, or the `}` closing the class. It returns io.EOF when there's none.
+func (engine *Engine) skipToClassMember() (Token, error) {
+	for {
+		token, err := engine.nextRecovering()
+		if err != nil {
+			return token, err
+		}
+		if token.Type() == SymbolTokenType && token.Content() == "}" && engine.tokenizer.depth == 0 {
+			return token, nil
+		}
+		if token.Type() == KeywordTokenType {
+			switch token.Content() {
+			case "static", "field", "constructor", "function", "method":
+				return token, nil
+			}
+		}
+	}
+}
+
+func (engine *Engine) compileClass() (Class, error) {
 	// TODO
 	//var err error
 	class := Class{
@@ -50,19 +179,20 @@ func (engine *Engine) CompileClass() (Class, error) {
 	}
 	token, err := engine.tokenizer.Next()
 	if err == io.EOF {
-		return class, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return class, engine.newError(err, token, "")
 	} else if err != nil {
-		return class, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return class, engine.newError(err, token, "")
 	} else if token.Type() != KeywordTokenType || token.Content() != "class" {
 		msg := fmt.Sprintf("class must start with `class` but got %s", token.Content())
-		return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return class, engine.newError(nil, token, msg)
 
 	}
+	class.pos = token.Pos()
 
 	token, err = engine.nextToken()
 	if err == io.EOF {
 		msg := fmt.Sprintf("class must start with has a name")
-		return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return class, engine.newError(nil, token, msg)
 	} else if err != nil {
 		return class, err
 	} else if token.Type() != IdentifierTokenType {
@@ -83,63 +213,30 @@ func (engine *Engine) CompileClass() (Class, error) {
 		return class, fmt.Errorf("expecte { after class name but got token type %s with content %s", token.Type(), token.Content())
 	}
 
+	resume := false
 	for {
-		token, err = engine.tokenizer.Next()
-		if err == io.EOF {
-			break
-		}
-
-		if err != nil {
-			return class, err
-		}
-
-		switch token.Type() {
-		case UnknownTokenType:
-			return class, fmt.Errorf("token type is unknow, content: %s", token.content)
-		case KeywordTokenType:
-			if token.Content() == "static" || token.Content() == "field" {
-				// classVarDec -> start with static, or field
-				// should not have classVarDec after subroutineDec is not empty
-				if len(class.subroutineDec) > 0 {
-					return class, fmt.Errorf("classVarDec must declare before subroutineDec")
-				}
+		if !resume {
+			token, err = engine.tokenizer.Next()
+			if err == io.EOF {
+				break
+			}
 
-				classVarDec, err := engine.CompileClassVarDec()
-				if err != nil {
-					return class, err
-				}
-				class.varDec = append(class.varDec, classVarDec)
-			} else if token.Content() == "constructor" || token.content == "function" || token.content == "method" {
-				// subroutineDec -> start with constructor,function,or method
-				subroutineDec, err := engine.CompileSubroutineDec()
-				if err != nil {
-					return class, err
-				}
-				class.subroutineDec = append(class.subroutineDec, subroutineDec)
-			} else {
-				msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got symbol with content: %s", token.content)
-				return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			if err != nil {
+				engine.addError(err)
+				continue
 			}
+		}
+		resume = false
 
-		case SymbolTokenType:
-			if token.Content() == "}" {
+		err = engine.compileClassMember(&class, token)
+		if err != nil {
+			// skip to the next classVarDec or subroutineDec, to report their errors too
+			engine.addError(err)
+			token, err = engine.skipToClassMember()
+			if err == io.EOF {
 				break
 			}
-
-			msg := fmt.Sprintf("expected '}', but got symbol with content: %s", token.content)
-			return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
-		case IntegerConstantTokenType:
-			msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got integerConstant with content: %s", token.content)
-			return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
-		case StringConstantTokenType:
-			msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got stringConstant with content: %s", token.content)
-			return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
-		case IdentifierTokenType:
-			msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got identifier with content: %s", token.content)
-			return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
-		default:
-			msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got content: %s", token.content)
-			return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			resume = true
 		}
 	}
 
@@ -151,19 +248,73 @@ func (engine *Engine) CompileClass() (Class, error) {
 	}
 
 	msg := fmt.Sprintf("unexpected token %s after class end", token.Content())
-	return class, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+	return class, engine.newError(nil, token, msg)
+}
+
+// compileClassMember compiles the classVarDec or subroutineDec starting at token into class.
+func (engine *Engine) compileClassMember(class *Class, token Token) error {
+	switch token.Type() {
+	case UnknownTokenType:
+		return fmt.Errorf("token type is unknow, content: %s", token.content)
+	case KeywordTokenType:
+		if token.Content() == "static" || token.Content() == "field" {
+			// classVarDec -> start with static, or field
+			// should not have classVarDec after subroutineDec is not empty
+			if len(class.subroutineDec) > 0 {
+				return fmt.Errorf("classVarDec must declare before subroutineDec")
+			}
+
+			classVarDec, err := engine.CompileClassVarDec()
+			if err != nil {
+				return err
+			}
+			class.varDec = append(class.varDec, classVarDec)
+		} else if token.Content() == "constructor" || token.content == "function" || token.content == "method" {
+			// subroutineDec -> start with constructor,function,or method
+			subroutineDec, err := engine.CompileSubroutineDec()
+			if err != nil {
+				return err
+			}
+			class.subroutineDec = append(class.subroutineDec, subroutineDec)
+		} else {
+			msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got symbol with content: %s", token.content)
+			return engine.newError(nil, token, msg)
+		}
+
+	case SymbolTokenType:
+		if token.Content() == "}" {
+			return nil
+		}
+
+		msg := fmt.Sprintf("expected '}', but got symbol with content: %s", token.content)
+		return engine.newError(nil, token, msg)
+	case IntegerConstantTokenType:
+		msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got integerConstant with content: %s", token.content)
+		return engine.newError(nil, token, msg)
+	case StringConstantTokenType:
+		msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got stringConstant with content: %s", token.content)
+		return engine.newError(nil, token, msg)
+	case IdentifierTokenType:
+		msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got identifier with content: %s", token.content)
+		return engine.newError(nil, token, msg)
+	default:
+		msg := fmt.Sprintf("expected classVarDec or subroutineDec, but got content: %s", token.content)
+		return engine.newError(nil, token, msg)
+	}
+	return nil
 }
 
 func (engine *Engine) CompileClassVarDec() (ClassVarDec, error) {
 	classVarDec := ClassVarDec{}
 	token, err := engine.tokenizer.Current()
 	if err != nil {
-		return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return classVarDec, engine.newError(err, token, "")
 	}
 	if token.Type() != KeywordTokenType {
 		msg := fmt.Sprintf("expect keyword but got %s when parsing ClassVarDec", token.Type())
-		return classVarDec, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return classVarDec, engine.newError(nil, token, msg)
 	}
+	classVarDec.pos = token.Pos()
 	// (`static`|`field`) type varName (`,` varName)* `;`
 	switch token.Content() {
 	case "static":
@@ -172,26 +323,26 @@ func (engine *Engine) CompileClassVarDec() (ClassVarDec, error) {
 		classVarDec.scope = FieldClassVarScope
 	default:
 		msg := fmt.Sprintf("expect a `static` or a `field` but got %s when parsing ClassVarDec", token.Content())
-		return classVarDec, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return classVarDec, engine.newError(nil, token, msg)
 	}
 
 	token, err = engine.tokenizer.Next()
 	if err != nil {
-		return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return classVarDec, engine.newError(err, token, "")
 	}
 	typee, err := compileType(token)
 	if err != nil {
-		return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return classVarDec, engine.newError(err, token, "")
 	}
 	classVarDec.typee = typee
 
 	token, err = engine.tokenizer.Next()
 	if err != nil {
-		return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return classVarDec, engine.newError(err, token, "")
 	}
 	varName, err := BuildVarName(token)
 	if err != nil {
-		return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return classVarDec, engine.newError(err, token, "")
 	}
 	classVarDec.varNames = []VarName{varName}
 
@@ -206,11 +357,11 @@ func (engine *Engine) CompileClassVarDec() (ClassVarDec, error) {
 		if token.Type() == SymbolTokenType && token.Content() == "," {
 			token, err = engine.tokenizer.Next()
 			if err != nil {
-				return classVarDec, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+				return classVarDec, engine.newError(err, token, "")
 			}
 		} else {
 			msg := fmt.Sprintf("expect a `;` or a `,` but got %s when parsing ClassVarDec", token.Content())
-			return classVarDec, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return classVarDec, engine.newError(nil, token, msg)
 		}
 
 		varName, err = BuildVarName(token)
@@ -230,6 +381,7 @@ func (engine *Engine) CompileSubroutineDec() (SubroutineDec, error) {
 		return subroutineDec, err
 	}
 
+	subroutineDec.pos = token.Pos()
 	subroutineType := MethodSubroutineType
 	switch token.Content() {
 	case "constructor":
@@ -249,17 +401,17 @@ func (engine *Engine) CompileSubroutineDec() (SubroutineDec, error) {
 	} else if err != nil {
 		return subroutineDec, err
 	}
-	returnType := ReturnType{isVoid: false}
+	returnType := ReturnType{isVoid: false, pos: token.Pos()}
 	if token.Type() == KeywordTokenType {
 		switch token.Content() {
 		case "void":
 			returnType.isVoid = true
 		case "int":
-			returnType.typee = Type{primitiveClassName: "int"}
+			returnType.typee = Type{primitiveClassName: "int", pos: token.Pos()}
 		case "char":
-			returnType.typee = Type{primitiveClassName: "char"}
+			returnType.typee = Type{primitiveClassName: "char", pos: token.Pos()}
 		case "boolean":
-			returnType.typee = Type{primitiveClassName: "boolean"}
+			returnType.typee = Type{primitiveClassName: "boolean", pos: token.Pos()}
 		default:
 			return subroutineDec, fmt.Errorf("expect a return type but got %s", token.Content())
 		}
@@ -268,7 +420,7 @@ func (engine *Engine) CompileSubroutineDec() (SubroutineDec, error) {
 		if err != nil {
 			return subroutineDec, err
 		}
-		returnType.typee = Type{className: className}
+		returnType.typee = Type{className: className, pos: token.Pos()}
 	} else {
 		return subroutineDec, fmt.Errorf("expect a return type but got %s", token.Content())
 	}
@@ -342,6 +494,7 @@ func (engine *Engine) CompileSubroutineBody() (SubroutineBody, error) {
 	if err != nil {
 		return subroutineBody, err
 	}
+	subroutineBody.pos = engine.tokenizer.currentToken.Pos()
 	_, err = engine.nextToken()
 	if err != nil {
 		return subroutineBody, err
@@ -362,9 +515,12 @@ func (engine *Engine) CompileSubroutineBody() (SubroutineBody, error) {
 		if token.Type() == KeywordTokenType && token.content == "var" {
 			// varDecs case
 			// TODO: should not have varDecs case after statements is not empty
+			depth := engine.tokenizer.depth
 			varDec, err := engine.CompileVarDec()
 			if err != nil {
-				return subroutineBody, err
+				engine.addError(err)
+				engine.synchronize(token, depth)
+				continue
 			}
 
 			subroutineBody.varDecs = append(subroutineBody.varDecs, &varDec)
@@ -387,6 +543,9 @@ func (engine *Engine) CompileSubroutineBody() (SubroutineBody, error) {
 // When parsing element* like (statement* or varName*), I decide to let compileXXX return the result once it meet a token it doesn't know how to deal with
 func (engine *Engine) CompileStatements() (Statements, error) {
 	statements := Statements{}
+	if token, err := engine.tokenizer.Current(); err == nil {
+		statements.pos = token.Pos()
+	}
 	for {
 		token, err := engine.tokenizer.Current()
 		if err != nil {
@@ -400,52 +559,36 @@ func (engine *Engine) CompileStatements() (Statements, error) {
 			return statements, nil
 		}
 		shouldNext := true
+		depth := engine.tokenizer.depth
 
+		var statement Statement
 		switch token.Content() {
 		case "let":
-			letStatement, err := engine.CompileLetStatement()
-			if err != nil {
-				return statements, err
-			}
-			statements.statements = append(statements.statements, letStatement)
-
+			statement, err = engine.CompileLetStatement()
 		case "if":
-			ifStatement, err := engine.CompileIfStatement()
-			if err != nil {
-				return statements, err
-			}
-			statements.statements = append(statements.statements, ifStatement)
+			statement, err = engine.CompileIfStatement()
 			shouldNext = false
 		case "while":
-			whileStatement, err := engine.CompileWhileStatement()
-			if err != nil {
-				return statements, err
-			}
-			statements.statements = append(statements.statements, whileStatement)
+			statement, err = engine.CompileWhileStatement()
 		case "do":
-			doStatement, err := engine.CompileDoStatement()
-
-			if err != nil {
-				return statements, err
-			}
-			statements.statements = append(statements.statements, doStatement)
+			statement, err = engine.CompileDoStatement()
 		case "return":
-			returnStatement, err := engine.CompileReturnStatement()
-
-			if err != nil {
-				return statements, err
-			}
-			statements.statements = append(statements.statements, returnStatement)
+			statement, err = engine.CompileReturnStatement()
 		default:
 			return statements, nil
 		}
+		if err != nil {
+			// skip the statement, to report the errors of the following ones too
+			engine.addError(err)
+			engine.synchronize(token, depth)
+			continue
+		}
+		statements.statements = append(statements.statements, statement)
+
 		if shouldNext {
-			_, err = engine.tokenizer.Next()
-			if err != nil {
-				if err == io.EOF {
-					return statements, nil
-				}
-				return statements, err
+			_, err = engine.nextRecovering()
+			if err == io.EOF {
+				return statements, nil
 			}
 		}
 	}
@@ -457,6 +600,7 @@ func (engine *Engine) CompileWhileStatement() (WhileStatement, error) {
 	if err != nil {
 		return statement, err
 	}
+	statement.pos = engine.tokenizer.currentToken.Pos()
 	err = engine.nextAndCheck(Token{tokenType: SymbolTokenType, content: "("})
 	if err != nil {
 		return statement, err
@@ -504,11 +648,9 @@ func (engine *Engine) check(expectedToken Token) error {
 	if err != nil {
 		return err
 	}
-	if token.Type() != expectedToken.Type() {
-		return fmt.Errorf("expected token.type to be %s but got %s", expectedToken.Type(), token.Type())
-	}
-	if token.Content() != expectedToken.Content() {
-		return fmt.Errorf("expected token.content to be %s but got %s", expectedToken.Content(), token.Content())
+	if token.Type() != expectedToken.Type() || token.Content() != expectedToken.Content() {
+		msg := fmt.Sprintf("expected `%s` but got %s", expectedToken.Content(), token.Content())
+		return engine.newError(nil, token, msg)
 	}
 	return nil
 }
@@ -526,19 +668,20 @@ func (engine *Engine) CompileLetStatement() (LetStatement, error) {
 	statement := LetStatement{}
 	token, err := engine.tokenizer.Current()
 	if err != nil {
-		return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return statement, engine.newError(err, token, "")
 	}
 	// let varName ([expression])? = expression;
 
 	if token.Type() != KeywordTokenType || token.Content() != "let" {
 		msg := fmt.Sprintf("expect `let` but got %s when parsing LetStatement", token.Content())
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	}
+	statement.pos = token.Pos()
 
 	token, err = engine.tokenizer.Next()
 	if err == io.EOF {
 		msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	} else if err != nil {
 		return statement, err
 	}
@@ -552,9 +695,9 @@ func (engine *Engine) CompileLetStatement() (LetStatement, error) {
 	token, err = engine.tokenizer.Next()
 	if err == io.EOF {
 		msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	} else if err != nil {
-		return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return statement, engine.newError(err, token, "")
 	}
 
 	// compile [expression]
@@ -562,50 +705,50 @@ func (engine *Engine) CompileLetStatement() (LetStatement, error) {
 		token, err = engine.tokenizer.Next()
 		if err == io.EOF {
 			msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-			return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return statement, engine.newError(nil, token, msg)
 		} else if err != nil {
-			return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return statement, engine.newError(err, token, "")
 		}
 
 		expression, err := engine.CompileExpression()
 		if err != nil {
-			return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return statement, engine.newError(err, token, "")
 		}
 		statement.varNameExpression = &expression
 
 		token, err = engine.tokenizer.Current()
 		if err == io.EOF {
 			msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-			return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return statement, engine.newError(nil, token, msg)
 		} else if err != nil {
-			return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return statement, engine.newError(err, token, "")
 		}
 		if token.Type() != SymbolTokenType || token.Content() != "]" {
 			msg := fmt.Sprintf("expect `]` but got %s when parsing LetStatement", token.Content())
-			return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return statement, engine.newError(nil, token, msg)
 		}
 
 		_, err = engine.tokenizer.Next()
 		if err == io.EOF {
 			msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-			return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return statement, engine.newError(nil, token, msg)
 		} else if err != nil {
-			return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return statement, engine.newError(err, token, "")
 		}
 	}
 
 	err = engine.check(Token{tokenType: SymbolTokenType, content: "="})
 	if err != nil {
-		return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return statement, engine.newError(err, token, "")
 	}
 
 	// compile expression
 	token, err = engine.tokenizer.Next()
 	if err == io.EOF {
 		msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	} else if err != nil {
-		return statement, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+		return statement, engine.newError(err, token, "")
 	}
 
 	expression, err := engine.CompileExpression()
@@ -621,13 +764,13 @@ func (engine *Engine) CompileLetStatement() (LetStatement, error) {
 	token, err = engine.tokenizer.Current()
 	if err == io.EOF {
 		msg := fmt.Sprintf("reach EOF when parsing LetStatement")
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	} else if err != nil {
 		return statement, err
 	}
 	if token.Type() != SymbolTokenType || token.Content() != ";" {
 		msg := fmt.Sprintf("expect `;` but got %s when parsing LetStatement", token.Content())
-		return statement, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+		return statement, engine.newError(nil, token, msg)
 	}
 
 	return statement, nil
@@ -644,6 +787,7 @@ func (engine *Engine) CompileReturnStatement() (ReturnStatement, error) {
 	if token.Type() != KeywordTokenType || token.Content() != "return" {
 		return returnStatement, fmt.Errorf("expect `return` got %s when parsing ReturnStatement", token.Content())
 	}
+	returnStatement.pos = token.Pos()
 
 	token, err = engine.tokenizer.Next()
 	if err != nil {
@@ -673,6 +817,7 @@ func (engine *Engine) CompileDoStatement() (DoStatement, error) {
 	if token.Type() != KeywordTokenType || token.Content() != "do" {
 		return doStatement, fmt.Errorf("expect `do` got %s when parsing DoStatement", token.Content())
 	}
+	doStatement.pos = token.Pos()
 
 	token, err = engine.tokenizer.Next()
 	if err != nil {
@@ -711,6 +856,7 @@ func (engine *Engine) CompileIfStatement() (IfStatement, error) {
 	if token.Type() != KeywordTokenType || token.Content() != "if" {
 		return ifStatement, fmt.Errorf("expect `if` got %s when parsing IfStatement", token.Content())
 	}
+	ifStatement.pos = token.Pos()
 
 	token, err = engine.tokenizer.Next()
 	if err != nil {
@@ -808,6 +954,9 @@ func (engine *Engine) CompileIfStatement() (IfStatement, error) {
 func (engine *Engine) CompileExpressionList() (ExpressionList, error) {
 	// (expression,(`,` expression)*)?
 	expressionList := ExpressionList{expressions: make([]Expression, 0)}
+	if token, err := engine.tokenizer.Current(); err == nil {
+		expressionList.pos = token.Pos()
+	}
 	for {
 		token, err := engine.tokenizer.Current()
 
@@ -879,6 +1028,7 @@ func (engine *Engine) CompileExpression() (Expression, error) {
 		return expression, err
 	}
 	expression.leftTerm = &leftTerm
+	expression.pos = leftTerm.pos
 
 	token, err := engine.tokenizer.Current()
 	if err == io.EOF {
@@ -921,6 +1071,7 @@ func (engine *Engine) CompileExpression() (Expression, error) {
 	if i == 0 {
 		return Expression{
 			leftTerm: &terms[0],
+			pos:      terms[0].pos,
 		}, nil
 	}
 
@@ -933,7 +1084,9 @@ func (engine *Engine) CompileExpression() (Expression, error) {
 				leftTerm:  &terms[i],
 				op:        ops[i],
 				rightTerm: previousTerm,
+				pos:       terms[i].pos,
 			},
+			pos: terms[i].pos,
 		}
 		i -= 1
 	}
@@ -983,6 +1136,7 @@ func (engine *Engine) CompileTerm() (Term, error) {
 	if err != nil {
 		return term, err
 	}
+	term.pos = token.Pos()
 	shouldNext := true
 	if token.Type() == IntegerConstantTokenType {
 		term.termType = IntegerConstantTermType
@@ -1060,19 +1214,19 @@ func (engine *Engine) CompileTerm() (Term, error) {
 		term.termType = ExpressionTermType
 		_, err = engine.tokenizer.Next()
 		if err != nil {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 		// handle expression
 		expression, err := engine.CompileExpression()
 		if err != nil {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 		term.expression = &expression
 
 		err = engine.check(Token{tokenType: SymbolTokenType, content: ")"})
 		if err != nil {
 			msg := fmt.Sprintf("expect `)` but got %s when parsing Term", token.Content())
-			return term, NewCompileError(nil, engine.tokenizer.CurrentLine(), token, msg)
+			return term, engine.newError(nil, token, msg)
 
 		}
 	} else if token.Type() == SymbolTokenType && (token.Content() == "-" || token.Content() == "~") {
@@ -1080,21 +1234,24 @@ func (engine *Engine) CompileTerm() (Term, error) {
 		// handle unaryOp term
 		unaryOp, err := BuildUnaryOp(token)
 		if err != nil {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 		term.unaryOp = unaryOp
 
 		_, err = engine.tokenizer.Next()
 		if err != nil {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 
 		term2, err := engine.CompileTerm()
 		if err != nil {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 		term.term = &term2
 		shouldNext = false
+	} else {
+		msg := fmt.Sprintf("expected a term but got %s", token.Content())
+		return term, engine.newError(nil, token, msg)
 	}
 
 	if shouldNext {
@@ -1102,7 +1259,7 @@ func (engine *Engine) CompileTerm() (Term, error) {
 		// no need to handle EOF here, for example: if we parse a line `42`, then EOF is still valid case from CompileTerm's POV
 		// the caller can decide it's an error or not according to it's context
 		if err != nil && err != io.EOF {
-			return term, NewCompileError(err, engine.tokenizer.CurrentLine(), token, "")
+			return term, engine.newError(err, token, "")
 		}
 	}
 
@@ -1110,7 +1267,7 @@ func (engine *Engine) CompileTerm() (Term, error) {
 }
 
 func (engine *Engine) CompileSubroutineCall(firstToken Token) (SubroutineCall, error) {
-	subroutineCall := SubroutineCall{}
+	subroutineCall := SubroutineCall{pos: firstToken.Pos()}
 	token, err := engine.tokenizer.Current()
 	if err == io.EOF {
 		return subroutineCall, fmt.Errorf("reach EOF when parsing SubroutineCall")
@@ -1205,6 +1362,7 @@ func (engine *Engine) CompileVarDec() (VarDec, error) {
 	} else if token.Type() != KeywordTokenType || token.Content() != "var" {
 		return varDec, fmt.Errorf("expect `var` but got %s parsing VarDec", token.Content())
 	}
+	varDec.pos = token.Pos()
 
 	token, err = engine.tokenizer.Next()
 	if err == io.EOF {
@@ -1265,6 +1423,9 @@ func (engine *Engine) CompileParameterList() (ParameterList, error) {
 	parameterList := ParameterList{
 		parameters: make([]Parameter, 0),
 	}
+	if token, err := engine.tokenizer.Current(); err == nil {
+		parameterList.pos = token.Pos()
+	}
 
 	for {
 		token, err := engine.tokenizer.Current()
@@ -1312,6 +1473,7 @@ func (engine *Engine) compileParameter() (Parameter, error) {
 	if err != nil {
 		return parameter, err
 	}
+	parameter.pos = token.Pos()
 	// i.e., int a
 	typee, err := compileType(token)
 	if err != nil {
@@ -1336,7 +1498,7 @@ func (engine *Engine) compileParameter() (Parameter, error) {
 }
 
 func compileType(token Token) (Type, error) {
-	res := Type{}
+	res := Type{pos: token.Pos()}
 	if token.Type() == KeywordTokenType {
 		switch token.Content() {
 		case "int":
@@ -1365,6 +1527,11 @@ type Class struct {
 	name          ClassName
 	varDec        []ClassVarDec
 	subroutineDec []SubroutineDec
+	pos           source.Pos
+}
+
+func (c Class) Pos() source.Pos {
+	return c.pos
 }
 
 func (c Class) Name() ClassName {
@@ -1383,6 +1550,11 @@ func (c Class) SubroutineDecs() []SubroutineDec {
 type Type struct {
 	primitiveClassName string
 	className          ClassName
+	pos                source.Pos
+}
+
+func (t Type) Pos() source.Pos {
+	return t.pos
 }
 
 func (t Type) PrimitiveClassName() string {
@@ -1426,6 +1598,11 @@ type ClassVarDec struct {
 	typee    Type
 	scope    ClassVarScope
 	varNames []VarName
+	pos      source.Pos
+}
+
+func (c ClassVarDec) Pos() source.Pos {
+	return c.pos
 }
 
 func (d ClassVarDec) Type() Type {
@@ -1444,6 +1621,10 @@ type ClassName struct {
 	identifier Identifier
 }
 
+func (c ClassName) Pos() source.Pos {
+	return c.identifier.pos
+}
+
 func (n ClassName) Name() string {
 	return n.identifier.Content()
 }
@@ -1484,6 +1665,11 @@ func (t SubroutineType) String() string {
 type ReturnType struct {
 	typee  Type
 	isVoid bool
+	pos    source.Pos
+}
+
+func (r ReturnType) Pos() source.Pos {
+	return r.pos
 }
 
 func (t ReturnType) String() string {
@@ -1508,6 +1694,11 @@ type SubroutineDec struct {
 	name           SubroutineName
 	parameters     ParameterList
 	body           SubroutineBody
+	pos            source.Pos
+}
+
+func (s SubroutineDec) Pos() source.Pos {
+	return s.pos
 }
 
 func (d SubroutineDec) SubroutineType() SubroutineType {
@@ -1531,6 +1722,11 @@ func (d SubroutineDec) Body() SubroutineBody {
 // ((type varName) (',' type varName)*)?
 type ParameterList struct {
 	parameters []Parameter
+	pos        source.Pos
+}
+
+func (p ParameterList) Pos() source.Pos {
+	return p.pos
 }
 
 func (l ParameterList) Parameters() []Parameter {
@@ -1540,6 +1736,11 @@ func (l ParameterList) Parameters() []Parameter {
 type Parameter struct {
 	typee Type
 	name  VarName
+	pos   source.Pos
+}
+
+func (p Parameter) Pos() source.Pos {
+	return p.pos
 }
 
 func (p Parameter) Type() Type {
@@ -1554,6 +1755,11 @@ func (p Parameter) Name() VarName {
 type SubroutineBody struct {
 	varDecs    []*VarDec
 	statements Statements
+	pos        source.Pos
+}
+
+func (s SubroutineBody) Pos() source.Pos {
+	return s.pos
 }
 
 func (b SubroutineBody) VarDecs() []*VarDec {
@@ -1566,6 +1772,11 @@ func (b SubroutineBody) Statements() Statements {
 
 type Statements struct {
 	statements []Statement
+	pos        source.Pos
+}
+
+func (s Statements) Pos() source.Pos {
+	return s.pos
 }
 
 func (s Statements) Statements() []Statement {
@@ -1600,6 +1811,7 @@ func (s StatementType) String() string {
 
 type Statement interface {
 	StatementType() StatementType
+	Pos() source.Pos
 }
 
 // LetStatement 'let' varName ( '[' expression ']')? '=' expression ';'
@@ -1607,6 +1819,11 @@ type LetStatement struct {
 	varName           VarName
 	varNameExpression *Expression
 	expression        *Expression
+	pos               source.Pos
+}
+
+func (l LetStatement) Pos() source.Pos {
+	return l.pos
 }
 
 func (l LetStatement) VarName() VarName {
@@ -1631,6 +1848,11 @@ type IfStatement struct {
 	trueStatements  Statements
 	hasElse         bool
 	falseStatements Statements
+	pos             source.Pos
+}
+
+func (i IfStatement) Pos() source.Pos {
+	return i.pos
 }
 
 func (i IfStatement) Expression() *Expression {
@@ -1655,6 +1877,11 @@ func (i IfStatement) StatementType() StatementType {
 type WhileStatement struct {
 	expression *Expression
 	statements Statements
+	pos        source.Pos
+}
+
+func (w WhileStatement) Pos() source.Pos {
+	return w.pos
 }
 
 func (w WhileStatement) Expression() *Expression {
@@ -1672,6 +1899,11 @@ func (w WhileStatement) StatementType() StatementType {
 // DoStatement 'do' subroutineCall ';'
 type DoStatement struct {
 	subroutineCall SubroutineCall
+	pos            source.Pos
+}
+
+func (d DoStatement) Pos() source.Pos {
+	return d.pos
 }
 
 func (d DoStatement) SubroutineCall() SubroutineCall {
@@ -1685,6 +1917,11 @@ func (d DoStatement) StatementType() StatementType {
 // ReturnStatement 'return' expression? ';'
 type ReturnStatement struct {
 	expression *Expression
+	pos        source.Pos
+}
+
+func (r ReturnStatement) Pos() source.Pos {
+	return r.pos
 }
 
 func (r ReturnStatement) Expression() *Expression {
@@ -1704,6 +1941,11 @@ type Expression struct {
 	leftTerm  *Term
 	op        Op
 	rightTerm *Term
+	pos       source.Pos
+}
+
+func (e Expression) Pos() source.Pos {
+	return e.pos
 }
 
 func (e Expression) LeftTerm() *Term {
@@ -1777,6 +2019,11 @@ type Term struct {
 	subroutineCall  SubroutineCall
 	term            *Term
 	unaryOp         UnaryOp
+	pos             source.Pos
+}
+
+func (t Term) Pos() source.Pos {
+	return t.pos
 }
 
 func (t Term) TermType() TermType {
@@ -1845,6 +2092,11 @@ type SubroutineCall struct {
 	expressionList ExpressionList
 	className      ClassName
 	varName        VarName
+	pos            source.Pos
+}
+
+func (s SubroutineCall) Pos() source.Pos {
+	return s.pos
 }
 
 func (c SubroutineCall) SubroutineName() SubroutineName {
@@ -1873,6 +2125,11 @@ func (c SubroutineCall) String() string {
 // (expression ( ',' expression)* )?
 type ExpressionList struct {
 	expressions []Expression
+	pos         source.Pos
+}
+
+func (e ExpressionList) Pos() source.Pos {
+	return e.pos
 }
 
 func (l ExpressionList) Expressions() []Expression {
@@ -2041,6 +2298,11 @@ func BuildKeywordConstant(token Token) (KeywordConstant, error) {
 type VarDec struct {
 	typee Type
 	names []VarName
+	pos   source.Pos
+}
+
+func (v VarDec) Pos() source.Pos {
+	return v.pos
 }
 
 func (d VarDec) Type() Type {
@@ -2055,6 +2317,10 @@ type SubroutineName struct {
 	identifier Identifier
 }
 
+func (s SubroutineName) Pos() source.Pos {
+	return s.identifier.pos
+}
+
 func (s SubroutineName) Name() string {
 	return s.identifier.Content()
 }
@@ -2080,6 +2346,10 @@ type VarName struct {
 	identifier Identifier
 }
 
+func (v VarName) Pos() source.Pos {
+	return v.identifier.pos
+}
+
 func (v VarName) String() string {
 	return v.Name()
 }
@@ -2099,6 +2369,11 @@ func BuildVarName(token Token) (VarName, error) {
 
 type Identifier struct {
 	content string
+	pos     source.Pos
+}
+
+func (i Identifier) Pos() source.Pos {
+	return i.pos
 }
 
 func (i Identifier) Content() string {
@@ -2110,5 +2385,5 @@ func BuildIdentifier(token Token) (Identifier, error) {
 		return Identifier{}, fmt.Errorf("expect token.type to be Identifier, but got %s", token.Type())
 	}
 
-	return Identifier{content: token.content}, nil
+	return Identifier{content: token.content, pos: token.Pos()}, nil
 }
@@ -1,7 +1,9 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
+	"hack/compiler/source"
 	"io"
 	"strings"
 	"testing"
@@ -248,7 +250,7 @@ func assertSubroutineDec(actual SubroutineDec, expect SubroutineDec, t *testing.
 		t.Fatalf("expect subroutineType to be %s but got %s", expect.subroutineType, actual.subroutineType)
 	}
 	assertParameterList(actual.parameters, expect.parameters, t)
-	if actual.returnType.Type() != expect.returnType.Type() {
+	if actual.returnType.Type().Name() != expect.returnType.Type().Name() {
 		t.Fatalf("expect returnType to be %s but got %s", expect.returnType.Type(), actual.returnType.Type())
 	}
 	if actual.returnType.IsVoid() != expect.returnType.IsVoid() {
@@ -1408,3 +1410,164 @@ func TestEngine_CompileWhileStatement(t *testing.T) {
 	}
 	assertWhileStatement(actual, expect, t)
 }
+
+func TestEngine_CompileClass_errors(t *testing.T) {
+	for _, tt := range []struct {
+		code     string
+		expected string
+	}{
+		{
+			"class Main {\n   function void main() {\n\tlet x = 1\n      return;\n   }\n}\n",
+			"Main.jack:4:7: expect `;` but got return when parsing LetStatement\n      return;\n      ^",
+		},
+		{
+			"class Main {\n   function void main() {\n\tdo foo(1 2);\n   }\n}\n",
+			"Main.jack:3:11: expect a `)` but got 2 when parsing SubroutineCall\n\tdo foo(1 2);\n\t         ^",
+		},
+		{
+			"class Main {\n   function void main() {\n      let x = 012;\n   }\n}\n",
+			"Main.jack:3:15: leading zero is not supported\n      let x = 012;\n              ^",
+		},
+	} {
+		_, err := NewFileEngine("Main.jack", strings.NewReader(tt.code)).CompileClass()
+		if err == nil {
+			t.Fatalf("expected error for %q", tt.code)
+		}
+		if err.Error() != tt.expected {
+			t.Fatalf("expected:\n%s\nbut got:\n%s", tt.expected, err)
+		}
+	}
+}
+
+func TestEngine_CompileClass_positions(t *testing.T) {
+	code := "class Main {\n   function int main() {\n      let x = a + f(1);\n      return x;\n   }\n}\n"
+	class, err := NewFileEngine("Main.jack", strings.NewReader(code)).CompileClass()
+	if err != nil {
+		t.Fatalf("expect no err but got %s", err)
+	}
+	subroutineDec := class.SubroutineDecs()[0]
+	statements := subroutineDec.Body().Statements().Statements()
+	let := statements[0].(LetStatement)
+	call := let.Expression().RightTerm().SubroutineCall()
+	for _, tt := range []struct {
+		actual   string
+		expected string
+	}{
+		{class.Pos().String(), "Main.jack:1:1"},
+		{class.Name().Pos().String(), "Main.jack:1:7"},
+		{subroutineDec.Pos().String(), "Main.jack:2:4"},
+		{subroutineDec.ReturnType().Type().Pos().String(), "Main.jack:2:13"},
+		{subroutineDec.Name().Pos().String(), "Main.jack:2:17"},
+		{let.Pos().String(), "Main.jack:3:7"},
+		{let.VarName().Pos().String(), "Main.jack:3:11"},
+		{let.Expression().Pos().String(), "Main.jack:3:15"},
+		{call.Pos().String(), "Main.jack:3:19"},
+		{statements[1].Pos().String(), "Main.jack:4:7"},
+	} {
+		if tt.actual != tt.expected {
+			t.Fatalf("expect position %s but got %s", tt.expected, tt.actual)
+		}
+	}
+}
+
+func TestEngine_CompileClass_recovery(t *testing.T) {
+	code := `class Main {
+   field int x
+   field int y;
+
+   function void main() {
+      var int a;
+      let a = 1
+      do foo(1 2);
+      let a = #;
+      let a = a + 1;
+      return;
+   }
+
+   method void g( {
+      return;
+   }
+
+   method void h() {
+      let y = 2;
+      return;
+   }
+}
+`
+	class, err := NewFileEngine("Main.jack", strings.NewReader(code)).CompileClass()
+	var list source.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected source.ErrorList but got %v", err)
+	}
+	expected := []string{"Main.jack:3:4", "Main.jack:8:7", "Main.jack:8:16", "Main.jack:9:15", "Main.jack:14:19"}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors but got %d:\n%s", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		if e.Pos.String() != expected[i] {
+			t.Fatalf("error %d: expected at %s but got %s", i, expected[i], e)
+		}
+	}
+
+	subroutineDecs := class.SubroutineDecs()
+	if len(subroutineDecs) != 2 {
+		t.Fatalf("expected 2 subroutines but got %d", len(subroutineDecs))
+	}
+	statements := subroutineDecs[0].Body().Statements().Statements()
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements in main but got %d", len(statements))
+	}
+}
+
+// TestEngine_CompileClass_recoveryNested checks an error in a while or if condition skips the
+// whole statement, without closing the subroutine at the `}` of its body.
+func TestEngine_CompileClass_recoveryNested(t *testing.T) {
+	code := `class Main {
+   function void main() {
+      var int x;
+      while (x < ) { let x = x + 1; }
+      if (x) {
+         while (~) {
+            if (x) { let x = 1; } else { let x = 2; }
+         }
+         let x = ;
+      }
+      return;
+   }
+
+   function void f() {
+      let y = 1 2;
+      return;
+   }
+}
+`
+	class, err := NewFileEngine("Main.jack", strings.NewReader(code)).CompileClass()
+	var list source.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected source.ErrorList but got %v", err)
+	}
+	expected := []string{
+		"Main.jack:4:18: expected a term but got )",
+		"Main.jack:6:18: expected a term but got )",
+		"Main.jack:9:18: expected a term but got ;",
+		"Main.jack:15:17: expect `;` but got 2 when parsing LetStatement",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors but got %d:\n%s", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		message := strings.SplitN(e.Error(), "\n", 2)[0]
+		if message != expected[i] {
+			t.Fatalf("error %d: expected %q but got %q", i, expected[i], message)
+		}
+	}
+
+	subroutineDecs := class.SubroutineDecs()
+	if len(subroutineDecs) != 2 {
+		t.Fatalf("expected 2 subroutines but got %d", len(subroutineDecs))
+	}
+	statements := subroutineDecs[0].Body().Statements().Statements()
+	if len(statements) != 2 {
+		t.Fatalf("expected the if and return statements in main but got %d", len(statements))
+	}
+}
@@ -0,0 +1,135 @@
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pos is a position in a source file, Line and Column are 1-based and Column counts runes.
+type Pos struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.File != "" {
+		s = p.File + ":" + s
+	}
+	return s
+}
+
+// Before reports whether p is before q in the same file.
+func (p Pos) Before(q Pos) bool {
+	if p.Line != q.Line {
+		return p.Line < q.Line
+	}
+	return p.Column < q.Column
+}
+
+// Span is the source text from Start up to, but not including, End.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%d:%d", s.Start, s.End.Line, s.End.Column)
+}
+
+// Error is an error at a position. When Source is the line Pos points into, Error renders it
+// followed by a caret under the column.
+type Error struct {
+	Pos     Pos
+	Message string
+	Source  string
+}
+
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	s := fmt.Sprintf("%s: %s", e.Pos, e.Message)
+	if e.Source == "" {
+		return s
+	}
+	return s + "\n" + e.Source + "\n" + caret(e.Source, e.Pos.Column)
+}
+
+// caret returns the line marking column of line, tabs are kept so the caret lines up with the
+// line whatever the tab width.
+func caret(line string, column int) string {
+	var out strings.Builder
+	i := 1
+	for _, r := range line {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		i++
+	}
+	for ; i < column; i++ {
+		out.WriteRune(' ')
+	}
+	out.WriteRune('^')
+	return out.String()
+}
+
+// Errorf returns an *Error at pos, source is the line pos points into and may be empty.
+func Errorf(pos Pos, source string, format string, args ...any) *Error {
+	return &Error{Pos: pos, Message: fmt.Sprintf(format, args...), Source: source}
+}
+
+// ErrorList is every error found in a run, such as all the syntax errors of a file.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Add appends err, unless an error was already added at the same position, or with the same
+// message on the same line: recovering from a syntax error may report it again.
+func (l *ErrorList) Add(err *Error) {
+	for _, e := range *l {
+		if !err.Pos.IsValid() || e.Pos.File != err.Pos.File || e.Pos.Line != err.Pos.Line {
+			continue
+		}
+		if e.Pos.Column == err.Pos.Column || e.Message == err.Message {
+			return
+		}
+	}
+	*l = append(*l, err)
+}
+
+// Sort orders the errors by file and position.
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Pos, l[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Before(b)
+	})
+}
+
+// Err returns l as an error, or nil when it's empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
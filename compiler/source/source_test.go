@@ -0,0 +1,49 @@
+package source
+
+import "testing"
+
+func TestError(t *testing.T) {
+	for _, tt := range []struct {
+		err      *Error
+		expected string
+	}{
+		{
+			Errorf(Pos{File: "Main.jack", Line: 12, Column: 7}, "", "expected ';'"),
+			"Main.jack:12:7: expected ';'",
+		},
+		{
+			Errorf(Pos{File: "Main.jack", Line: 3, Column: 8}, "\tlet x 1;", "expected '='"),
+			"Main.jack:3:8: expected '='\n\tlet x 1;\n\t      ^",
+		},
+		{
+			Errorf(Pos{Line: 1, Column: 5}, "x", "expected ';'"),
+			"1:5: expected ';'\nx\n    ^",
+		},
+		{
+			Errorf(Pos{}, "", "unexpected end of file"),
+			"unexpected end of file",
+		},
+	} {
+		if tt.err.Error() != tt.expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", tt.expected, tt.err.Error())
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	if list.Err() != nil {
+		t.Fatalf("expected nil error for an empty list")
+	}
+	list.Add(Errorf(Pos{File: "Main.jack", Line: 5, Column: 3}, "", "b"))
+	list.Add(Errorf(Pos{File: "Main.jack", Line: 5, Column: 3}, "", "dropped, same position"))
+	list.Add(Errorf(Pos{File: "Main.jack", Line: 5, Column: 7}, "", "b"))
+	list.Add(Errorf(Pos{File: "Main.jack", Line: 5, Column: 9}, "", "d"))
+	list.Add(Errorf(Pos{File: "Main.jack", Line: 2, Column: 1}, "", "a"))
+	list.Add(Errorf(Pos{File: "A.jack", Line: 9, Column: 1}, "", "c"))
+	list.Sort()
+	expected := "A.jack:9:1: c\nMain.jack:2:1: a\nMain.jack:5:3: b\nMain.jack:5:9: d"
+	if list.Err().Error() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, list.Err())
+	}
+}
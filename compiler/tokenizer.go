@@ -3,10 +3,12 @@ package compiler
 import (
 	"bufio"
 	"fmt"
+	"hack/compiler/source"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Tokenizer struct {
@@ -15,13 +17,23 @@ type Tokenizer struct {
 	currentLine  string
 	buffer       string
 	currentToken Token
+	file         string
+	// lines holds every line read so far, untrimmed, for positions and error snippets
+	lines []string
+	// depth is the number of `{` read so far minus the number of `}`
+	depth int
 }
 
 func NewTokenizer(reader io.Reader) *Tokenizer {
+	return NewFileTokenizer("", reader)
+}
+
+// NewFileTokenizer returns a tokenizer whose token positions are in file.
+func NewFileTokenizer(file string, reader io.Reader) *Tokenizer {
 	//r := bufio.NewReader(reader)
 	r := bufio.NewScanner(reader)
 
-	tokenizer := &Tokenizer{reader: r, hasNext: true}
+	tokenizer := &Tokenizer{reader: r, hasNext: true, file: file}
 
 	return tokenizer
 }
@@ -46,6 +58,7 @@ const (
 type Token struct {
 	content   string
 	tokenType TokenType
+	span      source.Span
 }
 
 func NewToken(content string, tokenType TokenType) Token {
@@ -63,6 +76,15 @@ func (t Token) Content() string {
 	return t.content
 }
 
+// Pos returns the position of the first character of the token.
+func (t Token) Pos() source.Pos {
+	return t.span.Start
+}
+
+func (t Token) Span() source.Span {
+	return t.span
+}
+
 func (t TokenType) String() string {
 	switch t {
 	case KeywordTokenType:
@@ -152,8 +174,45 @@ func (t *Tokenizer) CurrentLine() string {
 	return t.currentLine
 }
 
-// Next returns Token{}, and error, if error = io.EOF it reaches the end
+// Line returns the source text of the 1-based line n, or "" when it hasn't been read.
+func (t *Tokenizer) Line(n int) string {
+	if n < 1 || n > len(t.lines) {
+		return ""
+	}
+	return t.lines[n-1]
+}
+
+// pos returns the position of the byte at offset in the buffer of the current line.
+func (t *Tokenizer) pos(buffer string, offset int) source.Pos {
+	text := t.Line(len(t.lines))
+	offset += len(text) - len(buffer)
+	return source.Pos{File: t.file, Line: len(t.lines), Column: utf8.RuneCountInString(text[:offset]) + 1}
+}
+
+// errorf returns an error at offset in the buffer of the current line.
+func (t *Tokenizer) errorf(buffer string, offset int, format string, args ...any) error {
+	return source.Errorf(t.pos(buffer, offset), t.Line(len(t.lines)), format, args...)
+}
+
+// Next returns Token{}, and error, if error = io.EOF it reaches the end. After any other error
+// the rest of the line is skipped, so the next call goes on with the following line.
 func (t *Tokenizer) Next() (Token, error) {
+	token, err := t.next()
+	if err != nil && err != io.EOF {
+		t.buffer = ""
+	}
+	if err == nil && token.Type() == SymbolTokenType {
+		switch token.Content() {
+		case "{":
+			t.depth++
+		case "}":
+			t.depth--
+		}
+	}
+	return token, err
+}
+
+func (t *Tokenizer) next() (Token, error) {
 	if len(t.buffer) > 0 {
 		newBuffer := ""
 		for _, c := range t.buffer {
@@ -176,6 +235,7 @@ func (t *Tokenizer) Next() (Token, error) {
 			t.hasNext = false
 			return Token{}, io.EOF
 		}
+		t.lines = append(t.lines, t.reader.Text())
 		line := ""
 		for _, c := range t.reader.Text() {
 			// handle space line
@@ -202,7 +262,7 @@ func (t *Tokenizer) Next() (Token, error) {
 			} else if strings.HasPrefix(line, "*") {
 				continue
 			} else {
-				return Token{}, fmt.Errorf("invalid line: %s when handling multiple line comment", line)
+				return Token{}, t.errorf(line, 0, "invalid line: %s when handling multiple line comment", line)
 			}
 		}
 
@@ -214,6 +274,8 @@ func (t *Tokenizer) Next() (Token, error) {
 	content := ""
 	isCompleted := false
 	tokenType := UnknownTokenType
+	// start and end are the offsets of the token in buffer, end is exclusive
+	start, end := 0, 0
 
 	i := 0
 	for i < len(buffer) {
@@ -221,6 +283,7 @@ func (t *Tokenizer) Next() (Token, error) {
 		if content == "" && tokenType != StringConstantTokenType {
 			// add anything except space or tab
 			if !isSpace(r) {
+				start = i
 				if isDigit(r) {
 					tokenType = IntegerConstantTokenType
 					content += string(r)
@@ -230,7 +293,7 @@ func (t *Tokenizer) Next() (Token, error) {
 					content += string(r)
 					if _, ok := symbolMap[content]; ok {
 						tokenType = SymbolTokenType
-						buffer = buffer[i+1:]
+						end = i + 1
 						isCompleted = true
 						break
 					}
@@ -241,37 +304,37 @@ func (t *Tokenizer) Next() (Token, error) {
 			if tokenType == IntegerConstantTokenType {
 				if isDigit(r) {
 					if isZero(rune(content[0])) {
-						return Token{}, fmt.Errorf("leading zero is not supported")
+						return Token{}, t.errorf(buffer, start, "leading zero is not supported")
 					}
 					content += string(r)
 					num, err := strconv.ParseInt(content, 10, 32)
 					if err != nil {
-						return Token{}, fmt.Errorf("failed to parse integer, error: %s", err)
+						return Token{}, t.errorf(buffer, start, "failed to parse integer, error: %s", err)
 					}
 					if num > 32767 {
 						// at most 32767
-						return Token{}, fmt.Errorf("integer can't greater than 32767")
+						return Token{}, t.errorf(buffer, start, "integer can't greater than 32767")
 					}
 				} else {
 					if isSpace(r) {
 						isCompleted = true
-						buffer = buffer[i+1:]
+						end = i
 						break
 					} else {
 						if _, ok := symbolMap[string(r)]; ok {
 							isCompleted = true
-							buffer = buffer[i:]
+							end = i
 							break
 						}
 						// TODO: handle symbol!
 						content += string(r)
-						return Token{}, fmt.Errorf("failed to parse %s as integer", content)
+						return Token{}, t.errorf(buffer, start, "failed to parse %s as integer", content)
 					}
 				}
 			} else if tokenType == StringConstantTokenType {
 				if r == '"' {
 					isCompleted = true
-					buffer = buffer[i+1:]
+					end = i + 1
 					break
 				} else {
 					content += string(r)
@@ -280,10 +343,10 @@ func (t *Tokenizer) Next() (Token, error) {
 				// either keyword or identifier
 				if isSpace(r) {
 					isCompleted = true
-					buffer = buffer[i+1:]
+					end = i
 				} else if _, ok := symbolMap[string(r)]; ok {
 					isCompleted = true
-					buffer = buffer[i:]
+					end = i
 				} else {
 					content += string(r)
 				}
@@ -295,7 +358,7 @@ func (t *Tokenizer) Next() (Token, error) {
 						if validateIdentifierFormat(content) {
 							tokenType = IdentifierTokenType
 						} else {
-							return Token{}, fmt.Errorf("failed to parse %s as identifier", content)
+							return Token{}, t.errorf(buffer, start, "failed to parse %s as identifier", content)
 						}
 					}
 					break
@@ -308,7 +371,7 @@ func (t *Tokenizer) Next() (Token, error) {
 	if !isCompleted {
 		// handle valid case first
 		if tokenType == IntegerConstantTokenType {
-			buffer = ""
+			end = len(buffer)
 		} else if tokenType == UnknownTokenType {
 			if _, ok := keywordMap[content]; ok {
 				tokenType = KeywordTokenType
@@ -316,18 +379,19 @@ func (t *Tokenizer) Next() (Token, error) {
 				if validateIdentifierFormat(content) {
 					tokenType = IdentifierTokenType
 				} else {
-					return Token{}, fmt.Errorf("failed to parse %s as identifier", content)
+					return Token{}, t.errorf(buffer, start, "failed to parse %s as identifier", content)
 				}
 			}
-			buffer = ""
+			end = len(buffer)
 		} else {
-			return Token{}, fmt.Errorf("doesn't support multiple-line statement %s", t.currentLine)
+			return Token{}, t.errorf(buffer, start, "doesn't support multiple-line statement %s", t.currentLine)
 		}
 
 	}
-	t.buffer = buffer
+	span := source.Span{Start: t.pos(buffer, start), End: t.pos(buffer, end)}
+	t.buffer = buffer[end:]
 
-	t.currentToken = Token{tokenType: tokenType, content: content}
+	t.currentToken = Token{tokenType: tokenType, content: content, span: span}
 	return t.currentToken, nil
 }
 
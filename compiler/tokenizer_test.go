@@ -22,7 +22,7 @@ func TestTokenizer_Next(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 	}
@@ -66,7 +66,7 @@ func TestTokenizer_Next_case2(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -90,7 +90,7 @@ func TestTokenizer_Next_case3(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -113,7 +113,7 @@ func TestTokenizer_Next_case4(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -136,7 +136,7 @@ func TestTokenizer_Next_case5(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -160,7 +160,7 @@ func TestTokenizer_Next_case6(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -184,7 +184,7 @@ func TestTokenizer_Next_case7(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -214,7 +214,7 @@ func TestTokenizer_Next_case8(t *testing.T) {
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -247,7 +247,7 @@ do Output.printString("(Verify echo and usage of 'backspace')");
 			t.Fatalf("expected %s, but got error: %s", expected, err)
 		}
 
-		if expected != actual {
+		if expected.content != actual.content || expected.tokenType != actual.tokenType {
 			t.Fatalf("expected %s, but got : %s", expected, actual)
 		}
 	}
@@ -257,3 +257,38 @@ do Output.printString("(Verify echo and usage of 'backspace')");
 		t.Fatalf("expected io.EOF, but got: %s", err)
 	}
 }
+
+func TestTokenizer_Next_positions(t *testing.T) {
+	code := "class Main {\n\t// comment\n  let s = \"a b\";\n\tdo f(12);  \n}"
+	tokenizer := NewFileTokenizer("Main.jack", strings.NewReader(code))
+
+	for _, expected := range []struct {
+		content string
+		pos     string
+		end     int
+	}{
+		{"class", "Main.jack:1:1", 6},
+		{"Main", "Main.jack:1:7", 11},
+		{"{", "Main.jack:1:12", 13},
+		{"let", "Main.jack:3:3", 6},
+		{"s", "Main.jack:3:7", 8},
+		{"=", "Main.jack:3:9", 10},
+		{"a b", "Main.jack:3:11", 16},
+		{";", "Main.jack:3:16", 17},
+		{"do", "Main.jack:4:2", 4},
+		{"f", "Main.jack:4:5", 6},
+		{"(", "Main.jack:4:6", 7},
+		{"12", "Main.jack:4:7", 9},
+		{")", "Main.jack:4:9", 10},
+		{";", "Main.jack:4:10", 11},
+		{"}", "Main.jack:5:1", 2},
+	} {
+		actual, err := tokenizer.Next()
+		if err != nil {
+			t.Fatalf("expected %s, but got error: %s", expected.content, err)
+		}
+		if actual.Content() != expected.content || actual.Pos().String() != expected.pos || actual.Span().End.Column != expected.end {
+			t.Fatalf("expected %s at %s ending at %d, but got %s at %s", expected.content, expected.pos, expected.end, actual.Content(), actual.Span())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"hack/compiler/source"
 	"hack/compiler/v2/token"
 	"strings"
 )
@@ -10,6 +11,8 @@ import (
 type AstNode interface {
 	TokenLiteral() string
 	String() string
+	// Pos returns the position where the node starts in the source
+	Pos() source.Pos
 }
 
 type Expression interface {
@@ -54,6 +57,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() source.Pos {
+	return i.Token.Pos()
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -70,6 +77,10 @@ func (f *Field) TokenLiteral() string {
 	return f.Token.Literal
 }
 
+func (f *Field) Pos() source.Pos {
+	return f.Token.Pos()
+}
+
 func (f *Field) String() string {
 	var out bytes.Buffer
 	idents := make([]string, len(f.Identifiers))
@@ -86,6 +97,36 @@ func (f *Field) String() string {
 	return out.String()
 }
 
+type Variable struct {
+	Token       token.Token
+	Type        string
+	Identifiers []*Identifier
+}
+
+func (v *Variable) structureNode() {}
+func (v *Variable) TokenLiteral() string {
+	return v.Token.Literal
+}
+
+func (v *Variable) Pos() source.Pos {
+	return v.Token.Pos()
+}
+
+func (v *Variable) String() string {
+	var out bytes.Buffer
+	idents := make([]string, len(v.Identifiers))
+	for i, identifier := range v.Identifiers {
+		idents[i] = identifier.String()
+	}
+
+	out.WriteString("var ")
+	out.WriteString(v.Type)
+	out.WriteString(" ")
+	out.WriteString(strings.Join(idents, ", "))
+	out.WriteString(";")
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -95,6 +136,10 @@ func (b *BlockStatement) statementNode() {}
 func (b *BlockStatement) TokenLiteral() string {
 	return b.Token.Literal
 }
+
+func (b *BlockStatement) Pos() source.Pos {
+	return b.Token.Pos()
+}
 func (b *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range b.Statements {
@@ -134,6 +179,10 @@ func (p *Parameter) structureNode() {}
 func (p *Parameter) TokenLiteral() string {
 	return p.Token.Literal
 }
+
+func (p *Parameter) Pos() source.Pos {
+	return p.Token.Pos()
+}
 func (p *Parameter) String() string {
 	var out bytes.Buffer
 
@@ -149,6 +198,7 @@ type Subroutine struct {
 	Name       *Identifier
 	ReturnType string
 	Parameters []*Parameter
+	Variables  []*Variable
 	Body       *BlockStatement
 }
 
@@ -156,6 +206,10 @@ func (s *Subroutine) structureNode() {}
 func (s *Subroutine) TokenLiteral() string {
 	return s.Token.Literal
 }
+
+func (s *Subroutine) Pos() source.Pos {
+	return s.Token.Pos()
+}
 func (s *Subroutine) String() string {
 	var output bytes.Buffer
 	params := make([]string, len(s.Parameters))
@@ -171,6 +225,9 @@ func (s *Subroutine) String() string {
 	output.WriteString("(")
 	output.WriteString(strings.Join(params, ", "))
 	output.WriteString("){")
+	for _, variable := range s.Variables {
+		output.WriteString(variable.String())
+	}
 	output.WriteString(s.Body.String())
 	output.WriteString("}")
 
@@ -188,6 +245,10 @@ func (c *Class) structureNode() {}
 func (c *Class) TokenLiteral() string {
 	return c.Token.Literal
 }
+
+func (c *Class) Pos() source.Pos {
+	return c.Token.Pos()
+}
 func (c *Class) String() string {
 	var output bytes.Buffer
 
@@ -218,6 +279,7 @@ type LetStatement struct {
 
 func (l *LetStatement) statementNode()       {}
 func (l *LetStatement) TokenLiteral() string { return l.Token.Literal }
+func (l *LetStatement) Pos() source.Pos      { return l.Token.Pos() }
 func (l *LetStatement) String() string {
 	var output bytes.Buffer
 
@@ -246,6 +308,10 @@ func (s *SubroutineCall) expressionNode() {}
 func (s *SubroutineCall) TokenLiteral() string {
 	return s.Token.Literal
 }
+
+func (s *SubroutineCall) Pos() source.Pos {
+	return s.Token.Pos()
+}
 func (s *SubroutineCall) String() string {
 	var output bytes.Buffer
 
@@ -275,6 +341,10 @@ func (d *DoStatement) statementNode() {}
 func (d *DoStatement) TokenLiteral() string {
 	return d.Token.Literal
 }
+
+func (d *DoStatement) Pos() source.Pos {
+	return d.Token.Pos()
+}
 func (d *DoStatement) String() string {
 	var output bytes.Buffer
 	output.WriteString("do ")
@@ -292,6 +362,10 @@ func (i *IntegerLiteral) expressionNode() {}
 func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
+
+func (i *IntegerLiteral) Pos() source.Pos {
+	return i.Token.Pos()
+}
 func (i *IntegerLiteral) String() string {
 	return fmt.Sprintf("%d", i.Value)
 }
@@ -305,6 +379,10 @@ func (r *ReturnStatement) statementNode() {}
 func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
+
+func (r *ReturnStatement) Pos() source.Pos {
+	return r.Token.Pos()
+}
 func (r *ReturnStatement) String() string {
 	var output bytes.Buffer
 	output.WriteString("return")
@@ -325,6 +403,10 @@ func (t *KeywordConstantLiteral) expressionNode() {}
 func (t *KeywordConstantLiteral) TokenLiteral() string {
 	return t.Token.Literal
 }
+
+func (t *KeywordConstantLiteral) Pos() source.Pos {
+	return t.Token.Pos()
+}
 func (t *KeywordConstantLiteral) String() string {
 	return t.Value
 }
@@ -340,6 +422,10 @@ func (i *IfStatement) statementNode() {}
 func (i *IfStatement) TokenLiteral() string {
 	return i.Token.Literal
 }
+
+func (i *IfStatement) Pos() source.Pos {
+	return i.Token.Pos()
+}
 func (i *IfStatement) String() string {
 	var output bytes.Buffer
 	output.WriteString("if ")
@@ -367,6 +453,10 @@ func (i *InfixExpression) expressionNode() {}
 func (i *InfixExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
+
+func (i *InfixExpression) Pos() source.Pos {
+	return i.Left.Pos()
+}
 func (i *InfixExpression) String() string {
 	var output bytes.Buffer
 	output.WriteString("(")
@@ -387,6 +477,10 @@ func (w *WhileStatement) statementNode() {}
 func (w *WhileStatement) TokenLiteral() string {
 	return w.Token.Literal
 }
+
+func (w *WhileStatement) Pos() source.Pos {
+	return w.Token.Pos()
+}
 func (w *WhileStatement) String() string {
 	var output bytes.Buffer
 	output.WriteString("while ")
@@ -408,6 +502,10 @@ func (p *PrefixExpression) expressionNode() {}
 func (p *PrefixExpression) TokenLiteral() string {
 	return p.Token.Literal
 }
+
+func (p *PrefixExpression) Pos() source.Pos {
+	return p.Token.Pos()
+}
 func (p *PrefixExpression) String() string {
 	var output bytes.Buffer
 	output.WriteString("(")
@@ -427,6 +525,10 @@ func (i *IndexExpression) expressionNode() {}
 func (i *IndexExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
+
+func (i *IndexExpression) Pos() source.Pos {
+	return i.Left.Pos()
+}
 func (i *IndexExpression) String() string {
 	var output bytes.Buffer
 	output.WriteString(i.Left.String())
@@ -445,6 +547,10 @@ func (s *StringLiteral) expressionNode() {}
 func (s *StringLiteral) TokenLiteral() string {
 	return s.Token.Literal
 }
+
+func (s *StringLiteral) Pos() source.Pos {
+	return s.Token.Pos()
+}
 func (s *StringLiteral) String() string {
 	return s.Value
 }
@@ -0,0 +1,354 @@
+package checker
+
+import (
+	"fmt"
+	"hack/compiler/source"
+	"hack/compiler/v2/ast"
+	"hack/compiler/v2/lexer"
+	"hack/compiler/v2/parser"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type classInfo struct {
+	file        string
+	class       *ast.Class
+	subroutines map[string]*ast.Subroutine
+	// library classes only provide signatures, their bodies are not checked
+	library bool
+}
+
+type Checker struct {
+	classes map[string]*classInfo
+	errors  source.ErrorList
+}
+
+func New() *Checker {
+	return &Checker{classes: make(map[string]*classInfo)}
+}
+
+// AddClass adds a class to check, file is only used in error messages.
+func (c *Checker) AddClass(file string, class *ast.Class) {
+	c.addClass(file, class, false)
+}
+
+// AddLibrary adds a class whose subroutines may be called but which isn't checked itself. A class
+// added with AddClass replaces a library class of the same name.
+func (c *Checker) AddLibrary(file string, class *ast.Class) {
+	c.addClass(file, class, true)
+}
+
+func (c *Checker) addClass(file string, class *ast.Class, library bool) {
+	name := class.Identifier.Value
+	if existing, ok := c.classes[name]; ok {
+		if existing.library == library {
+			c.errorf(file, class.Identifier.Pos(), "class %s is already defined in %s", name, existing.file)
+			return
+		}
+		if library {
+			return
+		}
+	}
+
+	info := &classInfo{file: file, class: class, subroutines: make(map[string]*ast.Subroutine), library: library}
+	for _, subroutine := range class.Subroutines {
+		if _, ok := info.subroutines[subroutine.Name.Value]; ok {
+			c.errorf(file, subroutine.Name.Pos(), "subroutine %s.%s is already defined", name, subroutine.Name.Value)
+			continue
+		}
+		info.subroutines[subroutine.Name.Value] = subroutine
+	}
+	c.classes[name] = info
+}
+
+// errorf records an error at pos, whose file is replaced by file since the lexer may not
+// have been given a name.
+func (c *Checker) errorf(file string, pos source.Pos, format string, args ...any) {
+	pos.File = file
+	c.errors = append(c.errors, source.Errorf(pos, "", format, args...))
+}
+
+// Check checks every class added with AddClass, the returned error is a source.ErrorList ordered
+// by position.
+func (c *Checker) Check() error {
+	names := make([]string, 0, len(c.classes))
+	for name := range c.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := c.classes[name]
+		if !info.library {
+			c.checkClass(info)
+		}
+	}
+
+	c.errors.Sort()
+	return c.errors.Err()
+}
+
+type symbol struct {
+	name  string
+	kind  string
+	typee string
+}
+
+type scope struct {
+	info       *classInfo
+	file       string
+	subroutine *ast.Subroutine
+	fields     map[string]symbol
+	locals     map[string]symbol
+}
+
+func (s *scope) lookup(name string) (symbol, bool) {
+	if sym, ok := s.locals[name]; ok {
+		return sym, true
+	}
+	sym, ok := s.fields[name]
+	return sym, ok
+}
+
+func isPrimitive(typee string) bool {
+	return typee == "int" || typee == "char" || typee == "boolean"
+}
+
+func (c *Checker) checkType(file string, pos source.Pos, typee string) {
+	if isPrimitive(typee) {
+		return
+	}
+	if _, ok := c.classes[typee]; !ok {
+		c.errorf(file, pos, "unknown type %s", typee)
+	}
+}
+
+func (c *Checker) checkClass(info *classInfo) {
+	fields := make(map[string]symbol)
+	for _, field := range info.class.Fields {
+		c.checkType(info.file, field.Pos(), field.Type)
+		for _, identifier := range field.Identifiers {
+			if _, ok := fields[identifier.Value]; ok {
+				c.errorf(info.file, identifier.Pos(), "%s is already defined", identifier.Value)
+				continue
+			}
+			fields[identifier.Value] = symbol{name: identifier.Value, kind: field.Scope.String(), typee: field.Type}
+		}
+	}
+
+	for _, subroutine := range info.class.Subroutines {
+		s := &scope{info: info, file: info.file, subroutine: subroutine, fields: fields, locals: make(map[string]symbol)}
+		c.checkSubroutine(s)
+	}
+}
+
+func (c *Checker) checkSubroutine(s *scope) {
+	subroutine := s.subroutine
+	if subroutine.ReturnType != "void" {
+		c.checkType(s.file, subroutine.Pos(), subroutine.ReturnType)
+	}
+	if subroutine.Type == ast.SubroutineTypeConstructor && subroutine.ReturnType != s.info.class.Identifier.Value {
+		c.errorf(s.file, subroutine.Pos(), "constructor %s must return %s", subroutine.Name.Value, s.info.class.Identifier.Value)
+	}
+
+	declare := func(identifier *ast.Identifier, kind string, typee string) {
+		if _, ok := s.locals[identifier.Value]; ok {
+			c.errorf(s.file, identifier.Pos(), "%s is already defined", identifier.Value)
+			return
+		}
+		s.locals[identifier.Value] = symbol{name: identifier.Value, kind: kind, typee: typee}
+	}
+	for _, parameter := range subroutine.Parameters {
+		c.checkType(s.file, parameter.Name.Pos(), parameter.Type)
+		declare(parameter.Name, "argument", parameter.Type)
+	}
+	for _, variable := range subroutine.Variables {
+		c.checkType(s.file, variable.Pos(), variable.Type)
+		for _, identifier := range variable.Identifiers {
+			declare(identifier, "local", variable.Type)
+		}
+	}
+
+	c.checkBlock(s, subroutine.Body)
+}
+
+func (c *Checker) checkBlock(s *scope, block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, statement := range block.Statements {
+		c.checkStatement(s, statement)
+	}
+}
+
+func (c *Checker) checkStatement(s *scope, statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		c.checkVariable(s, statement.Name)
+		if statement.Index != nil {
+			c.checkExpression(s, statement.Index)
+		}
+		c.checkExpression(s, statement.Value)
+	case *ast.IfStatement:
+		c.checkExpression(s, statement.Condition)
+		c.checkBlock(s, statement.Consequence)
+		c.checkBlock(s, statement.Alternative)
+	case *ast.WhileStatement:
+		c.checkExpression(s, statement.Condition)
+		c.checkBlock(s, statement.Body)
+	case *ast.DoStatement:
+		c.checkSubroutineCall(s, statement.SubroutineCall)
+	case *ast.ReturnStatement:
+		void := s.subroutine.ReturnType == "void"
+		if statement.Value != nil {
+			if void {
+				c.errorf(s.file, statement.Pos(), "void subroutine %s can't return a value", s.subroutine.Name.Value)
+			}
+			c.checkExpression(s, statement.Value)
+		} else if !void {
+			c.errorf(s.file, statement.Pos(), "subroutine %s must return a value of type %s", s.subroutine.Name.Value, s.subroutine.ReturnType)
+		}
+	case *ast.BlockStatement:
+		c.checkBlock(s, statement)
+	}
+}
+
+func (c *Checker) checkVariable(s *scope, identifier *ast.Identifier) (symbol, bool) {
+	sym, ok := s.lookup(identifier.Value)
+	if !ok {
+		c.errorf(s.file, identifier.Pos(), "%s is not defined", identifier.Value)
+		return sym, false
+	}
+	if sym.kind == "field" && s.subroutine.Type == ast.SubroutineTypeFunction {
+		c.errorf(s.file, identifier.Pos(), "field %s can't be used in function %s", identifier.Value, s.subroutine.Name.Value)
+	}
+	return sym, true
+}
+
+func (c *Checker) checkExpression(s *scope, expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		c.checkVariable(s, expression)
+	case *ast.KeywordConstantLiteral:
+		if expression.Value == "this" && s.subroutine.Type == ast.SubroutineTypeFunction {
+			c.errorf(s.file, expression.Pos(), "this can't be used in function %s", s.subroutine.Name.Value)
+		}
+	case *ast.IndexExpression:
+		c.checkExpression(s, expression.Left)
+		c.checkExpression(s, expression.Index)
+	case *ast.PrefixExpression:
+		c.checkExpression(s, expression.Left)
+	case *ast.InfixExpression:
+		c.checkExpression(s, expression.Left)
+		c.checkExpression(s, expression.Right)
+	case *ast.SubroutineCall:
+		c.checkSubroutineCall(s, expression)
+	}
+}
+
+func (c *Checker) checkSubroutineCall(s *scope, call *ast.SubroutineCall) {
+	for _, argument := range call.Arguments {
+		c.checkExpression(s, argument)
+	}
+
+	pos := call.SubroutineName.Pos()
+	name := call.SubroutineName.Value
+	var className string
+	// withObject is true when the call has an object, `foo()` inside a method uses this
+	withObject := false
+	switch {
+	case call.CalleeName == nil:
+		className = s.info.class.Identifier.Value
+		withObject = s.subroutine.Type != ast.SubroutineTypeFunction
+	default:
+		if _, ok := s.lookup(call.CalleeName.Value); ok {
+			sym, _ := c.checkVariable(s, call.CalleeName)
+			if isPrimitive(sym.typee) {
+				c.errorf(s.file, pos, "%s of type %s has no subroutine %s", sym.name, sym.typee, name)
+				return
+			}
+			className = sym.typee
+			withObject = true
+		} else {
+			className = call.CalleeName.Value
+		}
+	}
+
+	info, ok := c.classes[className]
+	if !ok {
+		if call.CalleeName != nil && className == call.CalleeName.Value {
+			c.errorf(s.file, call.CalleeName.Pos(), "%s is neither a variable nor a class", className)
+		}
+		// an unknown variable type is reported at its declaration
+		return
+	}
+	subroutine, ok := info.subroutines[name]
+	if !ok {
+		c.errorf(s.file, pos, "subroutine %s.%s is not defined", className, name)
+		return
+	}
+
+	switch {
+	case subroutine.Type == ast.SubroutineTypeMethod && !withObject:
+		if call.CalleeName == nil {
+			c.errorf(s.file, pos, "method %s can't be called from function %s without an object", name, s.subroutine.Name.Value)
+		} else {
+			c.errorf(s.file, pos, "method %s.%s can't be called without an object", className, name)
+		}
+	case subroutine.Type != ast.SubroutineTypeMethod && withObject && call.CalleeName != nil:
+		c.errorf(s.file, pos, "%s %s.%s can't be called on an object", subroutine.Type, className, name)
+	}
+
+	if len(call.Arguments) != len(subroutine.Parameters) {
+		c.errorf(s.file, pos, "%s.%s expects %d arguments but got %d", className, name, len(subroutine.Parameters), len(call.Arguments))
+	}
+}
+
+// ParseFile parses the .jack file at path.
+func ParseFile(path string) (*ast.Class, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	class, err := parser.New(lexer.NewFile(path, f)).ParseClass()
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// CheckDir checks every .jack file in dir, with the classes in libraryDir such as the OS
+// available to them. libraryDir may be empty.
+func CheckDir(dir string, libraryDir string) error {
+	c := New()
+	if libraryDir != "" {
+		paths, err := filepath.Glob(filepath.Join(libraryDir, "*.jack"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			class, err := ParseFile(path)
+			if err != nil {
+				return err
+			}
+			c.AddLibrary(path, class)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jack"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no .jack file in %s", dir)
+	}
+	for _, path := range paths {
+		class, err := ParseFile(path)
+		if err != nil {
+			return err
+		}
+		c.AddClass(path, class)
+	}
+	return c.Check()
+}
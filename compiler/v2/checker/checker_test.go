@@ -0,0 +1,140 @@
+package checker
+
+import (
+	"errors"
+	"hack/compiler/source"
+	"hack/compiler/v2/ast"
+	"hack/compiler/v2/lexer"
+	"hack/compiler/v2/parser"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, code string) *ast.Class {
+	t.Helper()
+	class, err := parser.New(lexer.New(strings.NewReader(code))).ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return class
+}
+
+func TestCheckDir_Programs(t *testing.T) {
+	mains, err := filepath.Glob("../../../ch11/*/Main.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch12, err := filepath.Glob("../../../ch12/*Test/Main.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, main := range append(mains, ch12...) {
+		dir := filepath.Dir(main)
+		err := CheckDir(dir, "../../../os")
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+	}
+}
+
+func TestCheck_Errors(t *testing.T) {
+	code := `class Main {
+   field int size;
+   static Point origin;
+
+   function void main() {
+      var int x;
+      var Foo foo;
+      let y = 1;
+      let size = 2;
+      do draw();
+      do Point.distance(origin);
+      do Point.new(1);
+      do x.foo();
+      do origin.new(1, 2);
+      do Bar.baz();
+      do Point.missing();
+      return 1;
+   }
+
+   method int draw() {
+      do origin.distance(this);
+      return;
+   }
+}
+`
+	point := `class Point {
+   field int x, y;
+
+   constructor Point new(int ax, int ay) {
+      return this;
+   }
+
+   method int distance(Point other) {
+      return 0;
+   }
+}
+`
+	c := New()
+	c.AddClass("Main.jack", parse(t, code))
+	c.AddClass("Point.jack", parse(t, point))
+	err := c.Check()
+	var list source.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected source.ErrorList, got %v", err)
+	}
+
+	expected := []string{
+		"Main.jack:7:7: unknown type Foo",
+		"Main.jack:8:11: y is not defined",
+		"Main.jack:9:11: field size can't be used in function main",
+		"Main.jack:10:10: method draw can't be called from function main without an object",
+		"Main.jack:11:16: method Point.distance can't be called without an object",
+		"Main.jack:12:16: Point.new expects 2 arguments but got 1",
+		"Main.jack:13:12: x of type int has no subroutine foo",
+		"Main.jack:14:17: constructor Point.new can't be called on an object",
+		"Main.jack:15:10: Bar is neither a variable nor a class",
+		"Main.jack:16:16: subroutine Point.missing is not defined",
+		"Main.jack:17:7: void subroutine main can't return a value",
+		"Main.jack:22:7: subroutine draw must return a value of type int",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		if e.Error() != expected[i] {
+			t.Fatalf("error %d: expected %q, got %q", i, expected[i], e.Error())
+		}
+	}
+}
+
+func TestCheck_LibraryOverride(t *testing.T) {
+	library := `class Math {
+   function int abs(int x) {
+      return x;
+   }
+}
+`
+	override := `class Math {
+   function int abs(int x, int y) {
+      return x;
+   }
+}
+`
+	main := `class Main {
+   function void main() {
+      do Math.abs(1, 2);
+      return;
+   }
+}
+`
+	c := New()
+	c.AddLibrary("os/Math.jack", parse(t, library))
+	c.AddClass("Math.jack", parse(t, override))
+	c.AddClass("Main.jack", parse(t, main))
+	err := c.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
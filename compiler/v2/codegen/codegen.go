@@ -0,0 +1,431 @@
+package codegen
+
+import (
+	"bufio"
+	"fmt"
+	"hack/compiler/v2/ast"
+	"io"
+)
+
+type Generator struct {
+	writer            *bufio.Writer
+	className         string
+	classSymbols      *SymbolTable
+	subroutineSymbols *SymbolTable
+	methods           map[string]bool
+	counter           int
+}
+
+func New(writer io.Writer) *Generator {
+	return &Generator{writer: bufio.NewWriter(writer)}
+}
+
+// GenerateClass writes the VM code of class. Labels and code layout follow compiler.VmWriter, so
+// both compilers emit the same code for expressions without chained operators.
+func (g *Generator) GenerateClass(class *ast.Class) error {
+	g.className = class.Identifier.Value
+	g.classSymbols = NewSymbolTable()
+	g.methods = make(map[string]bool)
+	g.counter = 0
+
+	for _, field := range class.Fields {
+		kind := SymbolKindField
+		if field.Scope == ast.FieldScopeStatic {
+			kind = SymbolKindStatic
+		}
+		for _, identifier := range field.Identifiers {
+			err := g.classSymbols.Add(identifier.Value, field.Type, kind)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, subroutine := range class.Subroutines {
+		if subroutine.Type == ast.SubroutineTypeMethod {
+			g.methods[subroutine.Name.Value] = true
+		}
+	}
+
+	for _, subroutine := range class.Subroutines {
+		err := g.generateSubroutine(subroutine)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", g.className, subroutine.Name.Value, err)
+		}
+	}
+	return g.writer.Flush()
+}
+
+func (g *Generator) writeLine(format string, args ...any) error {
+	_, err := fmt.Fprintf(g.writer, format+"\n", args...)
+	return err
+}
+
+func (g *Generator) nextLabel() string {
+	g.counter++
+	return fmt.Sprintf("%s_%d", g.className, g.counter)
+}
+
+func (g *Generator) generateSubroutine(subroutine *ast.Subroutine) error {
+	g.subroutineSymbols = NewSymbolTable()
+	if subroutine.Type == ast.SubroutineTypeMethod {
+		// `this` is always argument 0 of a method
+		err := g.subroutineSymbols.Add("this", g.className, SymbolKindArgument)
+		if err != nil {
+			return err
+		}
+	}
+	for _, parameter := range subroutine.Parameters {
+		err := g.subroutineSymbols.Add(parameter.Name.Value, parameter.Type, SymbolKindArgument)
+		if err != nil {
+			return err
+		}
+	}
+	for _, variable := range subroutine.Variables {
+		for _, identifier := range variable.Identifiers {
+			err := g.subroutineSymbols.Add(identifier.Value, variable.Type, SymbolKindLocal)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := g.writeLine("function %s.%s %d", g.className, subroutine.Name.Value, g.subroutineSymbols.Count(SymbolKindLocal))
+	if err != nil {
+		return err
+	}
+
+	switch subroutine.Type {
+	case ast.SubroutineTypeConstructor:
+		fieldCount := g.classSymbols.Count(SymbolKindField)
+		if fieldCount == 0 {
+			// ensure alloc at least one memory word
+			fieldCount = 1
+		}
+		err = g.writeLine("push constant %d", fieldCount)
+		if err != nil {
+			return err
+		}
+		err = g.writeLine("call Memory.alloc 1")
+		if err != nil {
+			return err
+		}
+		err = g.writeLine("pop pointer 0")
+	case ast.SubroutineTypeMethod:
+		err = g.writeLine("push argument 0")
+		if err != nil {
+			return err
+		}
+		err = g.writeLine("pop pointer 0")
+	}
+	if err != nil {
+		return err
+	}
+
+	return g.generateBlock(subroutine.Body)
+}
+
+func (g *Generator) generateBlock(block *ast.BlockStatement) error {
+	if block == nil {
+		return nil
+	}
+	for _, statement := range block.Statements {
+		err := g.generateStatement(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) generateStatement(statement ast.Statement) error {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return g.generateLetStatement(statement)
+	case *ast.IfStatement:
+		return g.generateIfStatement(statement)
+	case *ast.WhileStatement:
+		return g.generateWhileStatement(statement)
+	case *ast.DoStatement:
+		err := g.generateSubroutineCall(statement.SubroutineCall)
+		if err != nil {
+			return err
+		}
+		return g.writeLine("pop temp 0")
+	case *ast.ReturnStatement:
+		var err error
+		if statement.Value != nil {
+			err = g.generateExpression(statement.Value)
+		} else {
+			err = g.writeLine("push constant 0")
+		}
+		if err != nil {
+			return err
+		}
+		return g.writeLine("return")
+	case *ast.BlockStatement:
+		return g.generateBlock(statement)
+	default:
+		return fmt.Errorf("unknown statement %s", statement)
+	}
+}
+
+func (g *Generator) generateLetStatement(statement *ast.LetStatement) error {
+	err := g.generateExpression(statement.Value)
+	if err != nil {
+		return err
+	}
+	symbol, err := g.symbol(statement.Name.Value)
+	if err != nil {
+		return err
+	}
+	if statement.Index == nil {
+		return g.writeLine("pop %s %d", symbol.Kind.Segment(), symbol.Position)
+	}
+
+	err = g.generateArrayAddress(symbol, statement.Index)
+	if err != nil {
+		return err
+	}
+	return g.writeLine("pop that 0")
+}
+
+// generateArrayAddress points THAT to symbol[index].
+func (g *Generator) generateArrayAddress(symbol Symbol, index ast.Expression) error {
+	err := g.writeLine("push %s %d", symbol.Kind.Segment(), symbol.Position)
+	if err != nil {
+		return err
+	}
+	err = g.generateExpression(index)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("add")
+	if err != nil {
+		return err
+	}
+	return g.writeLine("pop pointer 1")
+}
+
+func (g *Generator) generateIfStatement(statement *ast.IfStatement) error {
+	elseLabel := g.nextLabel()
+	endLabel := g.nextLabel()
+	err := g.generateExpression(statement.Condition)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("not")
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("if-goto %s", elseLabel)
+	if err != nil {
+		return err
+	}
+	err = g.generateBlock(statement.Consequence)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("goto %s", endLabel)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("label %s", elseLabel)
+	if err != nil {
+		return err
+	}
+	err = g.generateBlock(statement.Alternative)
+	if err != nil {
+		return err
+	}
+	return g.writeLine("label %s", endLabel)
+}
+
+func (g *Generator) generateWhileStatement(statement *ast.WhileStatement) error {
+	startLabel := g.nextLabel()
+	endLabel := g.nextLabel()
+	err := g.writeLine("label %s", startLabel)
+	if err != nil {
+		return err
+	}
+	err = g.generateExpression(statement.Condition)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("not")
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("if-goto %s", endLabel)
+	if err != nil {
+		return err
+	}
+	err = g.generateBlock(statement.Body)
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("goto %s", startLabel)
+	if err != nil {
+		return err
+	}
+	return g.writeLine("label %s", endLabel)
+}
+
+func (g *Generator) symbol(name string) (Symbol, error) {
+	if symbol, ok := g.subroutineSymbols.Get(name); ok {
+		return symbol, nil
+	}
+	if symbol, ok := g.classSymbols.Get(name); ok {
+		return symbol, nil
+	}
+	return Symbol{}, fmt.Errorf("symbol not found: %s", name)
+}
+
+func (g *Generator) generateSubroutineCall(call *ast.SubroutineCall) error {
+	argumentSize := len(call.Arguments)
+	var functionName string
+	if call.CalleeName != nil {
+		symbol, err := g.symbol(call.CalleeName.Value)
+		if err == nil {
+			// method call on a variable, the instance is the first argument
+			argumentSize++
+			err = g.writeLine("push %s %d", symbol.Kind.Segment(), symbol.Position)
+			if err != nil {
+				return err
+			}
+			functionName = symbol.Type + "." + call.SubroutineName.Value
+		} else {
+			functionName = call.CalleeName.Value + "." + call.SubroutineName.Value
+		}
+	} else {
+		if g.methods[call.SubroutineName.Value] {
+			argumentSize++
+			err := g.writeLine("push pointer 0")
+			if err != nil {
+				return err
+			}
+		}
+		functionName = g.className + "." + call.SubroutineName.Value
+	}
+
+	for _, argument := range call.Arguments {
+		err := g.generateExpression(argument)
+		if err != nil {
+			return err
+		}
+	}
+	return g.writeLine("call %s %d", functionName, argumentSize)
+}
+
+var operatorCommands = map[string]string{
+	"+": "add",
+	"-": "sub",
+	"*": "call Math.multiply 2",
+	"/": "call Math.divide 2",
+	"&": "and",
+	"|": "or",
+	">": "gt",
+	"<": "lt",
+	"=": "eq",
+}
+
+func (g *Generator) generateExpression(expression ast.Expression) error {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		if expression.Value < 0 || expression.Value > 32767 {
+			return fmt.Errorf("integer constant %d is out of range", expression.Value)
+		}
+		return g.writeLine("push constant %d", expression.Value)
+	case *ast.StringLiteral:
+		return g.generateString(expression.Value)
+	case *ast.KeywordConstantLiteral:
+		return g.generateKeywordConstant(expression.Value)
+	case *ast.Identifier:
+		symbol, err := g.symbol(expression.Value)
+		if err != nil {
+			return err
+		}
+		return g.writeLine("push %s %d", symbol.Kind.Segment(), symbol.Position)
+	case *ast.IndexExpression:
+		identifier, ok := expression.Left.(*ast.Identifier)
+		if !ok {
+			return fmt.Errorf("expected array variable, got %s", expression.Left)
+		}
+		symbol, err := g.symbol(identifier.Value)
+		if err != nil {
+			return err
+		}
+		err = g.generateArrayAddress(symbol, expression.Index)
+		if err != nil {
+			return err
+		}
+		return g.writeLine("push that 0")
+	case *ast.SubroutineCall:
+		return g.generateSubroutineCall(expression)
+	case *ast.PrefixExpression:
+		err := g.generateExpression(expression.Left)
+		if err != nil {
+			return err
+		}
+		switch expression.Operator {
+		case "-":
+			return g.writeLine("neg")
+		case "~":
+			return g.writeLine("not")
+		}
+		return fmt.Errorf("unknown unary operator %s", expression.Operator)
+	case *ast.InfixExpression:
+		command, ok := operatorCommands[expression.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", expression.Operator)
+		}
+		err := g.generateExpression(expression.Left)
+		if err != nil {
+			return err
+		}
+		err = g.generateExpression(expression.Right)
+		if err != nil {
+			return err
+		}
+		return g.writeLine("%s", command)
+	default:
+		return fmt.Errorf("unknown expression %s", expression)
+	}
+}
+
+func (g *Generator) generateString(value string) error {
+	err := g.writeLine("push constant %d", len(value))
+	if err != nil {
+		return err
+	}
+	err = g.writeLine("call String.new 1")
+	if err != nil {
+		return err
+	}
+	for _, c := range value {
+		err = g.writeLine("push constant %d", c)
+		if err != nil {
+			return err
+		}
+		err = g.writeLine("call String.appendChar 2")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) generateKeywordConstant(value string) error {
+	switch value {
+	case "true":
+		err := g.writeLine("push constant 1")
+		if err != nil {
+			return err
+		}
+		return g.writeLine("neg")
+	case "false", "null":
+		return g.writeLine("push constant 0")
+	case "this":
+		return g.writeLine("push pointer 0")
+	}
+	return fmt.Errorf("unknown keyword constant %s", value)
+}
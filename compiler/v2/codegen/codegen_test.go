@@ -0,0 +1,195 @@
+package codegen
+
+import (
+	"bytes"
+	"hack/compiler"
+	"hack/compiler/v2/lexer"
+	"hack/compiler/v2/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func generate(t *testing.T, code string) string {
+	t.Helper()
+	p := parser.New(lexer.New(strings.NewReader(code)))
+	class, err := p.ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = New(&out).GenerateClass(class)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestGenerator_GenerateClass(t *testing.T) {
+	code := `
+class Point {
+   field int x, y;
+   static int count;
+
+   constructor Point new(int ax, int ay) {
+      let x = ax;
+      let y = ay;
+      let count = count + 1;
+      return this;
+   }
+
+   method int distance(Point other) {
+      var int dx, dy;
+      let dx = x - other.getX();
+      if (dx < 0) {
+         let dx = -dx;
+      } else {
+         let dy = ~(dx = 0);
+      }
+      return dx;
+   }
+
+   method int getX() {
+      return x;
+   }
+}
+`
+	expected := `function Point.new 0
+push constant 2
+call Memory.alloc 1
+pop pointer 0
+push argument 0
+pop this 0
+push argument 1
+pop this 1
+push static 0
+push constant 1
+add
+pop static 0
+push pointer 0
+return
+function Point.distance 2
+push argument 0
+pop pointer 0
+push this 0
+push argument 1
+call Point.getX 1
+sub
+pop local 0
+push local 0
+push constant 0
+lt
+not
+if-goto Point_1
+push local 0
+neg
+pop local 0
+goto Point_2
+label Point_1
+push local 0
+push constant 0
+eq
+not
+pop local 1
+label Point_2
+push local 0
+return
+function Point.getX 0
+push argument 0
+pop pointer 0
+push this 0
+return
+`
+	actual := generate(t, code)
+	if actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestGenerator_Precedence(t *testing.T) {
+	code := `
+class Main {
+   function int main(Array a) {
+      return a[1 + 2] - 3 * -4 & 5;
+   }
+}
+`
+	expected := `function Main.main 0
+push argument 0
+push constant 1
+push constant 2
+add
+add
+pop pointer 1
+push that 0
+push constant 3
+push constant 4
+neg
+call Math.multiply 2
+sub
+push constant 5
+and
+return
+`
+	actual := generate(t, code)
+	if actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestGenerator_Errors(t *testing.T) {
+	for _, code := range []string{
+		"class Main { function void main() { let x = 1; return; } }",
+		"class Main { function void main() { var int x, x; return; } }",
+		"class Main { function int main() { return 32768; } }",
+	} {
+		class, err := parser.New(lexer.New(strings.NewReader(code))).ParseClass()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err = New(&out).GenerateClass(class)
+		if err == nil {
+			t.Fatalf("expected error for %s", code)
+		}
+	}
+}
+
+// TestGenerator_Chapter11 compiles every ch11 program with both compilers, and compares with the
+// checked-in .vm files when they exist.
+func TestGenerator_Chapter11(t *testing.T) {
+	paths, err := filepath.Glob("../../../ch11/*/*.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no jack files found")
+	}
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		class, err := compiler.NewEngine(bytes.NewReader(source)).CompileClass()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		var expected bytes.Buffer
+		err = compiler.NewVmWriter(&expected, class).Write()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		actual := generate(t, string(source))
+		if actual != expected.String() {
+			t.Fatalf("%s: expected:\n%s\ngot:\n%s", path, expected.String(), actual)
+		}
+
+		checkedIn, err := os.ReadFile(strings.TrimSuffix(path, ".jack") + ".vm")
+		if err == nil && string(checkedIn) != actual {
+			t.Fatalf("%s: output differs from the checked-in .vm file", path)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package codegen
+
+import "fmt"
+
+type SymbolKind uint8
+
+const (
+	SymbolKindField SymbolKind = iota
+	SymbolKindStatic
+	SymbolKindArgument
+	SymbolKindLocal
+)
+
+// Segment returns the VM segment holding symbols of kind k.
+func (k SymbolKind) Segment() string {
+	switch k {
+	case SymbolKindField:
+		return "this"
+	case SymbolKindStatic:
+		return "static"
+	case SymbolKindArgument:
+		return "argument"
+	case SymbolKindLocal:
+		return "local"
+	default:
+		panic(fmt.Sprintf("unknown symbol kind %d", k))
+	}
+}
+
+type Symbol struct {
+	Name     string
+	Type     string
+	Kind     SymbolKind
+	Position int
+}
+
+type SymbolTable struct {
+	symbols map[string]Symbol
+	counts  map[SymbolKind]int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		symbols: make(map[string]Symbol),
+		counts:  make(map[SymbolKind]int),
+	}
+}
+
+func (t *SymbolTable) Add(name string, symbolType string, kind SymbolKind) error {
+	if _, ok := t.symbols[name]; ok {
+		return fmt.Errorf("symbol already defined: %s", name)
+	}
+	t.symbols[name] = Symbol{Name: name, Type: symbolType, Kind: kind, Position: t.counts[kind]}
+	t.counts[kind]++
+	return nil
+}
+
+func (t *SymbolTable) Get(name string) (Symbol, bool) {
+	symbol, ok := t.symbols[name]
+	return symbol, ok
+}
+
+func (t *SymbolTable) Count(kind SymbolKind) int {
+	return t.counts[kind]
+}
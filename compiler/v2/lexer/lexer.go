@@ -3,6 +3,7 @@ package lexer
 import (
 	"bufio"
 	"bytes"
+	"hack/compiler/source"
 	"hack/compiler/v2/token"
 	"io"
 	"unicode"
@@ -15,10 +16,22 @@ type Lexer struct {
 	currentPosition int
 	peekPosition    int
 	isEOF           bool
+	line            int
+	file            string
+	// lines holds every line read so far, for error snippets
+	lines []string
+	// end is the position right after the last consumed rune
+	end source.Pos
 }
 
 func New(reader io.Reader) *Lexer {
+	return NewFile("", reader)
+}
+
+// NewFile returns a lexer whose token positions are in file.
+func NewFile(file string, reader io.Reader) *Lexer {
 	l := &Lexer{
+		file:            file,
 		scanner:         bufio.NewScanner(reader),
 		currentPosition: 0,
 		peekPosition:    0,
@@ -39,12 +52,15 @@ func (l *Lexer) nextLine() {
 		return
 	}
 	l.currentLine = []rune(l.scanner.Text())
+	l.lines = append(l.lines, l.scanner.Text())
+	l.line++
 	l.peekPosition = 0
 	l.currentPosition = 0
 
 }
 
 func (l *Lexer) nextRune() {
+	l.end = l.pos(l.currentPosition + 2)
 	for l.peekPosition == len(l.currentLine) {
 		l.nextLine()
 		if l.isEOF {
@@ -66,13 +82,37 @@ func (l *Lexer) PeekRune() (rune, bool) {
 	return l.currentLine[l.peekPosition], true
 }
 
+func (l *Lexer) pos(column int) source.Pos {
+	return source.Pos{File: l.file, Line: l.line, Column: column}
+}
+
+// File returns the file name given to NewFile.
+func (l *Lexer) File() string {
+	return l.file
+}
+
+// Line returns the source text of the 1-based line n, or "" when it hasn't been read.
+func (l *Lexer) Line(n int) string {
+	if n < 1 || n > len(l.lines) {
+		return ""
+	}
+	return l.lines[n-1]
+}
+
 func (l *Lexer) NextToken() token.Token {
 	//l.skipWhitespace()
 	l.skipCommentAndWhitespace()
 	if l.isEOF {
-		return token.Token{TokenType: token.TokenTypeEOF, Literal: ""}
+		end := l.pos(len(l.currentLine) + 1)
+		return token.Token{TokenType: token.TokenTypeEOF, Literal: "", Span: source.Span{Start: end, End: end}}
 	}
-	// handle comment
+	start := l.pos(l.currentPosition + 1)
+	tok := l.readToken()
+	tok.Span = source.Span{Start: start, End: l.end}
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 
 	switch l.currentRune {
@@ -174,7 +214,8 @@ func (l *Lexer) NextToken() token.Token {
 	case '"':
 		lit, err := l.readStringLiteral()
 		if err != nil {
-			return token.Token{TokenType: token.TokenTypeIllegal, Literal: "illegal"}
+			// unterminated string
+			return token.Token{TokenType: token.TokenTypeIllegal, Literal: "\""}
 		}
 		tok = token.Token{
 			TokenType: token.TokenTypeStringLiteral,
@@ -197,8 +238,10 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = token.Token{
 				TokenType: token.TokenTypeIllegal,
-				Literal:   "illegal",
+				Literal:   string(l.currentRune),
 			}
+			// skip the rune, so the parser can go on after it
+			l.nextRune()
 		}
 		return tok
 	}
@@ -241,10 +284,14 @@ func (l *Lexer) skipCommentAndWhitespace() {
 				}
 				prev = l.currentRune
 				l.nextRune()
+				if l.isEOF {
+					return
+				}
 			}
 
 		} else {
-
+			// division operator
+			return
 		}
 
 		l.skipWhitespace()
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"hack/compiler/source"
 	"hack/compiler/v2/token"
 	"strings"
 	"testing"
@@ -20,35 +21,50 @@ int a2; // test a2
 int a3;
 /** multi lien format in single line */
 boolean b1;
+a / 2;
 
 `
 
 	reader := strings.NewReader(content)
-	lexer := New(reader)
+	lexer := NewFile("Test.jack", reader)
 
+	// span returns the span of a token on line from column up to end
+	span := func(line, column, end int) source.Span {
+		return source.Span{
+			Start: source.Pos{File: "Test.jack", Line: line, Column: column},
+			End:   source.Pos{File: "Test.jack", Line: line, Column: end},
+		}
+	}
 	for _, expected := range []token.Token{
-		{TokenType: token.TokenTypeThis, Literal: "this"},
-		{TokenType: token.TokenTypeIdentifier, Literal: "is"},
-		{TokenType: token.TokenTypeIntegerLiteral, Literal: "1"},
-		{TokenType: token.TokenTypeStringLiteral, Literal: "test"},
-		{TokenType: token.TokenTypeSemicolon, Literal: ";"},
-		{TokenType: token.TokenTypeInt, Literal: "int"},
-		{TokenType: token.TokenTypeIdentifier, Literal: "a1"},
-		{TokenType: token.TokenTypeSemicolon, Literal: ";"},
-		{TokenType: token.TokenTypeInt, Literal: "int"},
-		{TokenType: token.TokenTypeIdentifier, Literal: "a2"},
-		{TokenType: token.TokenTypeSemicolon, Literal: ";"},
-		{TokenType: token.TokenTypeInt, Literal: "int"},
-		{TokenType: token.TokenTypeIdentifier, Literal: "a3"},
-		{TokenType: token.TokenTypeSemicolon, Literal: ";"},
-		{TokenType: token.TokenTypeBoolean, Literal: "boolean"},
-		{TokenType: token.TokenTypeIdentifier, Literal: "b1"},
-		{TokenType: token.TokenTypeSemicolon, Literal: ";"},
-		{TokenType: token.TokenTypeEOF, Literal: ""},
+		{TokenType: token.TokenTypeThis, Literal: "this", Span: span(2, 1, 5)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "is", Span: span(2, 6, 8)},
+		{TokenType: token.TokenTypeIntegerLiteral, Literal: "1", Span: span(2, 9, 10)},
+		{TokenType: token.TokenTypeStringLiteral, Literal: "test", Span: span(2, 11, 17)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(2, 17, 18)},
+		{TokenType: token.TokenTypeInt, Literal: "int", Span: span(3, 1, 4)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "a1", Span: span(3, 5, 7)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(3, 7, 8)},
+		{TokenType: token.TokenTypeInt, Literal: "int", Span: span(4, 1, 4)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "a2", Span: span(4, 5, 7)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(4, 7, 8)},
+		{TokenType: token.TokenTypeInt, Literal: "int", Span: span(11, 1, 4)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "a3", Span: span(11, 5, 7)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(11, 7, 8)},
+		{TokenType: token.TokenTypeBoolean, Literal: "boolean", Span: span(13, 1, 8)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "b1", Span: span(13, 9, 11)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(13, 11, 12)},
+		{TokenType: token.TokenTypeIdentifier, Literal: "a", Span: span(14, 1, 2)},
+		{TokenType: token.TokenTypeSlash, Literal: "/", Span: span(14, 3, 4)},
+		{TokenType: token.TokenTypeIntegerLiteral, Literal: "2", Span: span(14, 5, 6)},
+		{TokenType: token.TokenTypeSemicolon, Literal: ";", Span: span(14, 6, 7)},
+		{TokenType: token.TokenTypeEOF, Literal: "", Span: span(15, 1, 1)},
 	} {
 		actual := lexer.NextToken()
 		if expected != actual {
-			t.Fatalf("expected %s, but got : %s", expected, actual)
+			t.Fatalf("expected %s at %s, but got : %s at %s", expected, expected.Span, actual, actual.Span)
 		}
 	}
+	if lexer.Line(14) != "a / 2;" {
+		t.Fatalf("expected line 14 to be %q, got %q", "a / 2;", lexer.Line(14))
+	}
 }
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"hack/compiler/source"
 	"hack/compiler/v2/ast"
 	"hack/compiler/v2/lexer"
 	"hack/compiler/v2/token"
@@ -9,9 +11,12 @@ import (
 )
 
 type Parser struct {
-	l              *lexer.Lexer
-	currentToken   token.Token
-	peekToken      token.Token
+	l            *lexer.Lexer
+	errors       source.ErrorList
+	currentToken token.Token
+	peekToken    token.Token
+	// depth is the number of `{` up to the current token minus the number of `}`
+	depth          int
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -57,60 +62,82 @@ func New(lexer *lexer.Lexer) *Parser {
 	return p
 }
 
+// ParseClass parses a class, recovering from syntax errors to report all of them. On errors it
+// returns what could be parsed of the class along with a source.ErrorList.
 func (p *Parser) ParseClass() (*ast.Class, error) {
 	klass := &ast.Class{
 		Fields:      make([]*ast.Field, 0),
 		Subroutines: make([]*ast.Subroutine, 0),
 	}
-	if p.currentTokenIs(token.TokenTypeClass) {
-		klass.Token = p.currentToken
-		p.nextToken()
+	p.errors = nil
+	err := p.parseClass(klass)
+	if err != nil {
+		p.addError(err)
+	}
+	p.errors.Sort()
+	return klass, p.errors.Err()
+}
+
+// parseClass returns the errors it can't recover from, the others are added to p.errors.
+func (p *Parser) parseClass(klass *ast.Class) error {
+	if !p.currentTokenIs(token.TokenTypeClass) {
+		return p.errorf(p.currentToken, "expected 'class', got %s", describe(p.currentToken))
+	}
+	klass.Token = p.currentToken
+	p.nextToken()
+
+	identifier, err := p.parseIdentifier()
+	if err != nil {
+		return err
+	}
+	klass.Identifier = identifier.(*ast.Identifier)
+	err = p.expectPeek(token.TokenTypeLeftBrace)
+	if err != nil {
+		return err
+	}
+	p.nextToken()
 
-		identifier, err := p.parseIdentifier()
+	for p.currentTokenIs(token.TokenTypeStatic) || p.currentTokenIs(token.TokenTypeField) {
+		f, err := p.parseField()
 		if err != nil {
-			return nil, err
-		}
-		klass.Identifier = identifier.(*ast.Identifier)
-		if !p.expectPeek(token.TokenTypeLeftBrace) {
-			return nil, fmt.Errorf("expected peek token to be left brace but found %s", p.currentToken.Literal)
+			p.addError(err)
+			p.skipTo(token.TokenTypeStatic, token.TokenTypeField, token.TokenTypeConstructor, token.TokenTypeFunction, token.TokenTypeMethod)
+			continue
 		}
+		klass.Fields = append(klass.Fields, f)
 		p.nextToken()
+	}
 
-		for p.currentTokenIs(token.TokenTypeStatic) || p.currentTokenIs(token.TokenTypeField) {
-			f, err := p.parseField()
+	for {
+		if p.currentTokenIs(token.TokenTypeConstructor) || p.currentTokenIs(token.TokenTypeFunction) || p.currentTokenIs(token.TokenTypeMethod) {
+			subroutine, err := p.parseSubroutine()
 			if err != nil {
-				return nil, err
-			}
-			klass.Fields = append(klass.Fields, f)
-			p.nextToken()
-		}
-
-		for {
-			if p.currentTokenIs(token.TokenTypeConstructor) || p.currentTokenIs(token.TokenTypeFunction) || p.currentTokenIs(token.TokenTypeMethod) {
-				subroutine, err := p.parseSubroutine()
-				if err != nil {
-					return nil, err
-				}
-				klass.Subroutines = append(klass.Subroutines, subroutine)
-			} else if p.currentTokenIs(token.TokenTypeRightBrace) {
-				break
-			} else {
-				return nil, fmt.Errorf("expected current token to be right brace or subroutine but found %s", p.currentToken.TokenType)
+				p.addError(err)
+				p.skipTo(token.TokenTypeConstructor, token.TokenTypeFunction, token.TokenTypeMethod)
+				continue
 			}
-			p.nextToken()
+			klass.Subroutines = append(klass.Subroutines, subroutine)
+		} else if p.currentTokenIs(token.TokenTypeRightBrace) {
+			break
+		} else if p.currentTokenIs(token.TokenTypeEOF) && len(p.errors) > 0 {
+			// the closing brace was skipped while recovering
+			break
+		} else {
+			p.addError(p.errorf(p.currentToken, "expected subroutine or '}', got %s", describe(p.currentToken)))
+			p.skipTo(token.TokenTypeConstructor, token.TokenTypeFunction, token.TokenTypeMethod)
+			continue
 		}
-
-	} else {
-		return nil, fmt.Errorf("expected start with class token")
+		p.nextToken()
 	}
 
-	return klass, nil
+	return nil
 }
+
 func (p *Parser) parseIdentifier() (ast.Expression, error) {
 	if p.currentTokenIs(token.TokenTypeIdentifier) {
 		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}, nil
 	}
-	return nil, fmt.Errorf("expected current token to be identifier, got %s", p.currentToken.TokenType)
+	return nil, p.errorf(p.currentToken, "expected identifier, got %s", describe(p.currentToken))
 }
 
 func (p *Parser) parseField() (*ast.Field, error) {
@@ -120,7 +147,7 @@ func (p *Parser) parseField() (*ast.Field, error) {
 	} else if p.currentTokenIs(token.TokenTypeField) {
 		f.Scope = ast.FieldScopeInstance
 	} else {
-		return nil, fmt.Errorf("expected field, got %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected 'static' or 'field', got %s", describe(p.currentToken))
 	}
 	p.nextToken()
 
@@ -136,6 +163,10 @@ func (p *Parser) parseField() (*ast.Field, error) {
 		return nil, err
 	}
 	f.Identifiers = identifiers
+	err = p.expectCurrent(token.TokenTypeSemicolon)
+	if err != nil {
+		return nil, err
+	}
 
 	return f, nil
 }
@@ -151,7 +182,7 @@ func (p *Parser) parseType() (string, error) {
 	case token.TokenTypeIdentifier:
 		return p.currentToken.Literal, nil
 	default:
-		return "", fmt.Errorf("expected int, boolean, char or identifier, got %s", p.currentToken.TokenType)
+		return "", p.errorf(p.currentToken, "expected type, got %s", describe(p.currentToken))
 	}
 }
 
@@ -161,16 +192,16 @@ func (p *Parser) parseIdentifiers() ([]*ast.Identifier, error) {
 		idents = append(idents, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
 		p.nextToken()
 	} else {
-		return nil, fmt.Errorf("expected identifier, got %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected identifier, got %s", describe(p.currentToken))
 	}
 
 	for p.currentTokenIs(token.TokenTypeComma) {
-		if p.expectPeek(token.TokenTypeIdentifier) {
-			idents = append(idents, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
-			p.nextToken()
-		} else {
-			return nil, fmt.Errorf("expected peek token to be identifier, got %s", p.peekToken.TokenType)
+		err := p.expectPeek(token.TokenTypeIdentifier)
+		if err != nil {
+			return nil, err
 		}
+		idents = append(idents, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+		p.nextToken()
 	}
 	return idents, nil
 }
@@ -184,7 +215,7 @@ func (p *Parser) parseSubroutine() (*ast.Subroutine, error) {
 	} else if p.currentTokenIs(token.TokenTypeMethod) {
 		subroutine.Type = ast.SubroutineTypeMethod
 	} else {
-		return nil, fmt.Errorf("expected current token to be constructor, function, or method, got %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected 'constructor', 'function' or 'method', got %s", describe(p.currentToken))
 	}
 	p.nextToken()
 
@@ -204,8 +235,9 @@ func (p *Parser) parseSubroutine() (*ast.Subroutine, error) {
 		return nil, err
 	}
 	subroutine.Name = name.(*ast.Identifier)
-	if !p.expectPeek(token.TokenTypeLeftParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be left parenthesis, got %s", p.peekToken.Literal)
+	err = p.expectPeek(token.TokenTypeLeftParenthesis)
+	if err != nil {
+		return nil, err
 	}
 	p.nextToken()
 
@@ -214,14 +246,33 @@ func (p *Parser) parseSubroutine() (*ast.Subroutine, error) {
 		return nil, err
 	}
 	subroutine.Parameters = parameters
-	if !p.currentTokenIs(token.TokenTypeRightParenthesis) {
-		return nil, fmt.Errorf("expected current token to be right parenthesis, got %s", p.currentToken.TokenType)
+	err = p.expectCurrent(token.TokenTypeRightParenthesis)
+	if err != nil {
+		return nil, err
 	}
-	if !p.expectPeek(token.TokenTypeLeftBrace) {
-		return nil, fmt.Errorf("expected peek token to be left brace but found %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeLeftBrace)
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := p.parseBlockStatement()
+	body := &ast.BlockStatement{Token: p.currentToken, Statements: []ast.Statement{}}
+	p.nextToken()
+
+	variables := make([]*ast.Variable, 0)
+	for p.currentTokenIs(token.TokenTypeVar) {
+		depth := p.depth
+		variable, err := p.parseVariable()
+		if err != nil {
+			p.addError(err)
+			p.synchronize(depth)
+			continue
+		}
+		variables = append(variables, variable)
+		p.nextToken()
+	}
+	subroutine.Variables = variables
+
+	err = p.parseStatements(body)
 	if err != nil {
 		return nil, err
 	}
@@ -230,6 +281,30 @@ func (p *Parser) parseSubroutine() (*ast.Subroutine, error) {
 	return subroutine, nil
 }
 
+func (p *Parser) parseVariable() (*ast.Variable, error) {
+	v := &ast.Variable{Token: p.currentToken}
+	p.nextToken()
+
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	v.Type = t
+	p.nextToken()
+
+	identifiers, err := p.parseIdentifiers()
+	if err != nil {
+		return nil, err
+	}
+	v.Identifiers = identifiers
+	err = p.expectCurrent(token.TokenTypeSemicolon)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
 func (p *Parser) parseParameterList() ([]*ast.Parameter, error) {
 	parameters := make([]*ast.Parameter, 0)
 	for !p.currentTokenIs(token.TokenTypeRightParenthesis) {
@@ -253,6 +328,8 @@ func (p *Parser) parseParameterList() ([]*ast.Parameter, error) {
 
 		if p.currentTokenIs(token.TokenTypeComma) {
 			p.nextToken()
+		} else if !p.currentTokenIs(token.TokenTypeRightParenthesis) {
+			return nil, p.errorf(p.currentToken, "expected ',' or ')', got %s", describe(p.currentToken))
 		}
 	}
 	return parameters, nil
@@ -270,7 +347,7 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 	case token.TokenTypeWhile:
 		return p.parseWhileStatement()
 	default:
-		return nil, fmt.Errorf("failed to parse statement with %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected statement, got %s", describe(p.currentToken))
 	}
 }
 
@@ -278,8 +355,9 @@ func (p *Parser) parseWhileStatement() (ast.Statement, error) {
 	statement := &ast.WhileStatement{
 		Token: p.currentToken,
 	}
-	if !p.expectPeek(token.TokenTypeLeftParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be left parenthesis, got %s", p.peekToken.TokenType)
+	err := p.expectPeek(token.TokenTypeLeftParenthesis)
+	if err != nil {
+		return nil, err
 	}
 	p.nextToken()
 
@@ -289,20 +367,19 @@ func (p *Parser) parseWhileStatement() (ast.Statement, error) {
 	}
 	statement.Condition = exp
 
-	if !p.expectPeek(token.TokenTypeRightParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be right parenthesis, got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeRightParenthesis)
+	if err != nil {
+		return nil, err
 	}
-	if !p.expectPeek(token.TokenTypeLeftBrace) {
-		return nil, fmt.Errorf("expected peek token to be left brace but found %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeLeftBrace)
+	if err != nil {
+		return nil, err
 	}
 	body, err := p.parseBlockStatement()
 	if err != nil {
 		return nil, err
 	}
 	statement.Body = body
-	if !p.currentTokenIs(token.TokenTypeRightBrace) {
-		return nil, fmt.Errorf("expected current token to be right brace but found %s", p.peekToken.TokenType)
-	}
 	return statement, nil
 }
 
@@ -310,8 +387,9 @@ func (p *Parser) parseIfStatement() (ast.Statement, error) {
 	statement := &ast.IfStatement{
 		Token: p.currentToken,
 	}
-	if !p.expectPeek(token.TokenTypeLeftParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be left parenthesis, got %s", p.peekToken.TokenType)
+	err := p.expectPeek(token.TokenTypeLeftParenthesis)
+	if err != nil {
+		return nil, err
 	}
 	p.nextToken()
 
@@ -321,22 +399,32 @@ func (p *Parser) parseIfStatement() (ast.Statement, error) {
 	}
 	statement.Condition = exp
 
-	if !p.expectPeek(token.TokenTypeRightParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be right parenthesis, got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeRightParenthesis)
+	if err != nil {
+		return nil, err
 	}
-	if !p.expectPeek(token.TokenTypeLeftBrace) {
-		return nil, fmt.Errorf("expected peek token to be left brace but found %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeLeftBrace)
+	if err != nil {
+		return nil, err
 	}
 	consequence, err := p.parseBlockStatement()
 	if err != nil {
 		return nil, err
 	}
 	statement.Consequence = consequence
-	if !p.currentTokenIs(token.TokenTypeRightBrace) {
-		return nil, fmt.Errorf("expected current token to be right brace but found %s", p.peekToken.TokenType)
-	}
 
-	// TODO: handle else
+	if p.peekTokenIs(token.TokenTypeElse) {
+		p.nextToken()
+		err = p.expectPeek(token.TokenTypeLeftBrace)
+		if err != nil {
+			return nil, err
+		}
+		alternative, err := p.parseBlockStatement()
+		if err != nil {
+			return nil, err
+		}
+		statement.Alternative = alternative
+	}
 
 	return statement, nil
 }
@@ -351,8 +439,9 @@ func (p *Parser) parseReturnStatement() (*ast.ReturnStatement, error) {
 			return nil, err
 		}
 		statement.Value = exp
-		if !p.expectPeek(token.TokenTypeSemicolon) {
-			return nil, fmt.Errorf("expected peek token to be semicolon got %s", p.peekToken.TokenType)
+		err = p.expectPeek(token.TokenTypeSemicolon)
+		if err != nil {
+			return nil, err
 		}
 	}
 	return statement, nil
@@ -367,8 +456,9 @@ func (p *Parser) parseDoStatement() (*ast.DoStatement, error) {
 	}
 	statement.SubroutineCall = call.(*ast.SubroutineCall)
 
-	if !p.expectPeek(token.TokenTypeSemicolon) {
-		return nil, fmt.Errorf("expected peek token to be semicolon got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeSemicolon)
+	if err != nil {
+		return nil, err
 	}
 
 	return statement, nil
@@ -391,8 +481,9 @@ func (p *Parser) parseSubroutineCall() (ast.Expression, error) {
 	}
 	call.SubroutineName = name.(*ast.Identifier)
 
-	if !p.expectPeek(token.TokenTypeLeftParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be left parenthesis, got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeLeftParenthesis)
+	if err != nil {
+		return nil, err
 	}
 
 	expressions, err := p.parseExpressions()
@@ -400,9 +491,6 @@ func (p *Parser) parseSubroutineCall() (ast.Expression, error) {
 		return nil, err
 	}
 	call.Arguments = expressions
-	if !p.currentTokenIs(token.TokenTypeRightParenthesis) {
-		return nil, fmt.Errorf("expected current token to be right parenthesis, got %s", p.peekToken.TokenType)
-	}
 
 	return call, nil
 }
@@ -419,6 +507,8 @@ func (p *Parser) parseExpressions() ([]ast.Expression, error) {
 		expressions = append(expressions, exp)
 		if p.peekTokenIs(token.TokenTypeComma) {
 			p.nextToken()
+		} else if !p.peekTokenIs(token.TokenTypeRightParenthesis) {
+			return nil, p.errorf(p.peekToken, "expected ',' or ')', got %s", describe(p.peekToken))
 		}
 		p.nextToken()
 	}
@@ -444,13 +534,15 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 		}
 		let.Index = index
 
-		if !p.expectPeek(token.TokenTypeRightBracket) {
-			return nil, fmt.Errorf("expected peek token to be right bracket, got %s", p.currentToken.TokenType)
+		err = p.expectPeek(token.TokenTypeRightBracket)
+		if err != nil {
+			return nil, err
 		}
 		p.nextToken()
 	}
-	if !p.currentTokenIs(token.TokenTypeAssign) {
-		return nil, fmt.Errorf("expected current token to be assign got %s", p.peekToken.TokenType)
+	err = p.expectCurrent(token.TokenTypeAssign)
+	if err != nil {
+		return nil, err
 	}
 	p.nextToken()
 
@@ -460,8 +552,9 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 	}
 	let.Value = exp
 
-	if !p.expectPeek(token.TokenTypeSemicolon) {
-		return nil, fmt.Errorf("expected peek token to be semicolon got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeSemicolon)
+	if err != nil {
+		return nil, err
 	}
 
 	return let, nil
@@ -469,6 +562,7 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 
 const (
 	LOWEST uint8 = iota
+	LOGICAL
 	EQUALS
 	LESSGREATER
 	SUM
@@ -480,6 +574,8 @@ const (
 )
 
 var precedenceTable = map[token.TokenType]uint8{
+	token.TokenTypeAmpersand:       LOGICAL,
+	token.TokenTypeVerticalBar:     LOGICAL,
 	token.TokenTypeAssign:          EQUALS,
 	token.TokenTypeLess:            LESSGREATER,
 	token.TokeTypeGreater:          LESSGREATER,
@@ -511,7 +607,7 @@ func (p *Parser) peekPrecedence() uint8 {
 func (p *Parser) parseExpression(precedence uint8) (ast.Expression, error) {
 	prefixFn, ok := p.prefixParseFns[p.currentToken.TokenType]
 	if !ok {
-		return nil, fmt.Errorf("can't found prefix parse function for %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected expression, got %s", describe(p.currentToken))
 	}
 	left, err := prefixFn()
 	if err != nil {
@@ -537,17 +633,89 @@ func (p *Parser) parseExpression(precedence uint8) (ast.Expression, error) {
 func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
 	block := &ast.BlockStatement{Token: p.currentToken, Statements: []ast.Statement{}}
 	p.nextToken()
+	return block, p.parseStatements(block)
+}
 
+// parseStatements appends statements to block until the right brace closing it. A statement
+// with a syntax error is skipped, only reaching the end of the file is returned as an error.
+func (p *Parser) parseStatements(block *ast.BlockStatement) error {
 	for !p.currentTokenIs(token.TokenTypeRightBrace) {
+		if p.currentTokenIs(token.TokenTypeEOF) {
+			return p.errorf(p.currentToken, "expected '}', got %s", describe(p.currentToken))
+		}
+		depth := p.depth
 		statement, err := p.parseStatement()
 		if err != nil {
-			return nil, err
+			p.addError(err)
+			p.synchronize(depth)
+			continue
 		}
 		block.Statements = append(block.Statements, statement)
 		p.nextToken()
 	}
 
-	return block, nil
+	return nil
+}
+
+// addError records err, which is positioned at the current token unless it's a *source.Error.
+func (p *Parser) addError(err error) {
+	var sourceError *source.Error
+	if !errors.As(err, &sourceError) {
+		sourceError = source.Errorf(p.currentToken.Pos(), p.l.Line(p.currentToken.Pos().Line), "%s", err)
+	}
+	p.errors.Add(sourceError)
+}
+
+var statementStarts = map[token.TokenType]bool{
+	token.TokenTypeLet:    true,
+	token.TokenTypeDo:     true,
+	token.TokenTypeIf:     true,
+	token.TokenTypeWhile:  true,
+	token.TokenTypeReturn: true,
+	token.TokenTypeVar:    true,
+}
+
+// synchronize skips the rest of a statement with a syntax error, depth being the brace depth at
+// its start: it stops after a `;`, or at a `}` or a keyword starting a statement, ignoring those
+// inside the blocks the statement opened, so that an error in a while or if condition skips its
+// whole body. The current token is always skipped unless it's a `}`, since the statement may
+// have failed on its first token.
+func (p *Parser) synchronize(depth int) {
+	if !p.currentTokenIs(token.TokenTypeRightBrace) {
+		p.nextToken()
+	}
+	for !p.currentTokenIs(token.TokenTypeEOF) {
+		if p.currentTokenIs(token.TokenTypeRightBrace) && p.depth < depth {
+			return
+		}
+		if p.depth == depth {
+			if p.currentTokenIs(token.TokenTypeSemicolon) {
+				p.nextToken()
+				return
+			}
+			if statementStarts[p.currentToken.TokenType] {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// skipTo skips the current token and the following ones up to one of tokenTypes, the `}` closing
+// the class or the end of the file.
+func (p *Parser) skipTo(tokenTypes ...token.TokenType) {
+	p.nextToken()
+	for !p.currentTokenIs(token.TokenTypeEOF) {
+		if p.currentTokenIs(token.TokenTypeRightBrace) && p.depth == 0 {
+			return
+		}
+		for _, tokenType := range tokenTypes {
+			if p.currentTokenIs(tokenType) {
+				return
+			}
+		}
+		p.nextToken()
+	}
 }
 
 func (p *Parser) currentTokenIs(tokenType token.TokenType) bool {
@@ -559,20 +727,97 @@ func (p *Parser) peekTokenIs(tokenType token.TokenType) bool {
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	switch p.currentToken.TokenType {
+	case token.TokenTypeLeftBrace:
+		p.depth++
+	case token.TokenTypeRightBrace:
+		p.depth--
+	}
 }
 
-func (p *Parser) expectPeek(tokenType token.TokenType) bool {
+// expectPeek advances to the peek token when it has tokenType, otherwise it returns an error at
+// the peek token.
+func (p *Parser) expectPeek(tokenType token.TokenType) error {
 	if p.peekTokenIs(tokenType) {
 		p.nextToken()
-		return true
+		return nil
+	}
+	return p.errorf(p.peekToken, "expected %s, got %s", describeType(tokenType), describe(p.peekToken))
+}
+
+func (p *Parser) expectCurrent(tokenType token.TokenType) error {
+	if p.currentTokenIs(tokenType) {
+		return nil
+	}
+	return p.errorf(p.currentToken, "expected %s, got %s", describeType(tokenType), describe(p.currentToken))
+}
+
+// errorf returns a *source.Error at tok, with its source line for the caret snippet.
+func (p *Parser) errorf(tok token.Token, format string, args ...any) error {
+	return source.Errorf(tok.Pos(), p.l.Line(tok.Pos().Line), format, args...)
+}
+
+var symbols = map[token.TokenType]string{
+	token.TokenTypeComma:            ",",
+	token.TokenTypeLeftParenthesis:  "(",
+	token.TokenTypeRightParenthesis: ")",
+	token.TokenTypeLeftBrace:        "{",
+	token.TokenTypeRightBrace:       "}",
+	token.TokenTypeSemicolon:        ";",
+	token.TokenTypeAssign:           "=",
+	token.TokenTypeTilde:            "~",
+	token.TokenTypeLeftBracket:      "[",
+	token.TokenTypeRightBracket:     "]",
+	token.TokeTypeGreater:           ">",
+	token.TokenTypeLess:             "<",
+	token.TokenTypePlus:             "+",
+	token.TokenTypeMinus:            "-",
+	token.TokenTypeAsterisk:         "*",
+	token.TokenTypeSlash:            "/",
+	token.TokenTypeAmpersand:        "&",
+	token.TokenTypeVerticalBar:      "|",
+	token.TokenTypeDot:              ".",
+}
+
+// describeType names tokenType the way it's written in Jack, for error messages.
+func describeType(tokenType token.TokenType) string {
+	if symbol, ok := symbols[tokenType]; ok {
+		return "'" + symbol + "'"
+	}
+	switch tokenType {
+	case token.TokenTypeIdentifier:
+		return "identifier"
+	case token.TokenTypeIntegerLiteral:
+		return "integer constant"
+	case token.TokenTypeStringLiteral:
+		return "string constant"
+	case token.TokenTypeEOF:
+		return "end of file"
+	case token.TokenTypeIllegal:
+		return "illegal token"
+	default:
+		return "'" + tokenType.String() + "'"
+	}
+}
+
+// describe quotes tok for error messages.
+func describe(tok token.Token) string {
+	switch tok.TokenType {
+	case token.TokenTypeEOF:
+		return describeType(tok.TokenType)
+	case token.TokenTypeIllegal:
+		return "illegal token '" + tok.Literal + "'"
+	case token.TokenTypeStringLiteral:
+		return fmt.Sprintf("%q", tok.Literal)
+	default:
+		return "'" + tok.Literal + "'"
 	}
-	return false
 }
 
 func (p *Parser) parseIntegerLiteral() (ast.Expression, error) {
 	num, err := strconv.Atoi(p.currentToken.Literal)
 	if err != nil {
-		return nil, err
+		return nil, p.errorf(p.currentToken, "invalid integer constant %s", p.currentToken.Literal)
 	}
 	exp := &ast.IntegerLiteral{
 		Token: p.currentToken,
@@ -591,7 +836,7 @@ func (p *Parser) parseKeywordConstantLiteral() (ast.Expression, error) {
 	case token.TokenTypeThis:
 		return &ast.KeywordConstantLiteral{Token: p.currentToken, Value: "this"}, nil
 	default:
-		return nil, fmt.Errorf("expected current token to be this got %s", p.currentToken.TokenType)
+		return nil, p.errorf(p.currentToken, "expected keyword constant, got %s", describe(p.currentToken))
 	}
 }
 
@@ -618,8 +863,9 @@ func (p *Parser) parseParenthesisExpression() (ast.Expression, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !p.expectPeek(token.TokenTypeRightParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be right parenthesis but got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeRightParenthesis)
+	if err != nil {
+		return nil, err
 	}
 	return exp, nil
 }
@@ -627,7 +873,7 @@ func (p *Parser) parseParenthesisExpression() (ast.Expression, error) {
 func (p *Parser) parseObjectCall(exp ast.Expression) (ast.Expression, error) {
 	callee, ok := exp.(*ast.Identifier)
 	if !ok {
-		return nil, fmt.Errorf("expected identifier, got %s", exp.String())
+		return nil, p.errorf(p.currentToken, "expected class or variable name before '.', got %s", exp.String())
 	}
 	subroutineCall := &ast.SubroutineCall{
 		Token:      callee.Token,
@@ -640,8 +886,9 @@ func (p *Parser) parseObjectCall(exp ast.Expression) (ast.Expression, error) {
 		return nil, err
 	}
 	subroutineCall.SubroutineName = subroutineName.(*ast.Identifier)
-	if !p.expectPeek(token.TokenTypeLeftParenthesis) {
-		return nil, fmt.Errorf("expected peek token to be left parenthesis, got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeLeftParenthesis)
+	if err != nil {
+		return nil, err
 	}
 
 	args, err := p.parseExpressions()
@@ -656,7 +903,7 @@ func (p *Parser) parseObjectCall(exp ast.Expression) (ast.Expression, error) {
 func (p *Parser) parseCallExpression(exp ast.Expression) (ast.Expression, error) {
 	subroutineName, ok := exp.(*ast.Identifier)
 	if !ok {
-		return nil, fmt.Errorf("expected identifier, got %s", exp.String())
+		return nil, p.errorf(p.currentToken, "expected subroutine name before '(', got %s", exp.String())
 	}
 	subroutineCall := &ast.SubroutineCall{
 		Token:          subroutineName.Token,
@@ -676,7 +923,7 @@ func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 	prefixExpression.Operator = p.currentToken.Literal
 	p.nextToken()
 
-	exp, err := p.parseExpression(LOWEST)
+	exp, err := p.parseExpression(PREFIX)
 	if err != nil {
 		return nil, err
 	}
@@ -687,14 +934,15 @@ func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
 	indexExpression := &ast.IndexExpression{Token: p.currentToken, Left: left}
 	p.nextToken()
-	index, err := p.parseExpression(PREFIX)
+	index, err := p.parseExpression(LOWEST)
 	if err != nil {
 		return nil, err
 	}
 	indexExpression.Index = index
 
-	if !p.expectPeek(token.TokenTypeRightBracket) {
-		return nil, fmt.Errorf("expected peek token to be right bracket got %s", p.peekToken.TokenType)
+	err = p.expectPeek(token.TokenTypeRightBracket)
+	if err != nil {
+		return nil, err
 	}
 
 	return indexExpression, nil
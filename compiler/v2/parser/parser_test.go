@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"errors"
+	"hack/compiler/source"
 	"hack/compiler/v2/ast"
 	"hack/compiler/v2/lexer"
 	"strings"
@@ -621,3 +623,225 @@ func testField(t *testing.T, input *ast.Field, expected string) {
 		t.Fatalf("expecting %s, got %s", expected, input.String())
 	}
 }
+
+func TestParseVarAndElse(t *testing.T) {
+	content := `
+class Main {
+   function int abs(int x) {
+      var int result, sign;
+      var Array a;
+      if (x < 0) {
+         let result = -x;
+      } else {
+         let result = x;
+      }
+      return result;
+   }
+}
+`
+	l := lexer.New(strings.NewReader(content))
+	p := New(l)
+	actual, err := p.ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	function := actual.Subroutines[0]
+	if len(function.Variables) != 2 {
+		t.Fatalf("expecting 2 variable declarations, got %d", len(function.Variables))
+	}
+	if function.Variables[0].String() != "var int result, sign;" {
+		t.Fatalf("expecting var int result, sign;, got %s", function.Variables[0])
+	}
+	if function.Variables[1].String() != "var Array a;" {
+		t.Fatalf("expecting var Array a;, got %s", function.Variables[1])
+	}
+	expectedBody := &ast.BlockStatement{
+		Statements: []ast.Statement{
+			&ast.IfStatement{
+				Condition: &ast.InfixExpression{
+					Left:     &ast.Identifier{Value: "x"},
+					Operator: "<",
+					Right:    &ast.IntegerLiteral{Value: 0},
+				},
+				Consequence: &ast.BlockStatement{
+					Statements: []ast.Statement{
+						&ast.LetStatement{
+							Name:  &ast.Identifier{Value: "result"},
+							Value: &ast.PrefixExpression{Operator: "-", Left: &ast.Identifier{Value: "x"}},
+						},
+					},
+				},
+				Alternative: &ast.BlockStatement{
+					Statements: []ast.Statement{
+						&ast.LetStatement{
+							Name:  &ast.Identifier{Value: "result"},
+							Value: &ast.Identifier{Value: "x"},
+						},
+					},
+				},
+			},
+			&ast.ReturnStatement{
+				Value: &ast.Identifier{Value: "result"},
+			},
+		},
+	}
+	testBlockStatement(t, function.Body, expectedBody)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		content  string
+		expected string
+	}{
+		{
+			"class Main {\n   function void main() {\n\tlet x = 1\n      return;\n   }\n}\n",
+			"Main.jack:4:7: expected ';', got 'return'\n      return;\n      ^",
+		},
+		{
+			"class Main {\n\tfunction void main( {\n",
+			"Main.jack:2:22: expected type, got '{'\n\tfunction void main( {\n\t                    ^",
+		},
+		{
+			"class Main {\n   function void main() {\n      do foo(1 2);\n",
+			"Main.jack:3:16: expected ',' or ')', got '2'\n      do foo(1 2);\n               ^\n" +
+				"Main.jack:3:19: expected '}', got end of file\n      do foo(1 2);\n                  ^",
+		},
+		{
+			"class Main {\n   function void main() {\n      let x = 1 let y = ;\n   }\n}\n",
+			"Main.jack:3:17: expected ';', got 'let'\n      let x = 1 let y = ;\n                ^\n" +
+				"Main.jack:3:25: expected expression, got ';'\n      let x = 1 let y = ;\n                        ^",
+		},
+		{
+			"class Main {\n   function void main() {\n      return;\n",
+			"Main.jack:3:14: expected '}', got end of file\n      return;\n             ^",
+		},
+	} {
+		p := New(lexer.NewFile("Main.jack", strings.NewReader(tt.content)))
+		_, err := p.ParseClass()
+		if err == nil {
+			t.Fatalf("expected error for %q", tt.content)
+		}
+		if err.Error() != tt.expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", tt.expected, err)
+		}
+	}
+}
+
+func TestParseErrors_Recovery(t *testing.T) {
+	content := `class Main {
+   field int x y;
+   static int z;
+
+   function void main() {
+      var int a;
+      let a = ;
+      do Output.printInt(a);
+      while (a) { let a = a - ; }
+      let b = 2 3;
+      return;
+   }
+
+   method void f( {
+      return;
+   }
+
+   method void g() {
+      let c = #;
+      return c;
+   }
+}
+`
+	p := New(lexer.NewFile("Main.jack", strings.NewReader(content)))
+	class, err := p.ParseClass()
+	var list source.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected source.ErrorList, got %v", err)
+	}
+	expected := []string{
+		"Main.jack:2:16: expected ';', got 'y'",
+		"Main.jack:7:15: expected expression, got ';'",
+		"Main.jack:9:31: expected expression, got ';'",
+		"Main.jack:10:17: expected ';', got '3'",
+		"Main.jack:14:19: expected type, got '{'",
+		"Main.jack:19:15: expected expression, got illegal token '#'",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		message := strings.SplitN(e.Error(), "\n", 2)[0]
+		if message != expected[i] {
+			t.Fatalf("error %d: expected %q, got %q", i, expected[i], message)
+		}
+	}
+
+	// the statements without errors are kept
+	if len(class.Fields) != 1 || class.Fields[0].String() != "static int z;" {
+		t.Fatalf("expected only static int z;, got %v", class.Fields)
+	}
+	if len(class.Subroutines) != 2 {
+		t.Fatalf("expected 2 subroutines, got %d", len(class.Subroutines))
+	}
+	main := class.Subroutines[0]
+	if len(main.Body.Statements) != 3 {
+		t.Fatalf("expected 3 statements in main, got %d: %s", len(main.Body.Statements), main.Body)
+	}
+	g := class.Subroutines[1]
+	if g.Name.Value != "g" || len(g.Body.Statements) != 1 {
+		t.Fatalf("expected g with 1 statement, got %s with %d", g.Name.Value, len(g.Body.Statements))
+	}
+}
+
+// TestParseErrors_RecoveryNested checks an error in a while or if condition skips the whole
+// statement, without closing the subroutine at the '}' of its body.
+func TestParseErrors_RecoveryNested(t *testing.T) {
+	content := `class Main {
+   function void main() {
+      var int x;
+      while (x < ) { let x = x + 1; }
+      if (x) {
+         while (~) {
+            if (x) { let x = 1; } else { let x = 2; }
+         }
+         let x = ;
+      }
+      return;
+   }
+
+   function void f() {
+      let y = 1 2;
+      return;
+   }
+}
+`
+	p := New(lexer.NewFile("Main.jack", strings.NewReader(content)))
+	class, err := p.ParseClass()
+	var list source.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected source.ErrorList, got %v", err)
+	}
+	expected := []string{
+		"Main.jack:4:18: expected expression, got ')'",
+		"Main.jack:6:18: expected expression, got ')'",
+		"Main.jack:9:18: expected expression, got ';'",
+		"Main.jack:15:17: expected ';', got '2'",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(list), list)
+	}
+	for i, e := range list {
+		message := strings.SplitN(e.Error(), "\n", 2)[0]
+		if message != expected[i] {
+			t.Fatalf("error %d: expected %q, got %q", i, expected[i], message)
+		}
+	}
+
+	if len(class.Subroutines) != 2 {
+		t.Fatalf("expected 2 subroutines, got %d", len(class.Subroutines))
+	}
+	main := class.Subroutines[0]
+	if len(main.Body.Statements) != 2 {
+		t.Fatalf("expected the if and return statements in main, got %d: %s", len(main.Body.Statements), main.Body)
+	}
+}
@@ -1,6 +1,9 @@
 package token
 
-import "fmt"
+import (
+	"fmt"
+	"hack/compiler/source"
+)
 
 type TokenType uint8
 
@@ -153,6 +156,12 @@ func (t TokenType) String() string {
 type Token struct {
 	TokenType TokenType
 	Literal   string
+	Span      source.Span
+}
+
+// Pos returns the position of the first rune of the token.
+func (t Token) Pos() source.Pos {
+	return t.Span.Start
 }
 
 func (t Token) String() string {
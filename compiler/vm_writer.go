@@ -606,8 +606,6 @@ func (w *VmWriter) handleTerm(term *Term) error {
 	default:
 		return fmt.Errorf("undefined term: %s", term.TermType())
 	}
-
-	return fmt.Errorf("unimplemented term: %s", term.TermType())
 }
 
 func (w *VmWriter) handleKeyword(keyword KeywordConstant) error {
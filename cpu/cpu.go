@@ -0,0 +1,287 @@
+package cpu
+
+import (
+	"bufio"
+	"fmt"
+	"hack/assembler"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ROMSize         = 32768
+	RAMSize         = 32768
+	ScreenAddress   = 16384
+	ScreenSize      = 8192
+	KeyboardAddress = 24576
+)
+
+// CPU is a Hack computer: 32K words of ROM holding the program, 32K words of RAM with the
+// memory-mapped SCREEN and KBD, and the A, D and PC registers.
+type CPU struct {
+	rom         [ROMSize]uint16
+	ram         [RAMSize]int16
+	a           int16
+	d           int16
+	pc          uint16
+	programSize int
+	cycles      int64
+	// extended enables the shifts of assembler.ExtendedISA
+	extended bool
+}
+
+func New() *CPU {
+	return &CPU{}
+}
+
+// SetExtended makes the CPU execute the shift instructions of assembler.ExtendedISA, which are
+// invalid otherwise.
+func (c *CPU) SetExtended(extended bool) {
+	c.extended = extended
+}
+
+// LoadInstructions loads the output of assembler.Translate into the ROM and resets the CPU.
+func (c *CPU) LoadInstructions(instructions []assembler.Instruction) error {
+	code, err := assembler.OutputBinaryCode(instructions)
+	if err != nil {
+		return err
+	}
+	return c.LoadBinary(code)
+}
+
+// LoadBinary loads lines of `0`/`1` characters, the content of a .hack file, into the ROM
+// and resets the CPU. Blank lines are ignored.
+func (c *CPU) LoadBinary(lines []string) error {
+	words := make([]uint16, 0, len(lines))
+	for lineNo, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) != 16 {
+			return fmt.Errorf("failed to load line %d, %s is not a 16-bit word", lineNo+1, line)
+		}
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil {
+			return fmt.Errorf("failed to load line %d, %s is not a binary word", lineNo+1, line)
+		}
+		words = append(words, uint16(word))
+	}
+	return c.LoadWords(words)
+}
+
+// LoadHack reads a .hack program from reader and loads it into the ROM.
+func (c *CPU) LoadHack(reader io.Reader) error {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return c.LoadBinary(lines)
+}
+
+// LoadHackFile loads the .hack file at path into the ROM.
+func (c *CPU) LoadHackFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadHack(f)
+}
+
+// LoadWords loads raw machine words into the ROM and resets the CPU.
+func (c *CPU) LoadWords(words []uint16) error {
+	if len(words) > ROMSize {
+		return fmt.Errorf("program has %d instructions, ROM only holds %d", len(words), ROMSize)
+	}
+	c.rom = [ROMSize]uint16{}
+	copy(c.rom[:], words)
+	c.programSize = len(words)
+	c.Reset()
+	return nil
+}
+
+// Reset sets PC to 0 and clears the registers, the RAM is kept as is.
+func (c *CPU) Reset() {
+	c.a = 0
+	c.d = 0
+	c.pc = 0
+	c.cycles = 0
+}
+
+func (c *CPU) A() int16 {
+	return c.a
+}
+
+func (c *CPU) D() int16 {
+	return c.d
+}
+
+func (c *CPU) PC() uint16 {
+	return c.pc
+}
+
+func (c *CPU) SetA(value int16) {
+	c.a = value
+}
+
+func (c *CPU) SetD(value int16) {
+	c.d = value
+}
+
+func (c *CPU) SetPC(value uint16) {
+	c.pc = value & (ROMSize - 1)
+}
+
+func (c *CPU) ProgramSize() int {
+	return c.programSize
+}
+
+func (c *CPU) Cycles() int64 {
+	return c.cycles
+}
+
+func (c *CPU) Peek(address int) (int16, error) {
+	if address < 0 || address >= RAMSize {
+		return 0, fmt.Errorf("RAM address %d is out of range", address)
+	}
+	return c.ram[address], nil
+}
+
+func (c *CPU) Poke(address int, value int16) error {
+	if address < 0 || address >= RAMSize {
+		return fmt.Errorf("RAM address %d is out of range", address)
+	}
+	c.ram[address] = value
+	return nil
+}
+
+func (c *CPU) ROM(address int) (uint16, error) {
+	if address < 0 || address >= ROMSize {
+		return 0, fmt.Errorf("ROM address %d is out of range", address)
+	}
+	return c.rom[address], nil
+}
+
+// SetKeyboard simulates pressing the key with the given Hack character code, 0 means no key.
+func (c *CPU) SetKeyboard(key int16) {
+	c.ram[KeyboardAddress] = key
+}
+
+// Screen returns the memory-mapped screen, 512x256 pixels packed into 16-bit words.
+func (c *CPU) Screen() []int16 {
+	return c.ram[ScreenAddress : ScreenAddress+ScreenSize]
+}
+
+// Step executes the instruction pointed by PC.
+func (c *CPU) Step() error {
+	instruction := c.rom[c.pc]
+	c.cycles++
+
+	// A-instruction: 0vvvvvvvvvvvvvvv
+	if instruction&0x8000 == 0 {
+		c.a = int16(instruction)
+		c.pc = (c.pc + 1) & (ROMSize - 1)
+		return nil
+	}
+
+	// C-instruction: 111a cccc ccdd djjj, or 101a cccc ccdd djjj for the shifts
+	address := uint16(c.a) & (RAMSize - 1)
+	y := c.a
+	if instruction&0x1000 != 0 {
+		y = c.ram[address]
+	}
+	var out int16
+	switch {
+	case instruction&0x6000 == 0x6000:
+		out = alu(c.d, y, (instruction>>6)&0x3F)
+	case c.extended && instruction&0x6000 == 0x2000:
+		out = shift(c.d, y, (instruction>>6)&0x3F)
+	default:
+		return fmt.Errorf("invalid instruction %016b at ROM[%d]", instruction, c.pc)
+	}
+
+	dest := (instruction >> 3) & 0x7
+	if dest&0x1 != 0 {
+		c.ram[address] = out
+	}
+	jumpAddress := uint16(c.a) & (ROMSize - 1)
+	if dest&0x4 != 0 {
+		c.a = out
+	}
+	if dest&0x2 != 0 {
+		c.d = out
+	}
+
+	if shouldJump(out, instruction&0x7) {
+		c.pc = jumpAddress
+	} else {
+		c.pc = (c.pc + 1) & (ROMSize - 1)
+	}
+	return nil
+}
+
+// Run executes at most maxSteps instructions and returns the number of executed instructions.
+func (c *CPU) Run(maxSteps int) (int, error) {
+	for i := 0; i < maxSteps; i++ {
+		if err := c.Step(); err != nil {
+			return i, err
+		}
+	}
+	return maxSteps, nil
+}
+
+// alu computes the Hack ALU output, comp holds the zx,nx,zy,ny,f,no control bits.
+func alu(x int16, y int16, comp uint16) int16 {
+	if comp&0x20 != 0 {
+		x = 0
+	}
+	if comp&0x10 != 0 {
+		x = ^x
+	}
+	if comp&0x08 != 0 {
+		y = 0
+	}
+	if comp&0x04 != 0 {
+		y = ^y
+	}
+	var out int16
+	if comp&0x02 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if comp&0x01 != 0 {
+		out = ^out
+	}
+	return out
+}
+
+// shift computes the shifts of the extended instruction set, comp holds the c bits: the first one
+// selects a left shift, the second one shifts x instead of y.
+func shift(x int16, y int16, comp uint16) int16 {
+	if comp&0x10 != 0 {
+		y = x
+	}
+	if comp&0x20 != 0 {
+		return int16(uint16(y) << 1)
+	}
+	return y >> 1
+}
+
+func shouldJump(out int16, jump uint16) bool {
+	switch {
+	case out < 0:
+		return jump&0x4 != 0
+	case out == 0:
+		return jump&0x2 != 0
+	default:
+		return jump&0x1 != 0
+	}
+}
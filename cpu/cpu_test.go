@@ -0,0 +1,174 @@
+package cpu
+
+import (
+	"bufio"
+	"hack/assembler"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCPU_Mult(t *testing.T) {
+	f, err := os.Open("../ch4/mult.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	commands, err := assembler.Parse(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := assembler.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	err = c.LoadInstructions(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Poke(0, 6)
+	_ = c.Poke(1, 7)
+	_, err = c.Run(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, _ := c.Peek(2)
+	if actual != 42 {
+		t.Fatalf("expecting R2 = 42, got %d", actual)
+	}
+}
+
+func TestCPU_LoadHack(t *testing.T) {
+	// @2, D=A, @3, D=D+A, @0, M=D, @6, 0;JMP
+	program := `
+0000000000000010
+1110110000010000
+0000000000000011
+1110000010010000
+0000000000000000
+1110001100001000
+0000000000000110
+1110101010000111
+`
+	c := New()
+	err := c.LoadHack(strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ProgramSize() != 8 {
+		t.Fatalf("expecting 8 instructions, got %d", c.ProgramSize())
+	}
+	_, err = c.Run(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, _ := c.Peek(0)
+	if actual != 5 {
+		t.Fatalf("expecting RAM[0] = 5, got %d", actual)
+	}
+	if c.PC() != 6 && c.PC() != 7 {
+		t.Fatalf("expecting PC to loop at the end, got %d", c.PC())
+	}
+}
+
+func TestCPU_Computations(t *testing.T) {
+	tests := []struct {
+		comp     assembler.Computation
+		expected int16
+	}{
+		{assembler.Zero, 0},
+		{assembler.One, 1},
+		{assembler.NegativeOne, -1},
+		{assembler.D, 17},
+		{assembler.A, 5},
+		{assembler.M, 100},
+		{assembler.NotD, ^int16(17)},
+		{assembler.NegativeA, -5},
+		{assembler.DPlusOne, 18},
+		{assembler.MMinusOne, 99},
+		{assembler.DPlusA, 22},
+		{assembler.DMinusM, -83},
+		{assembler.AMinusD, -12},
+		{assembler.DAndA, 17 & 5},
+		{assembler.DOrM, 17 | 100},
+	}
+
+	for _, test := range tests {
+		c := New()
+		err := c.LoadInstructions([]assembler.Instruction{
+			assembler.CInstruction{Dst: assembler.DDestination, Comp: test.comp, Jump: assembler.NotJump},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetA(5)
+		c.SetD(17)
+		_ = c.Poke(5, 100)
+		err = c.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.D() != test.expected {
+			t.Fatalf("expecting %s to compute %d, got %d", test.comp, test.expected, c.D())
+		}
+	}
+}
+
+func TestCPU_Shifts(t *testing.T) {
+	tests := []struct {
+		comp     string
+		expected int16
+	}{
+		{"D<<", 34},
+		{"A<<", 10},
+		{"M<<", -200},
+		{"D>>", 8},
+		{"A>>", 2},
+		{"M>>", -50},
+	}
+
+	for _, test := range tests {
+		commands, err := assembler.Parse([]string{"D=" + test.comp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := assembler.Translate(commands); err == nil {
+			t.Fatalf("expecting %s to be rejected by the standard instruction set", test.comp)
+		}
+		a := assembler.New()
+		a.SetISA(assembler.ExtendedISA)
+		instructions, err := a.Translate(commands)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := New()
+		err = c.LoadInstructions(instructions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetA(5)
+		c.SetD(17)
+		_ = c.Poke(5, -100)
+		if err := c.Step(); err == nil {
+			t.Fatalf("expecting %s to be invalid without the extended instruction set", test.comp)
+		}
+		c.SetPC(0)
+		c.SetExtended(true)
+		err = c.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.D() != test.expected {
+			t.Fatalf("expecting %s to compute %d, got %d", test.comp, test.expected, c.D())
+		}
+	}
+}
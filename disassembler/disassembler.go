@@ -0,0 +1,177 @@
+package disassembler
+
+import (
+	"bufio"
+	"fmt"
+	"hack/assembler"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decode decodes a machine word of the standard instruction set into an assembler.AInstruction
+// or assembler.CInstruction.
+func Decode(word uint16) (assembler.Instruction, error) {
+	return DecodeISA(word, assembler.StandardISA)
+}
+
+// DecodeISA decodes a machine word of the instruction set isa, see Decode.
+func DecodeISA(word uint16, isa *assembler.ISA) (assembler.Instruction, error) {
+	if word&0x8000 == 0 {
+		return assembler.AInstruction{Location: int32(word)}, nil
+	}
+	if isa == assembler.StandardISA && word&0x6000 != 0x6000 {
+		return nil, fmt.Errorf("%016b is not a C-instruction, bits 14 and 13 must be set", word)
+	}
+	bits := fmt.Sprintf("%016b", word)
+	prefix := assembler.Prefix(bits[:3])
+	if prefix == assembler.StandardPrefix {
+		prefix = ""
+	}
+	instruction := assembler.CInstruction{
+		Prefix: prefix,
+		Comp:   assembler.Computation(bits[3:10]),
+		Dst:    assembler.Destination(bits[10:13]),
+		Jump:   assembler.Jump(bits[13:16]),
+	}
+	if _, ok := isa.Mnemonic(instruction.Prefix, instruction.Comp); !ok {
+		return nil, fmt.Errorf("%016b has an invalid computation %s%s for the %s instruction set", word, instruction.Prefix, instruction.Comp, isa.Name)
+	}
+	return instruction, nil
+}
+
+// Format returns the assembly of an instruction of the standard instruction set, such as "@17"
+// or "AM=M-1;JGT".
+func Format(instruction assembler.Instruction) (string, error) {
+	return FormatISA(instruction, assembler.StandardISA)
+}
+
+// FormatISA returns the assembly of instruction, an instruction of isa, see Format.
+func FormatISA(instruction assembler.Instruction, isa *assembler.ISA) (string, error) {
+	switch i := instruction.(type) {
+	case assembler.AInstruction:
+		return fmt.Sprintf("@%d", i.Location), nil
+	case assembler.CInstruction:
+		comp, ok := isa.Mnemonic(i.Prefix, i.Comp)
+		if !ok {
+			return "", fmt.Errorf("invalid computation %s%s", i.Prefix, i.Comp)
+		}
+		dst, ok := i.Dst.Mnemonic()
+		if !ok {
+			return "", fmt.Errorf("invalid destination %s", i.Dst)
+		}
+		jump, ok := i.Jump.Mnemonic()
+		if !ok {
+			return "", fmt.Errorf("invalid jump %s", i.Jump)
+		}
+		res := comp
+		if dst != "" {
+			res = dst + "=" + res
+		}
+		if jump != "" {
+			res = res + ";" + jump
+		}
+		return res, nil
+	}
+	return "", fmt.Errorf("unknown instruction %v", instruction)
+}
+
+// ParseBinary parses lines of `0`/`1` characters, the content of a .hack file. Blank lines are
+// ignored.
+func ParseBinary(lines []string) ([]uint16, error) {
+	words := make([]uint16, 0, len(lines))
+	for lineNo, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) != 16 {
+			return words, fmt.Errorf("line %d: %s is not a 16-bit word", lineNo+1, line)
+		}
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil {
+			return words, fmt.Errorf("line %d: %s is not a binary word", lineNo+1, line)
+		}
+		words = append(words, uint16(word))
+	}
+	return words, nil
+}
+
+// ReadHack reads the words of a .hack program.
+func ReadHack(reader io.Reader) ([]uint16, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ParseBinary(lines)
+}
+
+// Disassemble returns the assembly of a program, one instruction per line. symbols may be nil,
+// otherwise the label declarations are put back, jump targets are named after their labels
+// and the addresses of variables are commented with their names. The assembly assembles back
+// to words.
+func Disassemble(words []uint16, symbols *assembler.Symbols) ([]string, error) {
+	return DisassembleISA(words, symbols, assembler.StandardISA)
+}
+
+// DisassembleISA returns the assembly of a program of the instruction set isa, see Disassemble.
+func DisassembleISA(words []uint16, symbols *assembler.Symbols, isa *assembler.ISA) ([]string, error) {
+	labels := make(map[int32][]string)
+	variables := make(map[int32]string)
+	if symbols != nil {
+		for name, location := range symbols.Labels {
+			labels[location] = append(labels[location], name)
+		}
+		for _, names := range labels {
+			sort.Strings(names)
+		}
+		for name, location := range symbols.Variables {
+			variables[location] = name
+		}
+	}
+
+	instructions := make([]assembler.Instruction, len(words))
+	for i, word := range words {
+		instruction, err := DecodeISA(word, isa)
+		if err != nil {
+			return nil, fmt.Errorf("address %d: %w", i, err)
+		}
+		instructions[i] = instruction
+	}
+
+	res := make([]string, 0, len(words))
+	for i, instruction := range instructions {
+		for _, label := range labels[int32(i)] {
+			res = append(res, fmt.Sprintf("(%s)", label))
+		}
+		line, err := FormatISA(instruction, isa)
+		if err != nil {
+			return nil, fmt.Errorf("address %d: %w", i, err)
+		}
+		if a, ok := instruction.(assembler.AInstruction); ok {
+			switch {
+			case len(labels[a.Location]) > 0 && i+1 < len(instructions) && jumps(instructions[i+1]):
+				line = "@" + labels[a.Location][0]
+			case variables[a.Location] != "":
+				// naming the variable could change the order variables are allocated in
+				line += " // " + variables[a.Location]
+			}
+		}
+		res = append(res, line)
+	}
+	// labels declared after the last instruction
+	for _, label := range labels[int32(len(words))] {
+		res = append(res, fmt.Sprintf("(%s)", label))
+	}
+	return res, nil
+}
+
+func jumps(instruction assembler.Instruction) bool {
+	c, ok := instruction.(assembler.CInstruction)
+	return ok && c.Jump != assembler.NotJump
+}
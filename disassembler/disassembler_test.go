@@ -0,0 +1,168 @@
+package disassembler
+
+import (
+	"bufio"
+	"hack/assembler"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func assemble(t *testing.T, lines []string) ([]string, *assembler.Symbols) {
+	t.Helper()
+	commands, err := assembler.Parse(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, symbols, err := assembler.New().TranslateWithSymbols(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := assembler.OutputBinaryCode(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, symbols
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		word     string
+		expected string
+	}{
+		{"0000000000010001", "@17"},
+		{"0111111111111111", "@32767"},
+		{"1110110000010000", "D=A"},
+		{"1111110111101000", "AM=M+1"},
+		{"1110101010000111", "0;JMP"},
+		{"1111000010011001", "MD=D+M;JGT"},
+		{"1110001100000000", "D"},
+	} {
+		words, err := ParseBinary([]string{tt.word})
+		if err != nil {
+			t.Fatal(err)
+		}
+		instruction, err := Decode(words[0])
+		if err != nil {
+			t.Fatalf("%s: %v", tt.word, err)
+		}
+		actual, err := Format(instruction)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.word, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("%s: expected %s, got %s", tt.word, tt.expected, actual)
+		}
+	}
+}
+
+func TestFormat_ISA(t *testing.T) {
+	shift := assembler.CInstruction{Prefix: assembler.ShiftPrefix, Dst: assembler.DDestination, Comp: assembler.ShiftLeftD, Jump: assembler.NotJump}
+	if _, err := Format(shift); err == nil {
+		t.Fatal("expected D=D<< to be invalid in the standard instruction set")
+	}
+	actual, err := FormatISA(shift, assembler.ExtendedISA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "D=D<<" {
+		t.Fatalf("expected D=D<<, got %s", actual)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, word := range []uint16{0b1000000000000000, 0b1110000001000000} {
+		_, err := Decode(word)
+		if err == nil {
+			t.Fatalf("expected error for %016b", word)
+		}
+	}
+}
+
+// TestDisassemble_RoundTrip disassembles the ch4 programs, with and without their symbols, and
+// assembles them back.
+func TestDisassemble_RoundTrip(t *testing.T) {
+	paths, err := filepath.Glob("../ch4/*.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no asm files found")
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := make([]string, 0)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+
+		code, symbols := assemble(t, lines)
+		words, err := ParseBinary(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []*assembler.Symbols{nil, symbols} {
+			asm, err := Disassemble(words, s)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			actual, _ := assemble(t, asm)
+			if strings.Join(actual, "\n") != strings.Join(code, "\n") {
+				t.Fatalf("%s: round trip differs:\n%s", path, strings.Join(asm, "\n"))
+			}
+		}
+	}
+}
+
+func TestDisassemble_Labels(t *testing.T) {
+	code, symbols := assemble(t, []string{"@x", "M=0", "(LOOP)", "@LOOP", "0;JMP", "(END)"})
+	words, err := ParseBinary(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asm, err := Disassemble(words, symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@16 // x\nM=0\n(LOOP)\n@LOOP\n0;JMP\n(END)"
+	if strings.Join(asm, "\n") != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, strings.Join(asm, "\n"))
+	}
+}
+
+func TestDisassemble_Extended(t *testing.T) {
+	lines := []string{"D=D<<", "AM=M>>", "A<<;JGT"}
+	commands, err := assembler.Parse(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := assembler.New()
+	a.SetISA(assembler.ExtendedISA)
+	instructions, err := a.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := assembler.OutputWords(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words[0] != 0b1010110000010000 {
+		t.Fatalf("expected D=D<< to be 1010110000010000, got %016b", words[0])
+	}
+	if _, err := Disassemble(words, nil); err == nil {
+		t.Fatal("expected shifts to be invalid in the standard instruction set")
+	}
+	actual, err := DisassembleISA(words, nil, assembler.ExtendedISA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(actual, "\n") != strings.Join(lines, "\n") {
+		t.Fatalf("expected %v, got %v", lines, actual)
+	}
+}
@@ -0,0 +1,135 @@
+package testscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Format uint8
+
+const (
+	BinaryFormat Format = iota
+	DecimalFormat
+	HexFormat
+	StringFormat
+)
+
+// Column is an output-list entry like `RAM[0]%D1.6.1`: the variable, its format, the left
+// padding, the width of the value and the right padding.
+type Column struct {
+	Variable     string
+	Format       Format
+	LeftPadding  int
+	Width        int
+	RightPadding int
+}
+
+// ParseColumn parses `variable%Fl.w.r`, the format defaults to %B1.16.1.
+func ParseColumn(spec string) (Column, error) {
+	column := Column{Format: BinaryFormat, LeftPadding: 1, Width: 16, RightPadding: 1}
+	idx := strings.Index(spec, "%")
+	if idx < 0 {
+		column.Variable = spec
+		return column, nil
+	}
+	column.Variable = spec[:idx]
+	if column.Variable == "" {
+		return column, fmt.Errorf("invalid output column %s", spec)
+	}
+
+	format := spec[idx+1:]
+	if len(format) == 0 {
+		return column, fmt.Errorf("invalid output column %s", spec)
+	}
+	switch format[0] {
+	case 'B':
+		column.Format = BinaryFormat
+	case 'D':
+		column.Format = DecimalFormat
+	case 'X':
+		column.Format = HexFormat
+	case 'S':
+		column.Format = StringFormat
+	default:
+		return column, fmt.Errorf("invalid format %c in output column %s", format[0], spec)
+	}
+
+	sizes := strings.Split(format[1:], ".")
+	if len(sizes) != 3 {
+		return column, fmt.Errorf("invalid output column %s", spec)
+	}
+	numbers := make([]int, 3)
+	for i, size := range sizes {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 {
+			return column, fmt.Errorf("invalid output column %s", spec)
+		}
+		numbers[i] = n
+	}
+	column.LeftPadding, column.Width, column.RightPadding = numbers[0], numbers[1], numbers[2]
+	return column, nil
+}
+
+func (c Column) size() int {
+	return c.LeftPadding + c.Width + c.RightPadding
+}
+
+// Header returns the variable name centered in the column.
+func (c Column) Header() string {
+	name := c.Variable
+	size := c.size()
+	if len(name) > size {
+		return name[:size]
+	}
+	left := (size - len(name)) / 2
+	return strings.Repeat(" ", left) + name + strings.Repeat(" ", size-len(name)-left)
+}
+
+// Cell formats value inside the column.
+func (c Column) Cell(value int16) string {
+	var content string
+	switch c.Format {
+	case DecimalFormat, StringFormat:
+		content = strconv.Itoa(int(value))
+	case BinaryFormat:
+		content = fmt.Sprintf("%016b", uint16(value))
+	case HexFormat:
+		content = fmt.Sprintf("%04X", uint16(value))
+	}
+
+	if len(content) > c.Width {
+		content = content[len(content)-c.Width:]
+	} else if c.Format == DecimalFormat {
+		content = strings.Repeat(" ", c.Width-len(content)) + content
+	} else {
+		content = content + strings.Repeat(" ", c.Width-len(content))
+	}
+	return strings.Repeat(" ", c.LeftPadding) + content + strings.Repeat(" ", c.RightPadding)
+}
+
+func headerLine(columns []Column) string {
+	var builder strings.Builder
+	builder.WriteString("|")
+	for _, column := range columns {
+		builder.WriteString(column.Header())
+		builder.WriteString("|")
+	}
+	return builder.String()
+}
+
+// linesMatch compares an output line with a compare file line, `*` in expected matches any
+// character.
+func linesMatch(expected string, actual string) bool {
+	expected = strings.TrimRight(expected, " \t\r")
+	actual = strings.TrimRight(actual, " \t\r")
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := 0; i < len(expected); i++ {
+		if expected[i] != '*' && expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
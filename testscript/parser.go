@@ -0,0 +1,267 @@
+package testscript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type CommandType uint8
+
+const (
+	SimpleCommandType CommandType = iota
+	RepeatCommandType
+	WhileCommandType
+)
+
+// Command is a single script command like `set RAM[0] 256`, or a repeat/while block.
+type Command struct {
+	CommandType CommandType
+	Name        string
+	Args        []string
+	LineNo      int
+	// Count is the number of iterations of a repeat block, -1 means until the target halts
+	Count     int
+	Condition []string
+	Body      []Command
+}
+
+func (c Command) String() string {
+	switch c.CommandType {
+	case RepeatCommandType:
+		if c.Count < 0 {
+			return "repeat"
+		}
+		return fmt.Sprintf("repeat %d", c.Count)
+	case WhileCommandType:
+		return "while " + strings.Join(c.Condition, " ")
+	}
+	if len(c.Args) == 0 {
+		return c.Name
+	}
+	return c.Name + " " + strings.Join(c.Args, " ")
+}
+
+type Script struct {
+	Commands []Command
+}
+
+type scriptToken struct {
+	content string
+	lineNo  int
+	// isString is true for a quoted string, content doesn't include the quotes
+	isString bool
+}
+
+func (t scriptToken) is(content string) bool {
+	return !t.isString && t.content == content
+}
+
+func isTerminator(content string) bool {
+	return content == "," || content == ";" || content == "!"
+}
+
+func tokenize(reader io.Reader) ([]scriptToken, error) {
+	tokens := make([]scriptToken, 0)
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	inComment := false
+	for scanner.Scan() {
+		lineNo++
+		line := []rune(scanner.Text())
+		i := 0
+		for i < len(line) {
+			r := line[i]
+			if inComment {
+				if r == '*' && i+1 < len(line) && line[i+1] == '/' {
+					inComment = false
+					i++
+				}
+				i++
+				continue
+			}
+
+			switch {
+			case r == ' ' || r == '\t' || r == '\r':
+				i++
+			case r == '/' && i+1 < len(line) && line[i+1] == '/':
+				i = len(line)
+			case r == '/' && i+1 < len(line) && line[i+1] == '*':
+				inComment = true
+				i += 2
+			case r == ',' || r == ';' || r == '!' || r == '{' || r == '}':
+				tokens = append(tokens, scriptToken{content: string(r), lineNo: lineNo})
+				i++
+			case r == '"':
+				end := i + 1
+				for end < len(line) && line[end] != '"' {
+					end++
+				}
+				if end == len(line) {
+					return tokens, fmt.Errorf("line %d: unterminated string", lineNo)
+				}
+				tokens = append(tokens, scriptToken{content: string(line[i+1 : end]), lineNo: lineNo, isString: true})
+				i = end + 1
+			default:
+				start := i
+				for i < len(line) && !strings.ContainsRune(" \t\r,;!{}", line[i]) {
+					i++
+				}
+				tokens = append(tokens, scriptToken{content: string(line[start:i]), lineNo: lineNo})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return tokens, err
+	}
+	if inComment {
+		return tokens, fmt.Errorf("line %d: unterminated comment", lineNo)
+	}
+	return tokens, nil
+}
+
+// Parse parses a nand2tetris test script.
+func Parse(reader io.Reader) (*Script, error) {
+	tokens, err := tokenize(reader)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	commands, err := p.parseCommands(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{Commands: commands}, nil
+}
+
+type parser struct {
+	tokens   []scriptToken
+	position int
+}
+
+func (p *parser) hasMore() bool {
+	return p.position < len(p.tokens)
+}
+
+func (p *parser) current() scriptToken {
+	return p.tokens[p.position]
+}
+
+func (p *parser) lastLineNo() int {
+	if len(p.tokens) == 0 {
+		return 0
+	}
+	return p.tokens[len(p.tokens)-1].lineNo
+}
+
+func (p *parser) parseCommands(inBlock bool) ([]Command, error) {
+	commands := make([]Command, 0)
+	for p.hasMore() {
+		token := p.current()
+		if token.is("}") {
+			if !inBlock {
+				return commands, fmt.Errorf("line %d: unexpected `}`", token.lineNo)
+			}
+			p.position++
+			return commands, nil
+		}
+		if isTerminator(token.content) && !token.isString {
+			// empty command
+			p.position++
+			continue
+		}
+
+		var command Command
+		var err error
+		switch {
+		case token.is("repeat"):
+			command, err = p.parseRepeat()
+		case token.is("while"):
+			command, err = p.parseWhile()
+		default:
+			command, err = p.parseSimpleCommand()
+		}
+		if err != nil {
+			return commands, err
+		}
+		commands = append(commands, command)
+	}
+	if inBlock {
+		return commands, fmt.Errorf("line %d: expected `}` but reach end of script", p.lastLineNo())
+	}
+	return commands, nil
+}
+
+func (p *parser) parseSimpleCommand() (Command, error) {
+	token := p.current()
+	command := Command{CommandType: SimpleCommandType, Name: token.content, LineNo: token.lineNo, Args: make([]string, 0)}
+	p.position++
+	for p.hasMore() {
+		token = p.current()
+		if !token.isString && isTerminator(token.content) {
+			p.position++
+			return command, nil
+		}
+		if token.is("{") || token.is("}") {
+			return command, fmt.Errorf("line %d: unexpected `%s` in command %s", token.lineNo, token.content, command.Name)
+		}
+		command.Args = append(command.Args, token.content)
+		p.position++
+	}
+	// the last command of a script may omit its terminator
+	return command, nil
+}
+
+func (p *parser) parseRepeat() (Command, error) {
+	token := p.current()
+	command := Command{CommandType: RepeatCommandType, Name: token.content, LineNo: token.lineNo, Count: -1}
+	p.position++
+	if !p.hasMore() {
+		return command, fmt.Errorf("line %d: expected `{` after repeat", token.lineNo)
+	}
+	if !p.current().is("{") {
+		count, err := strconv.Atoi(p.current().content)
+		if err != nil || count < 0 {
+			return command, fmt.Errorf("line %d: invalid repeat count %s", p.current().lineNo, p.current().content)
+		}
+		command.Count = count
+		p.position++
+	}
+	if !p.hasMore() || !p.current().is("{") {
+		return command, fmt.Errorf("line %d: expected `{` after repeat", token.lineNo)
+	}
+	p.position++
+
+	body, err := p.parseCommands(true)
+	if err != nil {
+		return command, err
+	}
+	command.Body = body
+	return command, nil
+}
+
+func (p *parser) parseWhile() (Command, error) {
+	token := p.current()
+	command := Command{CommandType: WhileCommandType, Name: token.content, LineNo: token.lineNo}
+	p.position++
+	for p.hasMore() && !p.current().is("{") {
+		command.Condition = append(command.Condition, p.current().content)
+		p.position++
+	}
+	if !p.hasMore() {
+		return command, fmt.Errorf("line %d: expected `{` after while", token.lineNo)
+	}
+	if len(command.Condition) != 3 {
+		return command, fmt.Errorf("line %d: expected a condition like `RAM[0] <> 0` after while", token.lineNo)
+	}
+	p.position++
+
+	body, err := p.parseCommands(true)
+	if err != nil {
+		return command, err
+	}
+	command.Body = body
+	return command, nil
+}
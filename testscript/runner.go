@@ -0,0 +1,382 @@
+package testscript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxSteps bounds a `repeat` block without count, the block normally ends when the
+// target halts.
+const DefaultMaxSteps = 10000000
+
+// Mismatch is returned when an output line differs from the compare file.
+type Mismatch struct {
+	LineNo   int
+	Expected string
+	Actual   string
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("comparison failure at line %d\nexpected: %s\nactual:   %s", m.LineNo, m.Expected, m.Actual)
+}
+
+type Runner struct {
+	target      Target
+	dir         string
+	outputDir   string
+	maxSteps    int
+	echo        io.Writer
+	columns     []Column
+	output      []string
+	outputFile  string
+	compare     []string
+	compareFile string
+}
+
+// NewRunner creates a runner for target, file names in the script are relative to dir.
+func NewRunner(target Target, dir string) *Runner {
+	return &Runner{
+		target:   target,
+		dir:      dir,
+		maxSteps: DefaultMaxSteps,
+		echo:     io.Discard,
+		output:   make([]string, 0),
+	}
+}
+
+// SetOutputDir writes the output file into dir instead of the script directory.
+func (r *Runner) SetOutputDir(dir string) {
+	r.outputDir = dir
+}
+
+func (r *Runner) SetMaxSteps(maxSteps int) {
+	r.maxSteps = maxSteps
+}
+
+// SetEcho sets where `echo` messages are printed.
+func (r *Runner) SetEcho(w io.Writer) {
+	r.echo = w
+}
+
+// Output returns the lines produced by `output-list` and `output`.
+func (r *Runner) Output() []string {
+	return r.output
+}
+
+// RunFile runs the script at path. Like the nand2tetris tools, a script without output-file or
+// compare-to writes NAME.out and compares it with NAME.cmp, where NAME is the script name
+// without a trailing VME.
+func RunFile(target Target, path string, outputDir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	script, err := Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".tst"), "VME")
+	r := NewRunner(target, dir)
+	if outputDir != "" {
+		r.SetOutputDir(outputDir)
+	}
+	r.outputFile = name + ".out"
+	if _, err := os.Stat(filepath.Join(dir, name+".cmp")); err == nil {
+		err = r.setCompareFile(name + ".cmp")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.Run(script)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Run executes script, the output file is written even when the comparison fails.
+func (r *Runner) Run(script *Script) error {
+	err := r.runCommands(script.Commands)
+	if err == nil && r.compareFile != "" && len(r.output) < len(r.compare) {
+		err = &Mismatch{LineNo: len(r.output) + 1, Expected: r.compare[len(r.output)], Actual: ""}
+	}
+	if writeErr := r.writeOutput(); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func (r *Runner) writeOutput() error {
+	if r.outputFile == "" {
+		return nil
+	}
+	dir := r.dir
+	if r.outputDir != "" {
+		dir = r.outputDir
+	}
+	f, err := os.Create(filepath.Join(dir, r.outputFile))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range r.output {
+		_, err = w.WriteString(line + "\n")
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *Runner) runCommands(commands []Command) error {
+	for _, command := range commands {
+		var err error
+		switch command.CommandType {
+		case RepeatCommandType:
+			err = r.runRepeat(command)
+		case WhileCommandType:
+			err = r.runWhile(command)
+		default:
+			err = r.runCommand(command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runRepeat(command Command) error {
+	if command.Count >= 0 {
+		for i := 0; i < command.Count; i++ {
+			if err := r.runCommands(command.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; !r.target.Halted(); i++ {
+		if i >= r.maxSteps {
+			return fmt.Errorf("line %d: repeat didn't finish after %d iterations", command.LineNo, r.maxSteps)
+		}
+		if err := r.runCommands(command.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runWhile(command Command) error {
+	for i := 0; ; i++ {
+		ok, err := r.evaluate(command.Condition)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", command.LineNo, err)
+		}
+		if !ok {
+			return nil
+		}
+		if i >= r.maxSteps {
+			return fmt.Errorf("line %d: while didn't finish after %d iterations", command.LineNo, r.maxSteps)
+		}
+		if err := r.runCommands(command.Body); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Runner) evaluate(condition []string) (bool, error) {
+	left, err := r.operand(condition[0])
+	if err != nil {
+		return false, err
+	}
+	right, err := r.operand(condition[2])
+	if err != nil {
+		return false, err
+	}
+	switch condition[1] {
+	case "=":
+		return left == right, nil
+	case "<>":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "<=":
+		return left <= right, nil
+	case ">=":
+		return left >= right, nil
+	}
+	return false, fmt.Errorf("unknown operator %s", condition[1])
+}
+
+func (r *Runner) operand(content string) (int16, error) {
+	if value, err := parseValue(content); err == nil {
+		return value, nil
+	}
+	return r.target.Get(content)
+}
+
+// parseValue parses a decimal number, or a number prefixed with %B, %X or %D.
+func parseValue(content string) (int16, error) {
+	base := 10
+	digits := content
+	if strings.HasPrefix(content, "%") && len(content) > 2 {
+		switch content[1] {
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		case 'D':
+			base = 10
+		default:
+			return 0, fmt.Errorf("invalid value %s", content)
+		}
+		digits = content[2:]
+	}
+	if base != 10 {
+		value, err := strconv.ParseUint(digits, base, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %s", content)
+		}
+		return int16(value), nil
+	}
+	value, err := strconv.ParseInt(digits, 10, 32)
+	if err != nil || value < -32768 || value > 65535 {
+		return 0, fmt.Errorf("invalid value %s", content)
+	}
+	return int16(value), nil
+}
+
+func (r *Runner) runCommand(command Command) error {
+	err := r.execute(command)
+	if err != nil {
+		if _, ok := err.(*Mismatch); ok {
+			return err
+		}
+		return fmt.Errorf("line %d: %s: %w", command.LineNo, command, err)
+	}
+	return nil
+}
+
+func (r *Runner) execute(command Command) error {
+	switch command.Name {
+	case "load":
+		if len(command.Args) > 1 {
+			return fmt.Errorf("expected at most one file name")
+		}
+		path := r.dir
+		if len(command.Args) == 1 {
+			path = filepath.Join(r.dir, command.Args[0])
+		}
+		return r.target.Load(path)
+	case "output-file":
+		if len(command.Args) != 1 {
+			return fmt.Errorf("expected a file name")
+		}
+		r.outputFile = command.Args[0]
+		return nil
+	case "compare-to":
+		if len(command.Args) != 1 {
+			return fmt.Errorf("expected a file name")
+		}
+		return r.setCompareFile(command.Args[0])
+	case "output-list":
+		columns := make([]Column, 0, len(command.Args))
+		for _, arg := range command.Args {
+			column, err := ParseColumn(arg)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, column)
+		}
+		r.columns = columns
+		return r.writeLine(headerLine(columns))
+	case "output":
+		return r.outputValues()
+	case "set":
+		if len(command.Args) != 2 {
+			return fmt.Errorf("expected a variable and a value")
+		}
+		value, err := parseValue(command.Args[1])
+		if err != nil {
+			return err
+		}
+		return r.target.Set(command.Args[0], value)
+	case "echo":
+		_, err := fmt.Fprintln(r.echo, strings.Join(command.Args, " "))
+		return err
+	case "clear-echo":
+		return nil
+	case "ticktock", "tick", "tock", "vmstep":
+		return r.target.Step(command.Name)
+	}
+	return fmt.Errorf("unknown command")
+}
+
+func (r *Runner) setCompareFile(name string) error {
+	f, err := os.Open(filepath.Join(r.dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	r.compareFile = name
+	r.compare = lines
+	return nil
+}
+
+func (r *Runner) outputValues() error {
+	if r.columns == nil {
+		return fmt.Errorf("output is used before output-list")
+	}
+	var builder strings.Builder
+	builder.WriteString("|")
+	for _, column := range r.columns {
+		value, err := r.target.Get(column.Variable)
+		if err != nil {
+			return err
+		}
+		builder.WriteString(column.Cell(value))
+		builder.WriteString("|")
+	}
+	return r.writeLine(builder.String())
+}
+
+func (r *Runner) writeLine(line string) error {
+	r.output = append(r.output, line)
+	if r.compareFile == "" {
+		return nil
+	}
+	lineNo := len(r.output)
+	if lineNo > len(r.compare) {
+		return &Mismatch{LineNo: lineNo, Expected: "", Actual: line}
+	}
+	if !linesMatch(r.compare[lineNo-1], line) {
+		return &Mismatch{LineNo: lineNo, Expected: r.compare[lineNo-1], Actual: line}
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package testscript
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hack/assembler"
+	"hack/compiler"
+	"hack/cpu"
+	"hack/vm/emulator"
+	"hack/vm/translator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	source := `// comment
+load Sys.vm,
+output-list RAM[0]%D1.6.1 RAM[256]%D1.6.1;
+set RAM[0] 256, /* block
+comment */
+repeat 3 {
+	vmstep;
+}
+while RAM[0] <> 0 {
+	ticktock;
+}
+echo "hello world";
+repeat {
+	vmstep;
+}
+output;`
+	script, err := Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("failed to parse script: %v", err)
+	}
+
+	expected := []string{
+		"load Sys.vm",
+		"output-list RAM[0]%D1.6.1 RAM[256]%D1.6.1",
+		"set RAM[0] 256",
+		"repeat 3",
+		"while RAM[0] <> 0",
+		"echo hello world",
+		"repeat",
+		"output",
+	}
+	if len(script.Commands) != len(expected) {
+		t.Fatalf("expected %d commands, got %d", len(expected), len(script.Commands))
+	}
+	for i, command := range script.Commands {
+		if command.String() != expected[i] {
+			t.Fatalf("command %d: expected %q, got %q", i, expected[i], command.String())
+		}
+	}
+	if script.Commands[3].Count != 3 || len(script.Commands[3].Body) != 1 {
+		t.Fatalf("unexpected repeat block %+v", script.Commands[3])
+	}
+	if script.Commands[6].Count != -1 {
+		t.Fatalf("expected repeat without count, got %d", script.Commands[6].Count)
+	}
+	if script.Commands[4].LineNo != 9 {
+		t.Fatalf("expected while at line 9, got %d", script.Commands[4].LineNo)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	sources := []string{
+		"repeat 3 { vmstep;",
+		"vmstep; }",
+		"while RAM[0] { vmstep; }",
+		"repeat x { vmstep; }",
+		"echo \"hello;",
+		"/* comment",
+	}
+	for _, source := range sources {
+		_, err := Parse(strings.NewReader(source))
+		if err == nil {
+			t.Fatalf("expected error for %q", source)
+		}
+	}
+}
+
+func TestColumn(t *testing.T) {
+	tests := []struct {
+		spec   string
+		value  int16
+		header string
+		cell   string
+	}{
+		{"RAM[0]%D1.6.1", 257, " RAM[0] ", "    257 "},
+		{"RAM[8000]%D2.6.1", -1, "RAM[8000]", "      -1 "},
+		{"A%B1.16.1", 5, "        A         ", " 0000000000000101 "},
+		{"D%X1.4.1", -1, "  D   ", " FFFF "},
+	}
+	for _, test := range tests {
+		column, err := ParseColumn(test.spec)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", test.spec, err)
+		}
+		if column.Header() != test.header {
+			t.Fatalf("%s: expected header %q, got %q", test.spec, test.header, column.Header())
+		}
+		if column.Cell(test.value) != test.cell {
+			t.Fatalf("%s: expected cell %q, got %q", test.spec, test.cell, column.Cell(test.value))
+		}
+	}
+}
+
+func TestRunner_Mismatch(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "Test.cmp"), []byte("| RAM[0] |\n|    258 |\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "Test.vm"), []byte("push constant 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := `load Test.vm,
+output-file Test.out,
+compare-to Test.cmp,
+output-list RAM[0]%D1.6.1;
+set sp 256,
+vmstep,
+output;`
+	script, err := Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("failed to parse script: %v", err)
+	}
+
+	r := NewRunner(NewVMTarget(emulator.New()), dir)
+	err = r.Run(script)
+	var mismatch *Mismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	if mismatch.LineNo != 2 || mismatch.Expected != "|    258 |" || mismatch.Actual != "|    257 |" {
+		t.Fatalf("unexpected mismatch %+v", mismatch)
+	}
+	output, err := os.ReadFile(filepath.Join(dir, "Test.out"))
+	if err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+	if string(output) != "| RAM[0] |\n|    257 |\n" {
+		t.Fatalf("unexpected output %q", output)
+	}
+}
+
+func TestRunner_While(t *testing.T) {
+	source := `set sp 256,
+output-list RAM[0]%D1.6.1;
+while sp < 260 {
+	vmstep;
+}
+echo "done";
+output;`
+	script, err := Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("failed to parse script: %v", err)
+	}
+	e := emulator.New()
+	program := "function Sys.init 0\nlabel LOOP\npush constant 1\ngoto LOOP\n"
+	file, err := emulator.Parse("Sys", strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Load([]emulator.File{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var echo bytes.Buffer
+	r := NewRunner(NewVMTarget(e), t.TempDir())
+	r.SetEcho(&echo)
+	err = r.Run(script)
+	if err != nil {
+		t.Fatalf("failed to run script: %v", err)
+	}
+	if echo.String() != "done\n" {
+		t.Fatalf("unexpected echo %q", echo.String())
+	}
+	output := r.Output()
+	if len(output) != 2 || output[1] != "|    260 |" {
+		t.Fatalf("unexpected output %q", output)
+	}
+}
+
+func TestRunner_Chapter8VME(t *testing.T) {
+	paths, err := filepath.Glob("../ch8/*/*VME.tst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test scripts found")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			err := RunFile(NewVMTarget(emulator.New()), path, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestRunner_Chapter7 runs misc/ch7.tst, which sets the pointers and steps until the end, on each
+// chapter 7 program and checks the values of its .cmp file.
+func TestRunner_Chapter7(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected map[string]int16
+	}{
+		{"SimpleAdd.vm", map[string]int16{"RAM[0]": 257, "RAM[256]": 15}},
+		{"StackTest.vm", map[string]int16{"RAM[0]": 266, "RAM[256]": -1, "RAM[257]": 0, "RAM[260]": -1, "RAM[265]": -91}},
+		{"BasicTest.vm", map[string]int16{"RAM[256]": 472, "RAM[300]": 10, "RAM[401]": 21, "RAM[402]": 22, "RAM[3006]": 36, "RAM[3012]": 42, "RAM[3015]": 45, "RAM[11]": 510}},
+		{"PointerTest.vm", map[string]int16{"RAM[256]": 6084, "RAM[3]": 3030, "RAM[4]": 3040, "RAM[3032]": 32, "RAM[3046]": 46}},
+		{"StaticTest.vm", map[string]int16{"RAM[256]": 1110}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			target := NewVMTarget(emulator.New())
+			err := target.Load(filepath.Join("../ch7", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = RunFile(target, "../misc/ch7.tst", t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for variable, value := range tt.expected {
+				actual, err := target.Get(variable)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != value {
+					t.Fatalf("expected %s to be %d, got %d", variable, value, actual)
+				}
+			}
+		})
+	}
+}
+
+// compileJack compiles the OS together with the .jack files of the test directory, the VM
+// emulator loads them without writing .vm files next to the sources.
+func compileJack(path string) ([]emulator.File, error) {
+	osPaths, err := filepath.Glob("../os/*.jack")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(path, "*.jack"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]emulator.File, 0)
+	for _, p := range append(osPaths, paths...) {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		class, err := compiler.NewFileEngine(p, f).CompileClass()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		err = compiler.NewVmWriter(&buf, class).Write()
+		if err != nil {
+			return nil, err
+		}
+		file, err := emulator.Parse(strings.TrimSuffix(filepath.Base(p), ".jack"), &buf)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func TestRunner_Chapter12(t *testing.T) {
+	for _, name := range []string{"ArrayTest", "MathTest", "MemoryTest", "MemoryTest/MemoryDiag"} {
+		t.Run(name, func(t *testing.T) {
+			script, err := os.Open(filepath.Join("../ch12", name, filepath.Base(name)+".tst"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer script.Close()
+			s, err := Parse(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := NewVMTarget(emulator.New())
+			target.SetLoader(compileJack)
+			r := NewRunner(target, filepath.Join("../ch12", name))
+			r.SetOutputDir(t.TempDir())
+			err = r.Run(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// translateVM translates the .vm files of dir into one assembly program, bootstrapped when
+// one of them is Sys.vm, with the shared routines if shared and optimized if optimize.
+func translateVM(dir string, shared bool, optimize bool) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]translator.Source, 0, len(paths))
+	bootstrap := false
+	for _, p := range paths {
+		code, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, translator.Source{Name: filepath.Base(p), Reader: bytes.NewReader(code)})
+		bootstrap = bootstrap || filepath.Base(p) == "Sys.vm"
+	}
+	var asm bytes.Buffer
+	err = translator.Translate(context.Background(), sources, &asm, translator.Options{Bootstrap: bootstrap, Shared: shared, Optimize: optimize, Link: bootstrap})
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(asm.String(), "\n"), nil
+}
+
+// TestRunner_Chapter8CPU runs the CPU scripts on the translated programs, with and without the
+// shared routines, as is and optimized, in parallel since each of them is assembled with its own
+// symbol table.
+func TestRunner_Chapter8CPU(t *testing.T) {
+	paths, err := filepath.Glob("../ch8/*/*.tst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "VME.tst") {
+			continue
+		}
+		for _, shared := range []bool{false, true} {
+			for _, optimize := range []bool{false, true} {
+				name := filepath.Base(path)
+				if shared {
+					name += "_shared"
+				}
+				if optimize {
+					name += "_optimized"
+				}
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+					asm, err := translateVM(filepath.Dir(path), shared, optimize)
+					if err != nil {
+						t.Fatal(err)
+					}
+					commands, err := assembler.Parse(asm)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if optimize {
+						var stats assembler.OptimizeStats
+						commands, stats = assembler.Optimize(commands)
+						if stats.After >= stats.Before {
+							t.Fatalf("expected fewer instructions, got %s", stats)
+						}
+					}
+					instructions, err := assembler.New().Translate(commands)
+					if err != nil {
+						t.Fatal(err)
+					}
+					target := NewCPUTarget(cpu.New())
+					err = target.CPU().LoadInstructions(instructions)
+					if err != nil {
+						t.Fatal(err)
+					}
+					err = RunFile(target, path, t.TempDir())
+					if err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package testscript
+
+import (
+	"fmt"
+	"hack/assembler"
+	"hack/cpu"
+	"hack/vm/emulator"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Target is the machine a script drives, either the CPU emulator or the VM emulator.
+type Target interface {
+	Load(path string) error
+	Get(variable string) (int16, error)
+	Set(variable string, value int16) error
+	// Step runs a simulation command such as `ticktock` or `vmstep`
+	Step(command string) error
+	// Halted reports whether the program reached its end, it stops `repeat` blocks without count
+	Halted() bool
+}
+
+// parseVariable splits `RAM[16]` into `RAM` and 16, index is -1 for a variable without index.
+func parseVariable(variable string) (string, int, error) {
+	open := strings.Index(variable, "[")
+	if open < 0 {
+		return variable, -1, nil
+	}
+	if !strings.HasSuffix(variable, "]") {
+		return "", 0, fmt.Errorf("invalid variable %s", variable)
+	}
+	index, err := strconv.Atoi(variable[open+1 : len(variable)-1])
+	if err != nil || index < 0 {
+		return "", 0, fmt.Errorf("invalid variable %s", variable)
+	}
+	return variable[:open], index, nil
+}
+
+type CPUTarget struct {
+	cpu *cpu.CPU
+}
+
+func NewCPUTarget(c *cpu.CPU) *CPUTarget {
+	return &CPUTarget{cpu: c}
+}
+
+func (t *CPUTarget) CPU() *cpu.CPU {
+	return t.cpu
+}
+
+// Load loads a .hack file, or assembles and loads a .asm file.
+func (t *CPUTarget) Load(path string) error {
+	switch filepath.Ext(path) {
+	case ".hack":
+		return t.cpu.LoadHackFile(path)
+	case ".asm":
+		lines, err := assembler.ExpandFile(path)
+		if err != nil {
+			return err
+		}
+		commands, err := assembler.ParseSource(lines)
+		if err != nil {
+			return err
+		}
+		instructions, err := assembler.Translate(commands)
+		if err != nil {
+			return err
+		}
+		return t.cpu.LoadInstructions(instructions)
+	}
+	return fmt.Errorf("CPU emulator can't load %s, expect a .hack or .asm file", path)
+}
+
+func (t *CPUTarget) Get(variable string) (int16, error) {
+	name, index, err := parseVariable(variable)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case name == "RAM" && index >= 0:
+		return t.cpu.Peek(index)
+	case name == "ROM" && index >= 0:
+		word, err := t.cpu.ROM(index)
+		return int16(word), err
+	case name == "A" && index < 0:
+		return t.cpu.A(), nil
+	case name == "D" && index < 0:
+		return t.cpu.D(), nil
+	case name == "PC" && index < 0:
+		return int16(t.cpu.PC()), nil
+	case name == "time" && index < 0:
+		return int16(t.cpu.Cycles()), nil
+	}
+	return 0, fmt.Errorf("unknown variable %s", variable)
+}
+
+func (t *CPUTarget) Set(variable string, value int16) error {
+	name, index, err := parseVariable(variable)
+	if err != nil {
+		return err
+	}
+	switch {
+	case name == "RAM" && index >= 0:
+		return t.cpu.Poke(index, value)
+	case name == "A" && index < 0:
+		t.cpu.SetA(value)
+		return nil
+	case name == "D" && index < 0:
+		t.cpu.SetD(value)
+		return nil
+	case name == "PC" && index < 0:
+		t.cpu.SetPC(uint16(value))
+		return nil
+	}
+	return fmt.Errorf("unknown variable %s", variable)
+}
+
+// Step supports `ticktock`, and `tick` followed by `tock` for a single clock cycle.
+func (t *CPUTarget) Step(command string) error {
+	switch command {
+	case "ticktock", "tock":
+		return t.cpu.Step()
+	case "tick":
+		return nil
+	}
+	return fmt.Errorf("CPU emulator doesn't support %s", command)
+}
+
+// Halted detects the `(END) @END 0;JMP` idiom used to end Hack programs.
+func (t *CPUTarget) Halted() bool {
+	pc := int(t.cpu.PC())
+	current, _ := t.cpu.ROM(pc)
+	next, err := t.cpu.ROM(pc + 1)
+	if err != nil {
+		return false
+	}
+	unconditionalJump := uint16(0b1110101010000111)
+	return int(current) == pc && next == unconditionalJump
+}
+
+// VMLoader reads the program for a `load` command, path is a .vm file or a directory.
+type VMLoader func(path string) ([]emulator.File, error)
+
+// LoadVMFiles is the default VMLoader reading .vm files.
+func LoadVMFiles(path string) ([]emulator.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return emulator.ParseDir(path)
+	}
+	file, err := emulator.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []emulator.File{file}, nil
+}
+
+type VMTarget struct {
+	emulator *emulator.Emulator
+	loader   VMLoader
+}
+
+func NewVMTarget(e *emulator.Emulator) *VMTarget {
+	return &VMTarget{emulator: e, loader: LoadVMFiles}
+}
+
+func (t *VMTarget) Emulator() *emulator.Emulator {
+	return t.emulator
+}
+
+// SetLoader replaces how `load` finds the program, e.g. to compile .jack files first.
+func (t *VMTarget) SetLoader(loader VMLoader) {
+	t.loader = loader
+}
+
+func (t *VMTarget) Load(path string) error {
+	files, err := t.loader(path)
+	if err != nil {
+		return err
+	}
+	return t.emulator.Load(files)
+}
+
+// vmPointers maps the pointer variables of VM scripts to their address, both in the segment
+// spelling and in the register spelling of the VM emulator scripts such as misc/ch7.tst.
+var vmPointers = map[string]int{
+	"sp":       emulator.SP,
+	"local":    emulator.LCL,
+	"argument": emulator.ARG,
+	"this":     emulator.THIS,
+	"that":     emulator.THAT,
+	"SP":       emulator.SP,
+	"LCL":      emulator.LCL,
+	"ARG":      emulator.ARG,
+	"THIS":     emulator.THIS,
+	"THAT":     emulator.THAT,
+}
+
+func (t *VMTarget) address(variable string) (int, error) {
+	name, index, err := parseVariable(variable)
+	if err != nil {
+		return 0, err
+	}
+	if name == "RAM" && index >= 0 {
+		return index, nil
+	}
+	if index < 0 {
+		address, ok := vmPointers[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown variable %s", variable)
+		}
+		return address, nil
+	}
+	switch name {
+	case "local", "argument", "this", "that", "temp", "pointer", "static":
+		return t.emulator.SegmentAddress(name, int64(index))
+	}
+	return 0, fmt.Errorf("unknown variable %s", variable)
+}
+
+func (t *VMTarget) Get(variable string) (int16, error) {
+	address, err := t.address(variable)
+	if err != nil {
+		return 0, err
+	}
+	return t.emulator.Peek(address)
+}
+
+func (t *VMTarget) Set(variable string, value int16) error {
+	address, err := t.address(variable)
+	if err != nil {
+		return err
+	}
+	return t.emulator.Poke(address, value)
+}
+
+func (t *VMTarget) Step(command string) error {
+	if command != "vmstep" {
+		return fmt.Errorf("VM emulator doesn't support %s", command)
+	}
+	return t.emulator.Step()
+}
+
+func (t *VMTarget) Halted() bool {
+	return t.emulator.Halted()
+}
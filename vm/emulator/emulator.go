@@ -0,0 +1,503 @@
+package emulator
+
+import (
+	"fmt"
+	"hack/vm/translator"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	RAMSize = 32768
+
+	SP   = 0
+	LCL  = 1
+	ARG  = 2
+	THIS = 3
+	THAT = 4
+
+	TempBase    = 5
+	TempSize    = 8
+	StaticBase  = 16
+	StaticLimit = 256
+	StackBase   = 256
+)
+
+// File is a parsed .vm file, Name decides the static segment the commands work on.
+type File struct {
+	Name     string
+	Commands []translator.VmCommand
+}
+
+// ParseFile reads the .vm file at path.
+func ParseFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	return Parse(strings.TrimSuffix(filepath.Base(path), ".vm"), f)
+}
+
+// Parse reads VM commands from reader, name is the file name without the .vm extension. The
+// error is a source.ErrorList of every invalid line, see translator.Parse.
+func Parse(name string, reader io.Reader) (File, error) {
+	commands, err := translator.Parse(name+".vm", reader)
+	return File{Name: name, Commands: commands}, err
+}
+
+// ParseDir reads every .vm file inside dir, ordered by file name.
+func ParseDir(dir string) ([]File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(paths))
+	for _, path := range paths {
+		file, err := ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+type instruction struct {
+	command  translator.VmCommand
+	file     string
+	function string
+}
+
+// Emulator executes VM commands directly on a Hack-like RAM, segments are mapped to the same
+// addresses the translated assembly uses so both can be compared word by word.
+type Emulator struct {
+	ram          [RAMSize]int16
+	program      []instruction
+	functions    map[string]int
+	labels       map[string]int
+	staticBases  map[string]int
+	pc           int
+	currentFunc  string
+	halted       bool
+	steps        int64
+	programFiles []string
+}
+
+func New() *Emulator {
+	return &Emulator{
+		functions:   make(map[string]int),
+		labels:      make(map[string]int),
+		staticBases: make(map[string]int),
+	}
+}
+
+// Load replaces the current program with files. Execution starts at Sys.init when it is
+// defined, called the same way the bootstrap code does, otherwise at the first command of the first file.
+func (e *Emulator) Load(files []File) error {
+	e.program = make([]instruction, 0)
+	e.functions = make(map[string]int)
+	e.labels = make(map[string]int)
+	e.staticBases = make(map[string]int)
+	e.programFiles = make([]string, 0, len(files))
+
+	nextStatic := StaticBase
+	for _, file := range files {
+		if _, ok := e.staticBases[file.Name]; ok {
+			return fmt.Errorf("file %s is loaded multiple times", file.Name)
+		}
+		e.programFiles = append(e.programFiles, file.Name)
+
+		function := ""
+		staticCount := 0
+		for _, cmd := range file.Commands {
+			switch cmd.CommandType() {
+			case translator.C_COMMENT, translator.C_BLANKLINE:
+				continue
+			case translator.C_FUNCTION:
+				function = cmd.Arg1()
+				if _, ok := e.functions[function]; ok {
+					return fmt.Errorf("function %s is defined multiple times", function)
+				}
+				e.functions[function] = len(e.program)
+			case translator.C_LABEL:
+				key := labelKey(file.Name, function, cmd.Arg1())
+				if _, ok := e.labels[key]; ok {
+					return fmt.Errorf("label %s is defined multiple times in %s", cmd.Arg1(), scopeName(file.Name, function))
+				}
+				e.labels[key] = len(e.program)
+			case translator.C_PUSH, translator.C_POP:
+				if cmd.Arg1() == "static" && int(cmd.Arg2())+1 > staticCount {
+					staticCount = int(cmd.Arg2()) + 1
+				}
+			}
+			e.program = append(e.program, instruction{command: cmd, file: file.Name, function: function})
+		}
+
+		e.staticBases[file.Name] = nextStatic
+		nextStatic += staticCount
+		if nextStatic > StaticLimit {
+			return fmt.Errorf("static segment overflow when loading %s", file.Name)
+		}
+	}
+
+	e.Reset()
+	if _, ok := e.functions["Sys.init"]; ok {
+		// like the bootstrap code, call Sys.init on an empty stack, the program halts when it returns
+		e.ram[SP] = StackBase
+		_, err := e.call("Sys.init", 0, len(e.program))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset moves execution back to the entry point, the RAM is kept as is.
+func (e *Emulator) Reset() {
+	e.pc = 0
+	e.currentFunc = ""
+	if entry, ok := e.functions["Sys.init"]; ok {
+		e.pc = entry
+		e.currentFunc = "Sys.init"
+	}
+	e.halted = len(e.program) == 0
+	e.steps = 0
+}
+
+func labelKey(file string, function string, label string) string {
+	return scopeName(file, function) + "$" + label
+}
+
+func scopeName(file string, function string) string {
+	if function == "" {
+		return file
+	}
+	return function
+}
+
+func (e *Emulator) Halted() bool {
+	return e.halted
+}
+
+func (e *Emulator) Steps() int64 {
+	return e.steps
+}
+
+// CurrentFunction returns the name of the function being executed, empty when the program
+// has no function declaration.
+func (e *Emulator) CurrentFunction() string {
+	return e.currentFunc
+}
+
+// CurrentCommand returns the command executed by the next Step.
+func (e *Emulator) CurrentCommand() (translator.VmCommand, bool) {
+	e.skipLabels()
+	if e.halted {
+		return translator.VmCommand{}, false
+	}
+	return e.program[e.pc].command, true
+}
+
+func (e *Emulator) Peek(address int) (int16, error) {
+	if address < 0 || address >= RAMSize {
+		return 0, fmt.Errorf("RAM address %d is out of range", address)
+	}
+	return e.ram[address], nil
+}
+
+func (e *Emulator) Poke(address int, value int16) error {
+	if address < 0 || address >= RAMSize {
+		return fmt.Errorf("RAM address %d is out of range", address)
+	}
+	e.ram[address] = value
+	return nil
+}
+
+// SegmentAddress returns the RAM address of segment[index] in the current state.
+func (e *Emulator) SegmentAddress(segment string, index int64) (int, error) {
+	if index < 0 {
+		return 0, fmt.Errorf("invalid index %d for %s segment", index, segment)
+	}
+	switch segment {
+	case "local":
+		return e.address(int64(e.ram[LCL]) + index)
+	case "argument":
+		return e.address(int64(e.ram[ARG]) + index)
+	case "this":
+		return e.address(int64(e.ram[THIS]) + index)
+	case "that":
+		return e.address(int64(e.ram[THAT]) + index)
+	case "pointer":
+		if index > 1 {
+			return 0, fmt.Errorf("invalid index %d for pointer segment", index)
+		}
+		return THIS + int(index), nil
+	case "temp":
+		if index >= TempSize {
+			return 0, fmt.Errorf("invalid index %d for temp segment", index)
+		}
+		return TempBase + int(index), nil
+	case "static":
+		file := ""
+		if !e.halted && e.pc < len(e.program) {
+			file = e.program[e.pc].file
+		} else if len(e.programFiles) > 0 {
+			file = e.programFiles[0]
+		}
+		base, ok := e.staticBases[file]
+		if !ok {
+			return 0, fmt.Errorf("no static segment for file %s", file)
+		}
+		return e.address(int64(base) + index)
+	}
+	return 0, fmt.Errorf("segment %s has no address", segment)
+}
+
+func (e *Emulator) address(address int64) (int, error) {
+	if address < 0 || address >= RAMSize {
+		return 0, fmt.Errorf("RAM address %d is out of range", address)
+	}
+	return int(address), nil
+}
+
+func (e *Emulator) skipLabels() {
+	for !e.halted && e.program[e.pc].command.CommandType() == translator.C_LABEL {
+		e.jumpTo(e.pc + 1)
+	}
+}
+
+func (e *Emulator) jumpTo(pc int) {
+	e.pc = pc
+	if e.pc >= len(e.program) {
+		e.halted = true
+	}
+}
+
+// Step executes one VM command, labels are not counted as commands.
+func (e *Emulator) Step() error {
+	e.skipLabels()
+	if e.halted {
+		return nil
+	}
+
+	current := e.program[e.pc]
+	cmd := current.command
+	next := e.pc + 1
+	var err error
+	switch cmd.CommandType() {
+	case translator.C_PUSH:
+		err = e.push(current, cmd.Arg1(), cmd.Arg2())
+	case translator.C_POP:
+		err = e.pop(current, cmd.Arg1(), cmd.Arg2())
+	case translator.C_ARITHMETIC:
+		err = e.arithmetic(cmd.Arg1())
+	case translator.C_GOTO:
+		next, err = e.label(current, cmd.Arg1())
+	case translator.C_IF:
+		var value int16
+		value, err = e.popValue()
+		if err == nil && value != 0 {
+			next, err = e.label(current, cmd.Arg1())
+		}
+	case translator.C_FUNCTION:
+		e.currentFunc = cmd.Arg1()
+		for i := int64(0); i < cmd.Arg2() && err == nil; i++ {
+			err = e.pushValue(0)
+		}
+	case translator.C_CALL:
+		next, err = e.call(cmd.Arg1(), cmd.Arg2(), next)
+	case translator.C_RETURN:
+		next, err = e.ret()
+	default:
+		err = fmt.Errorf("unsupported command %s", cmd)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", scopeName(current.file, current.function), strings.TrimSpace(cmd.String()), err)
+	}
+
+	e.steps++
+	e.jumpTo(next)
+	return nil
+}
+
+// Run executes at most maxSteps commands and stops early when the program halts.
+func (e *Emulator) Run(maxSteps int) (int, error) {
+	for i := 0; i < maxSteps; i++ {
+		if e.halted {
+			return i, nil
+		}
+		if err := e.Step(); err != nil {
+			return i, err
+		}
+	}
+	return maxSteps, nil
+}
+
+func (e *Emulator) pushValue(value int16) error {
+	sp, err := e.address(int64(e.ram[SP]))
+	if err != nil {
+		return fmt.Errorf("stack overflow: %w", err)
+	}
+	e.ram[sp] = value
+	e.ram[SP]++
+	return nil
+}
+
+func (e *Emulator) popValue() (int16, error) {
+	sp := int(e.ram[SP]) - 1
+	if sp < StackBase || sp >= RAMSize {
+		return 0, fmt.Errorf("stack underflow, SP is %d", e.ram[SP])
+	}
+	e.ram[SP]--
+	return e.ram[sp], nil
+}
+
+func (e *Emulator) segmentAddress(current instruction, segment string, index int64) (int, error) {
+	if segment == "static" {
+		if index < 0 {
+			return 0, fmt.Errorf("invalid index %d for static segment", index)
+		}
+		return e.address(int64(e.staticBases[current.file]) + index)
+	}
+	return e.SegmentAddress(segment, index)
+}
+
+func (e *Emulator) push(current instruction, segment string, index int64) error {
+	if segment == "constant" {
+		if index < 0 || index > 32767 {
+			return fmt.Errorf("invalid constant %d", index)
+		}
+		return e.pushValue(int16(index))
+	}
+	address, err := e.segmentAddress(current, segment, index)
+	if err != nil {
+		return err
+	}
+	return e.pushValue(e.ram[address])
+}
+
+func (e *Emulator) pop(current instruction, segment string, index int64) error {
+	if segment == "constant" {
+		return fmt.Errorf("constant doesn't support pop command")
+	}
+	address, err := e.segmentAddress(current, segment, index)
+	if err != nil {
+		return err
+	}
+	value, err := e.popValue()
+	if err != nil {
+		return err
+	}
+	e.ram[address] = value
+	return nil
+}
+
+func boolValue(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+func (e *Emulator) arithmetic(command string) error {
+	switch command {
+	case "neg", "not":
+		value, err := e.popValue()
+		if err != nil {
+			return err
+		}
+		if command == "neg" {
+			return e.pushValue(-value)
+		}
+		return e.pushValue(^value)
+	}
+
+	y, err := e.popValue()
+	if err != nil {
+		return err
+	}
+	x, err := e.popValue()
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "add":
+		return e.pushValue(x + y)
+	case "sub":
+		return e.pushValue(x - y)
+	case "eq":
+		return e.pushValue(boolValue(x == y))
+	case "gt":
+		return e.pushValue(boolValue(x > y))
+	case "lt":
+		return e.pushValue(boolValue(x < y))
+	case "and":
+		return e.pushValue(x & y)
+	case "or":
+		return e.pushValue(x | y)
+	}
+	return fmt.Errorf("unsupported command %s", command)
+}
+
+func (e *Emulator) label(current instruction, label string) (int, error) {
+	pc, ok := e.labels[labelKey(current.file, current.function, label)]
+	if !ok {
+		return 0, fmt.Errorf("label %s is not defined in %s", label, scopeName(current.file, current.function))
+	}
+	return pc, nil
+}
+
+func (e *Emulator) call(function string, args int64, returnAddress int) (int, error) {
+	entry, ok := e.functions[function]
+	if !ok {
+		return 0, fmt.Errorf("function %s is not defined", function)
+	}
+	if returnAddress > 32767 {
+		return 0, fmt.Errorf("return address %d doesn't fit in a word", returnAddress)
+	}
+
+	for _, value := range []int16{int16(returnAddress), e.ram[LCL], e.ram[ARG], e.ram[THIS], e.ram[THAT]} {
+		if err := e.pushValue(value); err != nil {
+			return 0, err
+		}
+	}
+	e.ram[ARG] = e.ram[SP] - 5 - int16(args)
+	e.ram[LCL] = e.ram[SP]
+	e.currentFunc = function
+	return entry, nil
+}
+
+func (e *Emulator) ret() (int, error) {
+	frame := int64(e.ram[LCL])
+	if frame < 5 {
+		return 0, fmt.Errorf("invalid frame at %d", frame)
+	}
+	returnAddress := int(e.ram[frame-5])
+
+	value, err := e.popValue()
+	if err != nil {
+		return 0, err
+	}
+	arg, err := e.address(int64(e.ram[ARG]))
+	if err != nil {
+		return 0, err
+	}
+	e.ram[arg] = value
+	e.ram[SP] = int16(arg + 1)
+	e.ram[THAT] = e.ram[frame-1]
+	e.ram[THIS] = e.ram[frame-2]
+	e.ram[ARG] = e.ram[frame-3]
+	e.ram[LCL] = e.ram[frame-4]
+
+	if returnAddress < 0 || returnAddress > len(e.program) {
+		return 0, fmt.Errorf("invalid return address %d", returnAddress)
+	}
+	if returnAddress < len(e.program) {
+		e.currentFunc = e.program[returnAddress].function
+	}
+	return returnAddress, nil
+}
@@ -0,0 +1,185 @@
+package emulator
+
+import (
+	"hack/assembler"
+	"hack/cpu"
+	"hack/vm/translator"
+	"strings"
+	"testing"
+)
+
+func parseFiles(t *testing.T, paths ...string) []File {
+	t.Helper()
+	files := make([]File, 0)
+	for _, path := range paths {
+		file, err := ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func testRAM(t *testing.T, e *Emulator, expected map[int]int16) {
+	t.Helper()
+	for address, value := range expected {
+		actual, err := e.Peek(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != value {
+			t.Fatalf("expecting RAM[%d] = %d, got %d", address, value, actual)
+		}
+	}
+}
+
+func TestEmulator_BasicLoop(t *testing.T) {
+	e := New()
+	err := e.Load(parseFiles(t, "../../ch8/BasicLoop/BasicLoop.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = e.Poke(SP, 256)
+	_ = e.Poke(LCL, 300)
+	_ = e.Poke(ARG, 400)
+	_ = e.Poke(400, 3)
+
+	_, err = e.Run(33)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRAM(t, e, map[int]int16{0: 257, 256: 6})
+}
+
+func TestEmulator_SimpleFunction(t *testing.T) {
+	e := New()
+	err := e.Load(parseFiles(t, "../../ch8/SimpleFunction/SimpleFunction.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, value := range map[int]int16{0: 317, 1: 317, 2: 310, 3: 3000, 4: 4000, 310: 1234, 311: 37, 312: 9, 313: 305, 314: 300, 315: 3010, 316: 4010} {
+		_ = e.Poke(address, value)
+	}
+
+	_, err = e.Run(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRAM(t, e, map[int]int16{0: 311, 1: 305, 2: 300, 3: 3010, 4: 4010, 310: 1196})
+}
+
+func TestEmulator_StaticsTest(t *testing.T) {
+	files, err := ParseDir("../../ch8/StaticsTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New()
+	err = e.Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.CurrentFunction() != "Sys.init" {
+		t.Fatalf("expecting to start at Sys.init, got %s", e.CurrentFunction())
+	}
+	_ = e.Poke(SP, 261)
+
+	_, err = e.Run(36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRAM(t, e, map[int]int16{0: 263, 261: -2, 262: 8})
+}
+
+func TestEmulator_Errors(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"function Main.main 0\ncall Main.foo 0\n", "function Main.foo is not defined"},
+		{"function Main.main 0\ngoto END\n", "label END is not defined in Main.main"},
+		{"function Main.main 0\nadd\n", "stack underflow"},
+	}
+
+	for _, test := range tests {
+		parser := translator.NewParser(strings.NewReader(test.code))
+		file := File{Name: "Main"}
+		for parser.HasMoreCommands() {
+			err := parser.Advance()
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Commands = append(file.Commands, parser.CurrentCommand())
+		}
+
+		e := New()
+		err := e.Load([]File{file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = e.Poke(SP, 256)
+		_, err = e.Run(10)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("expecting error %q, got %v", test.expected, err)
+		}
+	}
+}
+
+// TestEmulator_MatchesTranslator runs the same program on the emulator and on the CPU with the
+// assembly generated by translator.Writer, both must leave the same result on the stack.
+func TestEmulator_MatchesTranslator(t *testing.T) {
+	files := parseFiles(t, "../../ch8/FibonacciElement/Sys.vm", "../../ch8/FibonacciElement/Main.vm")
+
+	e := New()
+	err := e.Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = e.Poke(SP, 261)
+	_, err = e.Run(110)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := int64(0)
+	lines := make([]string, 0)
+	for _, file := range files {
+		w := translator.NewWriter(file.Name+".vm", counter)
+		for _, cmd := range file.Commands {
+			asms, err := w.Write(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, asms...)
+		}
+		counter = w.Counter()
+	}
+	commands, err := assembler.Parse(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := assembler.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cpu.New()
+	err = c.LoadInstructions(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sys.vm starts with `call Sys.init 0`, which pushes a frame of 5 words the emulator skips
+	_ = c.Poke(SP, 256)
+	_, err = c.Run(6000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []int{SP, 261} {
+		expected, _ := e.Peek(address)
+		actual, _ := c.Peek(address)
+		if expected != actual {
+			t.Fatalf("expecting RAM[%d] = %d on both machines, emulator got %d, cpu got %d", address, expected, expected, actual)
+		}
+	}
+	testRAM(t, e, map[int]int16{0: 262, 261: 3})
+}
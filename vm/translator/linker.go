@@ -0,0 +1,137 @@
+package translator
+
+import "hack/compiler/source"
+
+// Function is a function of a program.
+type Function struct {
+	// Command is the function command declaring it
+	Command VmCommand
+	// Arguments is the number of arguments the function uses: the largest index of its argument
+	// segment plus 1
+	Arguments int64
+	// Callers are the call commands calling the function, in the order of the files
+	Callers []VmCommand
+}
+
+// Name returns the name of the function, such as Main.main.
+func (f *Function) Name() string {
+	return f.Command.Arg1()
+}
+
+// Program is the function table of the files of a program.
+type Program struct {
+	// Functions are the functions in the order they are declared
+	Functions []*Function
+	byName    map[string]*Function
+}
+
+// Function returns the function declared with name.
+func (p *Program) Function(name string) (*Function, bool) {
+	f, ok := p.byName[name]
+	return f, ok
+}
+
+// labelScope is where a label can be jumped to from: a function, or the commands of a file that
+// come before its first function. pos is where the function is declared, so that a function
+// declared twice has two scopes.
+type labelScope struct {
+	pos      source.Pos
+	function string
+}
+
+func (s labelScope) String() string {
+	if s.function == "" {
+		return s.pos.File
+	}
+	return s.function
+}
+
+// jump is a goto or if-goto command and its scope.
+type jump struct {
+	command VmCommand
+	scope   labelScope
+}
+
+// Link builds the function table of a program, files[i] being the commands of a file, and checks
+// the program is complete. The error is a source.ErrorList of the functions declared twice or
+// called without being declared, and of the labels declared twice or jumped to without being
+// declared in the same function.
+func Link(files [][]VmCommand) (*Program, error) {
+	p := &Program{byName: make(map[string]*Function)}
+	var errs source.ErrorList
+	labels := make(map[labelScope]map[string]VmCommand)
+	jumps := make([]jump, 0)
+	calls := make([]VmCommand, 0)
+	for _, commands := range files {
+		var function *Function
+		scope := labelScope{}
+		if len(commands) > 0 {
+			scope.pos.File = commands[0].file
+		}
+		for _, command := range commands {
+			switch command.commandType {
+			case C_FUNCTION:
+				function = &Function{Command: command}
+				scope = labelScope{pos: command.Pos(), function: command.arg1}
+				if first, ok := p.byName[command.arg1]; ok {
+					errs.Add(command.errorf("function %s is already declared at %s", command.arg1, first.Command.Pos()))
+					continue
+				}
+				p.Functions = append(p.Functions, function)
+				p.byName[command.arg1] = function
+			case C_PUSH, C_POP:
+				if command.arg1 == "argument" && function != nil && command.arg2 >= function.Arguments {
+					function.Arguments = command.arg2 + 1
+				}
+			case C_LABEL:
+				if labels[scope] == nil {
+					labels[scope] = make(map[string]VmCommand)
+				}
+				if first, ok := labels[scope][command.arg1]; ok {
+					errs.Add(command.errorf("label %s is already declared at %s", command.arg1, first.Pos()))
+					continue
+				}
+				labels[scope][command.arg1] = command
+			case C_GOTO, C_IF:
+				jumps = append(jumps, jump{command: command, scope: scope})
+			case C_CALL:
+				calls = append(calls, command)
+			}
+		}
+	}
+
+	for _, j := range jumps {
+		if _, ok := labels[j.scope][j.command.arg1]; !ok {
+			errs.Add(j.command.errorf("label %s is not defined in %s", j.command.arg1, j.scope))
+		}
+	}
+	for _, call := range calls {
+		f, ok := p.byName[call.arg1]
+		if !ok {
+			errs.Add(call.errorf("function %s is not defined", call.arg1))
+			continue
+		}
+		f.Callers = append(f.Callers, call)
+	}
+	errs.Sort()
+	return p, errs.Err()
+}
+
+// Warnings returns the calls whose number of arguments is less than what the function uses, or
+// differs from its first call.
+func (p *Program) Warnings() source.ErrorList {
+	var warnings source.ErrorList
+	for _, f := range p.Functions {
+		for _, call := range f.Callers {
+			switch {
+			case call.arg2 < f.Arguments:
+				warnings.Add(call.errorf("%s is called with %d arguments but uses %d", f.Name(), call.arg2, f.Arguments))
+			case call.arg2 != f.Callers[0].arg2:
+				first := f.Callers[0]
+				warnings.Add(call.errorf("%s is called with %d arguments but with %d at %s", f.Name(), call.arg2, first.arg2, first.Pos()))
+			}
+		}
+	}
+	warnings.Sort()
+	return warnings
+}
@@ -0,0 +1,140 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseFiles(t *testing.T, files map[string]string) [][]VmCommand {
+	t.Helper()
+	res := make([][]VmCommand, 0, len(files))
+	for _, name := range []string{"Main.vm", "Sys.vm"} {
+		code, ok := files[name]
+		if !ok {
+			continue
+		}
+		commands, err := Parse(name, strings.NewReader(code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, commands)
+	}
+	return res
+}
+
+func TestLink(t *testing.T) {
+	files := parseFiles(t, map[string]string{
+		"Main.vm": "function Main.main 0\npush constant 1\npush constant 2\ncall Main.add 2\nreturn\n" +
+			"function Main.add 0\npush argument 0\npush argument 1\nadd\nreturn\n",
+		"Sys.vm": "function Sys.init 0\ncall Main.main 0\nlabel END\ngoto END\n",
+	})
+	program, err := Link(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Functions) != 3 {
+		t.Fatalf("expected 3 functions, got %d", len(program.Functions))
+	}
+	add, ok := program.Function("Main.add")
+	if !ok || add.Arguments != 2 || len(add.Callers) != 1 || add.Callers[0].Pos().String() != "Main.vm:4:1" {
+		t.Fatalf("expected Main.add to use 2 arguments and be called at Main.vm:4:1, got %+v", add)
+	}
+	if warnings := program.Warnings(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %s", warnings)
+	}
+}
+
+func TestLink_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			"undefined function",
+			map[string]string{"Main.vm": "function Main.main 0\ncall Main.foo 0\nreturn\n"},
+			[]string{"Main.vm:2:1: function Main.foo is not defined"},
+		},
+		{
+			"duplicate function",
+			map[string]string{
+				"Main.vm": "function Main.main 0\nreturn\n",
+				"Sys.vm":  "function Sys.init 0\nreturn\nfunction Main.main 0\nreturn\n",
+			},
+			[]string{"Sys.vm:3:1: function Main.main is already declared at Main.vm:1:1"},
+		},
+		{
+			"undefined label",
+			map[string]string{"Main.vm": "function Main.main 0\nlabel END\nfunction Main.loop 0\ngoto END\n"},
+			[]string{"Main.vm:4:1: label END is not defined in Main.loop"},
+		},
+		{
+			"undefined label outside functions",
+			map[string]string{"Main.vm": "if-goto END\nfunction Main.main 0\nlabel END\n"},
+			[]string{"Main.vm:1:1: label END is not defined in Main.vm"},
+		},
+		{
+			"duplicate label",
+			map[string]string{"Main.vm": "function Main.main 0\nlabel END\nlabel END\n"},
+			[]string{"Main.vm:3:1: label END is already declared at Main.vm:2:1"},
+		},
+		{
+			"labels of a duplicate function",
+			map[string]string{"Main.vm": "function Main.main 0\nlabel L\nfunction Main.main 0\nlabel L\ngoto L\n"},
+			[]string{"Main.vm:3:1: function Main.main is already declared at Main.vm:1:1"},
+		},
+		{
+			"every error",
+			map[string]string{
+				"Main.vm": "function Main.main 0\ncall Sys.foo 0\ngoto L\n",
+				"Sys.vm":  "function Sys.init 0\ncall Main.bar 0\n",
+			},
+			[]string{
+				"Main.vm:2:1: function Sys.foo is not defined",
+				"Main.vm:3:1: label L is not defined in Main.main",
+				"Sys.vm:2:1: function Main.bar is not defined",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Link(parseFiles(t, tt.files))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			lines := make([]string, 0)
+			for _, line := range strings.Split(err.Error(), "\n") {
+				if strings.Contains(line, ".vm:") {
+					lines = append(lines, line)
+				}
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Fatalf("expected %q, got %q", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestProgram_Warnings(t *testing.T) {
+	files := parseFiles(t, map[string]string{
+		"Main.vm": "function Main.main 0\ncall Main.f 2\ncall Main.f 3\ncall Main.f 1\nreturn\n" +
+			"function Main.f 0\npush argument 1\nreturn\n",
+	})
+	program, err := Link(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Main.vm:3:1: Main.f is called with 3 arguments but with 2 at Main.vm:2:1",
+		"Main.vm:4:1: Main.f is called with 1 arguments but uses 2",
+	}
+	warnings := program.Warnings()
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, got %s", len(expected), warnings)
+	}
+	for i, warning := range warnings {
+		if !strings.HasPrefix(warning.Error(), expected[i]+"\n") {
+			t.Fatalf("expected %q, got %q", expected[i], warning)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxConstant is the largest value of `push constant`.
+const maxConstant = 1<<15 - 1
+
+// OptimizeStats counts the functions and commands of a program before and after Optimize.
+type OptimizeStats struct {
+	FunctionsBefore int
+	FunctionsAfter  int
+	CommandsBefore  int
+	CommandsAfter   int
+}
+
+func (s OptimizeStats) String() string {
+	return fmt.Sprintf("%d functions instead of %d, %d commands instead of %d",
+		s.FunctionsAfter, s.FunctionsBefore, s.CommandsAfter, s.CommandsBefore)
+}
+
+// Optimize rewrites the commands of the files of a program, files[i] being the commands of a
+// file, to equivalent ones translating to less assembly:
+//
+//	push constant 2; push constant 3; add    folded to push constant 5, when in range
+//	push constant 0; add                     removed, as well as with sub and or
+//	push local 0; pop local 1                a C_COPY, which doesn't go through the stack
+//	not; if-goto L                           a C_IF_NOT, jumping when the top isn't -1
+//
+// When the program defines Sys.init, the functions it can't call, directly or not, are removed
+// too. Comments and blank lines are removed.
+func Optimize(files [][]VmCommand) ([][]VmCommand, OptimizeStats) {
+	stats := OptimizeStats{}
+	res := make([][]VmCommand, len(files))
+	for i, commands := range files {
+		res[i] = make([]VmCommand, 0, len(commands))
+		for _, command := range commands {
+			if command.commandType != C_COMMENT && command.commandType != C_BLANKLINE {
+				res[i] = append(res[i], command)
+			}
+		}
+		stats.FunctionsBefore += countFunctions(res[i])
+		stats.CommandsBefore += len(res[i])
+	}
+
+	res = removeDeadFunctions(res)
+	for i, commands := range res {
+		for changed := true; changed; {
+			commands, changed = optimizeCommands(commands)
+		}
+		res[i] = commands
+		stats.FunctionsAfter += countFunctions(commands)
+		stats.CommandsAfter += len(commands)
+	}
+	return res, stats
+}
+
+func countFunctions(commands []VmCommand) int {
+	n := 0
+	for _, command := range commands {
+		if command.commandType == C_FUNCTION {
+			n++
+		}
+	}
+	return n
+}
+
+// removeDeadFunctions removes the functions Sys.init doesn't call, directly or not. A function
+// is its `function` command up to the next one.
+func removeDeadFunctions(files [][]VmCommand) [][]VmCommand {
+	calls := make(map[string][]string)
+	for _, commands := range files {
+		function := ""
+		for _, command := range commands {
+			switch command.commandType {
+			case C_FUNCTION:
+				function = command.arg1
+				calls[function] = calls[function]
+			case C_CALL:
+				calls[function] = append(calls[function], command.arg1)
+			}
+		}
+	}
+	if _, ok := calls["Sys.init"]; !ok {
+		return files
+	}
+
+	// the commands before the first function can't be removed, nor what they call
+	reachable := make(map[string]bool)
+	pending := []string{"", "Sys.init"}
+	for len(pending) > 0 {
+		function := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[function] {
+			continue
+		}
+		reachable[function] = true
+		pending = append(pending, calls[function]...)
+	}
+
+	res := make([][]VmCommand, len(files))
+	for i, commands := range files {
+		res[i] = make([]VmCommand, 0, len(commands))
+		keep := true
+		for _, command := range commands {
+			if command.commandType == C_FUNCTION {
+				keep = reachable[command.arg1]
+			}
+			if keep {
+				res[i] = append(res[i], command)
+			}
+		}
+	}
+	return res
+}
+
+// optimizeCommands applies each rewrite once, it reports whether one applied.
+func optimizeCommands(commands []VmCommand) ([]VmCommand, bool) {
+	res := make([]VmCommand, 0, len(commands))
+	changed := false
+	for i := 0; i < len(commands); i++ {
+		c := commands[i]
+		next := VmCommand{}
+		if i+1 < len(commands) {
+			next = commands[i+1]
+		}
+		afterNext := VmCommand{}
+		if i+2 < len(commands) {
+			afterNext = commands[i+2]
+		}
+
+		switch {
+		case isConstant(c) && isConstant(next) && afterNext.commandType == C_ARITHMETIC:
+			if value, ok := fold(c.arg2, next.arg2, afterNext.arg1); ok {
+				folded := c
+				folded.arg2 = value
+				folded.raw = fmt.Sprintf("push constant %d", value)
+				res = append(res, folded)
+				i += 2
+				changed = true
+				continue
+			}
+		case isConstant(c) && c.arg2 == 0 && next.commandType == C_ARITHMETIC:
+			if next.arg1 == "add" || next.arg1 == "sub" || next.arg1 == "or" {
+				i++
+				changed = true
+				continue
+			}
+		case c.commandType == C_PUSH && next.commandType == C_POP:
+			copied := next
+			copied.commandType = C_COPY
+			copied.sourceSegment, copied.sourceIndex = c.arg1, c.arg2
+			copied.raw = strings.TrimSpace(c.raw) + "; " + strings.TrimSpace(next.raw)
+			copied.file, copied.lineNo, copied.column = c.file, c.lineNo, c.column
+			res = append(res, copied)
+			i++
+			changed = true
+			continue
+		case c.commandType == C_ARITHMETIC && c.arg1 == "not" && next.commandType == C_IF:
+			fused := c
+			fused.commandType = C_IF_NOT
+			fused.arg1 = next.arg1
+			fused.raw = strings.TrimSpace(c.raw) + "; " + strings.TrimSpace(next.raw)
+			res = append(res, fused)
+			i++
+			changed = true
+			continue
+		}
+		res = append(res, c)
+	}
+	return res, changed
+}
+
+func isConstant(c VmCommand) bool {
+	return c.commandType == C_PUSH && c.arg1 == "constant"
+}
+
+// fold computes `push constant x; push constant y; command`, it reports whether the result can
+// be pushed as a constant.
+func fold(x int64, y int64, command string) (int64, bool) {
+	var value int64
+	switch command {
+	case "add":
+		value = x + y
+	case "sub":
+		value = x - y
+	case "and":
+		value = x & y
+	case "or":
+		value = x | y
+	default:
+		return 0, false
+	}
+	return value, value >= 0 && value <= maxConstant
+}
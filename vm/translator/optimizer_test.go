@@ -0,0 +1,136 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"hack/assembler"
+	"hack/cpu"
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			"constant folding",
+			"push constant 2\npush constant 3\nadd\npush constant 4\nsub\n",
+			[]string{"push constant 1"},
+		},
+		{
+			"out of range constants aren't folded",
+			"push constant 2\npush constant 3\nsub\n",
+			[]string{"push constant 2", "push constant 3", "sub"},
+		},
+		{
+			"adding 0",
+			"push local 0\npush constant 0\nadd\n",
+			[]string{"push local 0"},
+		},
+		{
+			"push and pop",
+			"push local 0\npop this 1\n",
+			[]string{"push local 0; pop this 1"},
+		},
+		{
+			"not and if-goto",
+			"push argument 0\nnot\nif-goto END\n",
+			[]string{"push argument 0", "not; if-goto END"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := Parse("Main.vm", strings.NewReader(tt.code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, _ := Optimize([][]VmCommand{commands})
+			actual := make([]string, len(files[0]))
+			for i, command := range files[0] {
+				actual[i] = command.String()
+			}
+			if strings.Join(actual, "\n") != strings.Join(tt.expected, "\n") {
+				t.Fatalf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOptimize_DeadFunctions(t *testing.T) {
+	main := "function Main.main 0\ncall Main.used 0\nreturn\nfunction Main.used 0\nreturn\nfunction Main.unused 0\ncall Main.used 0\nreturn\n"
+	sys := "function Sys.init 0\ncall Main.main 0\nreturn\n"
+	files := make([][]VmCommand, 0)
+	for _, code := range []string{main, sys} {
+		commands, err := Parse("Main.vm", strings.NewReader(code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, commands)
+	}
+
+	res, stats := Optimize(files)
+	if stats.FunctionsBefore != 4 || stats.FunctionsAfter != 3 {
+		t.Fatalf("expected 3 functions out of 4, got %s", stats)
+	}
+	for _, command := range res[0] {
+		if command.commandType == C_FUNCTION && command.arg1 == "Main.unused" {
+			t.Fatal("expected Main.unused to be removed")
+		}
+	}
+
+	// without Sys.init, every function is kept
+	res, stats = Optimize(files[:1])
+	if stats.FunctionsAfter != 3 || len(res[0]) != len(files[0]) {
+		t.Fatalf("expected every function to be kept, got %s", stats)
+	}
+}
+
+// runCode translates and runs code on the CPU until it runs past its last instruction, it returns
+// temp 0.
+func runCode(t *testing.T, code string, opts Options) int16 {
+	t.Helper()
+	var asm bytes.Buffer
+	err := Translate(context.Background(), []Source{{Name: "Main.vm", Reader: strings.NewReader(code)}}, &asm, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := assembler.Parse(strings.Split(strings.TrimSuffix(asm.String(), "\n"), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := assembler.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cpu.New()
+	err = c.LoadInstructions(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Poke(0, 256)
+	for steps := 0; int(c.PC()) < c.ProgramSize(); steps++ {
+		if steps == 10000 {
+			t.Fatal("expected the program to end")
+		}
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	temp, _ := c.Peek(int(TempOffset))
+	return temp
+}
+
+// TestOptimize_IfNot checks `not; if-goto` jumps as unoptimized whatever the value, not only for
+// the booleans 0 and -1.
+func TestOptimize_IfNot(t *testing.T) {
+	for _, value := range []string{"constant 0", "constant 1", "constant 5", "constant 0\nnot"} {
+		code := "push " + value + "\nnot\nif-goto SKIP\npush constant 100\npop temp 0\nlabel SKIP\n"
+		expected := runCode(t, code, Options{})
+		if actual := runCode(t, code, Options{Optimize: true}); actual != expected {
+			t.Fatalf("push %s: expected temp 0 = %d, got %d optimized", value, expected, actual)
+		}
+	}
+}
@@ -3,7 +3,8 @@ package translator
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"hack/compiler/source"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -13,145 +14,259 @@ type Parser struct {
 	currentCommand VmCommand
 	nextLine       string
 	hasNextLine    bool
+	// file names the file in the position of the commands and errors, it may be empty
+	file string
+	// lineNo is the 1-based line of nextLine
+	lineNo int
 }
 
-func NewParser(file *os.File) *Parser {
-	scanner := bufio.NewScanner(bufio.NewReader(file))
+func NewParser(reader io.Reader) *Parser {
+	return NewFileParser("", reader)
+}
+
+// NewFileParser returns a parser of the .vm file named file, such as Main.vm, read from reader.
+func NewFileParser(file string, reader io.Reader) *Parser {
+	scanner := bufio.NewScanner(bufio.NewReader(reader))
 	hasNextLine := scanner.Scan()
 	nextLine := scanner.Text()
-	return &Parser{scanner: scanner, nextLine: nextLine, hasNextLine: hasNextLine}
+	return &Parser{scanner: scanner, nextLine: nextLine, hasNextLine: hasNextLine, file: file, lineNo: 1}
 }
 func (p *Parser) HasMoreCommands() bool {
 	//return p.nextLine != ""
 	return p.hasNextLine
 }
+
+// Advance parses the next line. The error is a *source.Error, the parser moves to the following
+// line anyway so that the errors of every line can be reported.
 func (p *Parser) Advance() error {
 	cmd, err := parseCommand(p.nextLine)
 	if err != nil {
-		return err
+		err.Pos.File = p.file
+		err.Pos.Line = p.lineNo
 	}
-
+	cmd.file = p.file
+	cmd.lineNo = p.lineNo
 	p.currentCommand = cmd
+	p.lineNo++
 	if p.scanner.Scan() {
 		p.nextLine = p.scanner.Text()
 	} else {
 		p.hasNextLine = false
 	}
+	// not err itself, a nil *source.Error isn't a nil error
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func parseCommand(line string) (VmCommand, error) {
-	tokens := make([]string, 0)
+// Err returns the error that stopped reading the lines, if any.
+func (p *Parser) Err() error {
+	return p.scanner.Err()
+}
 
-	processedLine := strings.Replace(line, "\t", " ", -1)
+// Parse returns the commands of the .vm file named file read from reader. The error is a
+// source.ErrorList of every invalid line, or the error reading reader.
+func Parse(file string, reader io.Reader) ([]VmCommand, error) {
+	commands := make([]VmCommand, 0)
+	var errs source.ErrorList
+	parser := NewFileParser(file, reader)
+	for parser.HasMoreCommands() {
+		if err := parser.Advance(); err != nil {
+			errs.Add(err.(*source.Error))
+			continue
+		}
+		commands = append(commands, parser.CurrentCommand())
+	}
+	if err := parser.Err(); err != nil {
+		return nil, err
+	}
+	return commands, errs.Err()
+}
 
-	for _, token := range strings.Split(processedLine, " ") {
-		if token == "" {
+// field is a word of a line and its 1-based column.
+type field struct {
+	text   string
+	column int
+}
+
+// fields splits line, without its comment, into words separated by spaces and tabs.
+func fields(line string) []field {
+	res := make([]field, 0, 3)
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			if start < 0 {
+				start = i
+			}
 			continue
 		}
-		tokens = append(tokens, token)
+		if start >= 0 {
+			res = append(res, field{text: line[start:i], column: start + 1})
+			start = -1
+		}
 	}
+	return res
+}
+
+// maxIndexes are the largest index of each segment: the static variables of a program are
+// RAM[16] to RAM[255], the other segments are addressed with 15 bits.
+var maxIndexes = map[string]int64{
+	"constant": 1<<15 - 1,
+	"local":    1<<15 - 1,
+	"argument": 1<<15 - 1,
+	"this":     1<<15 - 1,
+	"that":     1<<15 - 1,
+	"temp":     7,
+	"pointer":  1,
+	"static":   239,
+}
+
+// arguments describes the arguments of every command.
+var arguments = map[string][]string{
+	"push":     {"segment", "index"},
+	"pop":      {"segment", "index"},
+	"add":      {},
+	"sub":      {},
+	"neg":      {},
+	"eq":       {},
+	"gt":       {},
+	"lt":       {},
+	"and":      {},
+	"or":       {},
+	"not":      {},
+	"label":    {"label"},
+	"if-goto":  {"label"},
+	"goto":     {"label"},
+	"function": {"function name", "number of local variables"},
+	"call":     {"function name", "number of arguments"},
+	"return":   {},
+}
+
+func parseCommand(line string) (VmCommand, *source.Error) {
+	code := line
+	if i := strings.Index(line, "//"); i >= 0 {
+		code = line[:i]
+	}
+	tokens := fields(code)
 	if len(tokens) == 0 {
+		if code != line {
+			return VmCommand{commandType: C_COMMENT, raw: line}, nil
+		}
 		return VmCommand{commandType: C_BLANKLINE, raw: line}, nil
 	}
-	if len(tokens[0]) > 1 && tokens[0][:2] == "//" {
-		return VmCommand{commandType: C_COMMENT, raw: line}, nil
+	raw := strings.TrimRight(code, " \t")
+	errorf := func(column int, format string, args ...any) *source.Error {
+		return source.Errorf(source.Pos{Column: column}, raw, format, args...)
 	}
 
-	switch tokens[0] {
-	case "push":
-		arg1 := tokens[1]
-		err := checkSegment(arg1)
-		if err != nil {
-			return VmCommand{}, err
+	name := tokens[0].text
+	args, ok := arguments[name]
+	if !ok {
+		return VmCommand{}, errorf(tokens[0].column, "invalid command %s", name)
+	}
+	if n := len(args) + 1; len(tokens) > n {
+		return VmCommand{}, errorf(tokens[n].column, "unexpected %s after %s", tokens[n].text, strings.Join(texts(tokens[:n]), " "))
+	}
+	if len(tokens) < len(args)+1 {
+		last := tokens[len(tokens)-1]
+		return VmCommand{}, errorf(last.column+len(last.text), "expected %s", args[len(tokens)-1])
+	}
+
+	cmd := VmCommand{raw: raw, column: tokens[0].column}
+	switch name {
+	case "push", "pop":
+		cmd.commandType = C_PUSH
+		if name == "pop" {
+			cmd.commandType = C_POP
 		}
-		arg2, err := strconv.ParseInt(tokens[2], 10, 64)
-		if err != nil {
-			return VmCommand{}, err
+		segment := tokens[1]
+		maxIndex, ok := maxIndexes[segment.text]
+		if !ok {
+			return VmCommand{}, errorf(segment.column, "invalid segment %s", segment.text)
 		}
-		return VmCommand{commandType: C_PUSH, arg1: arg1, arg2: arg2, raw: line}, nil
-	case "pop":
-		arg1 := tokens[1]
-		err := checkSegment(arg1)
+		if name == "pop" && segment.text == "constant" {
+			return VmCommand{}, errorf(segment.column, "constant doesn't support pop command")
+		}
+		index, err := parseNumber(tokens[2], maxIndex, segment.text+" index")
 		if err != nil {
-			return VmCommand{}, err
+			return VmCommand{}, errorf(tokens[2].column, "%s", err)
 		}
-		if arg1 == "constant" {
-			return VmCommand{}, fmt.Errorf("constant doesn't support pop command")
+		cmd.arg1, cmd.arg2 = segment.text, index
+	case "label", "if-goto", "goto":
+		cmd.commandType = map[string]VmCommandType{"label": C_LABEL, "if-goto": C_IF, "goto": C_GOTO}[name]
+		if !isSymbol(tokens[1].text) {
+			return VmCommand{}, errorf(tokens[1].column, "invalid label %s", tokens[1].text)
 		}
-		arg2, err := strconv.ParseInt(tokens[2], 10, 64)
-		if err != nil {
-			return VmCommand{}, err
+		cmd.arg1 = tokens[1].text
+	case "function", "call":
+		cmd.commandType = C_FUNCTION
+		if name == "call" {
+			cmd.commandType = C_CALL
 		}
-		return VmCommand{commandType: C_POP, arg1: arg1, arg2: arg2, raw: line}, nil
-	case "add":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "add", raw: line}, nil
-	case "sub":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "sub", raw: line}, nil
-	case "neg":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "neg", raw: line}, nil
-	case "eq":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "eq", raw: line}, nil
-	case "gt":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "gt", raw: line}, nil
-	case "lt":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "lt", raw: line}, nil
-	case "and":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "and", raw: line}, nil
-	case "or":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "or", raw: line}, nil
-	case "not":
-		return VmCommand{commandType: C_ARITHMETIC, arg1: "not", raw: line}, nil
-	case "label":
-		arg1 := tokens[1]
-		return VmCommand{commandType: C_LABEL, arg1: arg1, raw: line}, nil
-	case "if-goto":
-		arg1 := tokens[1]
-		return VmCommand{commandType: C_IF, arg1: arg1, raw: line}, nil
-	case "goto":
-		arg1 := tokens[1]
-		return VmCommand{commandType: C_GOTO, arg1: arg1, raw: line}, nil
-	case "function":
-		arg1 := tokens[1]
-		arg2, err := strconv.ParseInt(tokens[2], 10, 64)
-		if err != nil {
-			return VmCommand{}, err
+		if !isSymbol(tokens[1].text) {
+			return VmCommand{}, errorf(tokens[1].column, "invalid function name %s", tokens[1].text)
 		}
-		return VmCommand{commandType: C_FUNCTION, arg1: arg1, arg2: arg2, raw: line}, nil
-	case "call":
-		arg1 := tokens[1]
-		arg2, err := strconv.ParseInt(tokens[2], 10, 64)
+		n, err := parseNumber(tokens[2], 1<<15-1, args[1])
 		if err != nil {
-			return VmCommand{}, err
+			return VmCommand{}, errorf(tokens[2].column, "%s", err)
 		}
-		return VmCommand{commandType: C_CALL, arg1: arg1, arg2: arg2, raw: line}, nil
+		cmd.arg1, cmd.arg2 = tokens[1].text, n
 	case "return":
-		return VmCommand{commandType: C_RETURN, arg1: "return", raw: line}, nil
+		cmd.commandType = C_RETURN
+		cmd.arg1 = "return"
+	default:
+		cmd.commandType = C_ARITHMETIC
+		cmd.arg1 = name
+	}
+	return cmd, nil
+}
+
+// checkSegment checks the segment and index of a push or pop.
+func checkSegment(segment string, index int64) error {
+	maxIndex, ok := maxIndexes[segment]
+	if !ok {
+		return fmt.Errorf("invalid segment %s", segment)
+	}
+	if index < 0 || index > maxIndex {
+		return fmt.Errorf("%s index %d is out of the range 0 to %d", segment, index, maxIndex)
+	}
+	return nil
+}
+
+func texts(tokens []field) []string {
+	res := make([]string, len(tokens))
+	for i, token := range tokens {
+		res[i] = token.text
 	}
+	return res
+}
 
-	return VmCommand{}, fmt.Errorf("invalid line: %s", line)
+// parseNumber parses the decimal number token, description names it in the errors.
+func parseNumber(token field, maxIndex int64, description string) (int64, error) {
+	index, err := strconv.ParseInt(token.text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s, expected a number", description, token.text)
+	}
+	if index < 0 || index > maxIndex {
+		return 0, fmt.Errorf("%s %d is out of the range 0 to %d", description, index, maxIndex)
+	}
+	return index, nil
 }
 
-func checkSegment(segment string) error {
-	switch segment {
-	case "constant":
-		return nil
-	case "local":
-		return nil
-	case "argument":
-		return nil
-	case "this":
-		return nil
-	case "that":
-		return nil
-	case "temp":
-		return nil
-	case "pointer":
-		return nil
-	case "static":
-		return nil
+// isSymbol reports whether s is a valid label or function name: letters, digits, '_', '.' and
+// ':', not starting with a digit.
+func isSymbol(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '.', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
 	}
-	return fmt.Errorf("invalid segment %s", segment)
+	return s != ""
 }
 
 type VmCommandType uint8
@@ -168,6 +283,10 @@ const (
 	C_FUNCTION
 	C_RETURN
 	C_CALL
+	// C_COPY and C_IF_NOT are made by Optimize: C_COPY pops a value pushed from its source
+	// segment and index, C_IF_NOT is `not` followed by `if-goto`
+	C_COPY
+	C_IF_NOT
 )
 
 func (p *Parser) CurrentCommand() VmCommand {
@@ -179,6 +298,13 @@ type VmCommand struct {
 	arg1        string
 	arg2        int64
 	raw         string
+	// file, lineNo and column are where the command was parsed from, lineNo is 0 if it wasn't
+	file   string
+	lineNo int
+	column int
+	// sourceSegment and sourceIndex are what a C_COPY pushes
+	sourceSegment string
+	sourceIndex   int64
 }
 
 func (c VmCommand) CommandType() VmCommandType {
@@ -191,6 +317,21 @@ func (c VmCommand) Arg2() int64 {
 	return c.arg2
 }
 
+// LineNo returns the 1-based line the command was parsed from.
+func (c VmCommand) LineNo() int {
+	return c.lineNo
+}
+
+// Pos returns where the command was parsed from.
+func (c VmCommand) Pos() source.Pos {
+	return source.Pos{File: c.file, Line: c.lineNo, Column: c.column}
+}
+
+// errorf returns an error at the command, with its line for context.
+func (c VmCommand) errorf(format string, args ...any) *source.Error {
+	return source.Errorf(c.Pos(), c.raw, format, args...)
+}
+
 func (c VmCommand) String() string {
 	return c.raw
 }
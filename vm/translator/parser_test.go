@@ -0,0 +1,82 @@
+package translator
+
+import (
+	"hack/compiler/source"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	code := "// Main.vm\n\nfunction Main.main 1 // x\n\tpush constant 7\t// 7\npop local 0//x\nreturn\n"
+	commands, err := Parse("Main.vm", strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		commandType VmCommandType
+		raw         string
+		pos         string
+	}{
+		{C_COMMENT, "// Main.vm", "Main.vm:1:0"},
+		{C_BLANKLINE, "", "Main.vm:2:0"},
+		{C_FUNCTION, "function Main.main 1", "Main.vm:3:1"},
+		{C_PUSH, "\tpush constant 7", "Main.vm:4:2"},
+		{C_POP, "pop local 0", "Main.vm:5:1"},
+		{C_RETURN, "return", "Main.vm:6:1"},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i, command := range commands {
+		if command.CommandType() != expected[i].commandType || command.String() != expected[i].raw || command.Pos().String() != expected[i].pos {
+			t.Fatalf("expected %q at %s, got %q at %s", expected[i].raw, expected[i].pos, command, command.Pos())
+		}
+	}
+	if commands[3].Arg1() != "constant" || commands[3].Arg2() != 7 {
+		t.Fatalf("expected push constant 7, got %s %d", commands[3].Arg1(), commands[3].Arg2())
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"push", "1:5: expected segment"},
+		{"push local", "1:11: expected index"},
+		{"pop", "1:4: expected segment"},
+		{"push local 0 1", "1:14: unexpected 1 after push local 0"},
+		{"add 1", "1:5: unexpected 1 after add"},
+		{"goto", "1:5: expected label"},
+		{"goto 1LOOP", "1:6: invalid label 1LOOP"},
+		{"call Main.main", "1:15: expected number of arguments"},
+		{"function Main.main -1", "1:20: number of local variables -1 is out of the range 0 to 32767"},
+		{"push segment 0", "1:6: invalid segment segment"},
+		{"pop constant 0", "1:5: constant doesn't support pop command"},
+		{"push local x", "1:12: invalid local index x, expected a number"},
+		{"push local -1", "1:12: local index -1 is out of the range 0 to 32767"},
+		{"push constant 32768", "1:15: constant index 32768 is out of the range 0 to 32767"},
+		{"push temp 8", "1:11: temp index 8 is out of the range 0 to 7"},
+		{"pop pointer 2", "1:13: pointer index 2 is out of the range 0 to 1"},
+		{"push static 240", "1:13: static index 240 is out of the range 0 to 239"},
+		{"  foo // bar", "1:3: invalid command foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := Parse("", strings.NewReader(tt.line))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected+"\n") {
+				t.Fatalf("expected an error starting with %q, got %q", tt.expected, err)
+			}
+		})
+	}
+
+	// every invalid line is reported
+	_, err := Parse("Main.vm", strings.NewReader("push\nadd\npop temp 8\n"))
+	errs, ok := err.(source.ErrorList)
+	if !ok || len(errs) != 2 || errs[0].Pos.Line != 1 || errs[1].Pos.Line != 3 {
+		t.Fatalf("expected the errors of lines 1 and 3, got %v", err)
+	}
+}
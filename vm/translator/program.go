@@ -0,0 +1,258 @@
+package translator
+
+import (
+	"bufio"
+	"context"
+	"hack/compiler/source"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Options are the options of Translate and TranslateProgram.
+type Options struct {
+	// Bootstrap starts the program with the code calling Sys.init, see Writer.Bootstrap
+	Bootstrap bool
+	// Shared translates with the shared routines, see Writer.SetSharedRoutines
+	Shared bool
+	// Optimize optimizes the commands of the whole program before translating them, see Optimize
+	Optimize bool
+	// Link checks the calls and jumps of the whole program before translating it, see Link
+	Link bool
+	// Warn is called with each warning of Link, if set
+	Warn func(warning *source.Error)
+	// Optimized is called with what Optimize saved, if set
+	Optimized func(stats OptimizeStats)
+}
+
+// Source is a .vm file to translate. Name is its file name, such as Main.vm, which names its
+// static variables and prefixes its errors.
+type Source struct {
+	Name   string
+	Reader io.Reader
+}
+
+// group runs goroutines sharing a context that is cancelled by the first of them to fail, like
+// golang.org/x/sync/errgroup.
+type group struct {
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+func withContext(ctx context.Context) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{cancel: cancel}, ctx
+}
+
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for the goroutines and returns the first error.
+func (g *group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// Translate translates sources into the assembly of one program written to w. Each source is
+// parsed and translated concurrently with labels scoped to its file, and the results are written
+// in the order of sources, so the output doesn't depend on the scheduling. The invalid lines of
+// every source are returned as a source.ErrorList. Otherwise the first error, at the command it
+// happened at, stops the translation and is returned, w may then hold part of the program.
+func Translate(ctx context.Context, sources []Source, w io.Writer, opts Options) error {
+	files := make([][]VmCommand, len(sources))
+	// parseErrs[i] are the invalid lines of sources[i], which don't stop the others
+	parseErrs := make([]source.ErrorList, len(sources))
+	g, parseCtx := withContext(ctx)
+	for i, s := range sources {
+		g.Go(func() error {
+			commands, errs, err := parseSource(parseCtx, s)
+			files[i], parseErrs[i] = commands, errs
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	var errs source.ErrorList
+	for _, list := range parseErrs {
+		errs = append(errs, list...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if opts.Link {
+		program, err := Link(files)
+		if err != nil {
+			return err
+		}
+		if opts.Warn != nil {
+			for _, warning := range program.Warnings() {
+				opts.Warn(warning)
+			}
+		}
+	}
+	if opts.Optimize {
+		var stats OptimizeStats
+		files, stats = Optimize(files)
+		if opts.Optimized != nil {
+			opts.Optimized(stats)
+		}
+	}
+
+	g, ctx = withContext(ctx)
+	// results[i] receives the assembly of sources[i]
+	results := make([]chan []string, len(sources))
+	for i, source := range sources {
+		results[i] = make(chan []string, 1)
+		g.Go(func() error {
+			asm, err := translateCommands(ctx, source.Name, files[i], opts.Shared)
+			if err != nil {
+				return err
+			}
+			results[i] <- asm
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		bw := bufio.NewWriter(w)
+		write := func(lines []string) error {
+			for _, line := range lines {
+				if _, err := bw.WriteString(line + "\n"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if opts.Bootstrap {
+			writer := NewWriter(BootstrapFileName, 0)
+			writer.SetSharedRoutines(opts.Shared)
+			asm, err := writer.Bootstrap()
+			if err != nil {
+				return err
+			}
+			if err := write(asm); err != nil {
+				return err
+			}
+		}
+		if opts.Shared {
+			if err := write(SharedRoutines()); err != nil {
+				return err
+			}
+		}
+		for _, result := range results {
+			select {
+			case asm := <-result:
+				if err := write(asm); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return bw.Flush()
+	})
+
+	return g.Wait()
+}
+
+// parseSource returns the commands of s and its invalid lines, the error is the one reading s.
+func parseSource(ctx context.Context, s Source) ([]VmCommand, source.ErrorList, error) {
+	commands := make([]VmCommand, 0)
+	var errs source.ErrorList
+	parser := NewFileParser(s.Name, s.Reader)
+	for parser.HasMoreCommands() {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if err := parser.Advance(); err != nil {
+			errs.Add(err.(*source.Error))
+			continue
+		}
+		commands = append(commands, parser.CurrentCommand())
+	}
+	return commands, errs, parser.Err()
+}
+
+// translateCommands returns the assembly of the commands of the file name, every command being
+// preceded by a comment.
+func translateCommands(ctx context.Context, name string, commands []VmCommand, shared bool) ([]string, error) {
+	asm := make([]string, 0)
+	writer := NewWriter(name, 0)
+	writer.SetSharedRoutines(shared)
+	for _, command := range commands {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := writer.Write(command)
+		if err != nil {
+			return nil, command.errorf("%s", err)
+		}
+		if len(res) > 0 {
+			asm = append(asm, "// "+command.String())
+			asm = append(asm, res...)
+		}
+	}
+	return asm, nil
+}
+
+// TranslateProgram translates the .vm files at paths, in order, into the assembly file output,
+// see Translate. The assembly is written with WriteFile, so output is left untouched when the
+// translation fails.
+func TranslateProgram(ctx context.Context, paths []string, output string, opts Options) error {
+	sources := make([]Source, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		sources = append(sources, Source{Name: filepath.Base(path), Reader: f})
+	}
+	return WriteFile(output, func(w io.Writer) error {
+		return Translate(ctx, sources, w, opts)
+	})
+}
+
+// WriteFile writes what write writes to a temporary file renamed to path once complete, so the
+// file at path is left untouched when write fails.
+func WriteFile(path string, write func(w io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	err = write(tmp)
+	if err != nil {
+		return err
+	}
+	err = tmp.Chmod(0644)
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,239 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, 0, len(files))
+	for _, name := range []string{"Main.vm", "Sys.vm", "Util.vm"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return dir, paths
+}
+
+func TestTranslateProgram(t *testing.T) {
+	dir, paths := writeFiles(t, map[string]string{
+		"Main.vm": "function Main.main 0\npush constant 7\nreturn\n",
+		"Sys.vm":  "function Sys.init 0\ncall Main.main 0\nlabel END\ngoto END\n",
+	})
+	output := filepath.Join(dir, "Program.asm")
+	err := TranslateProgram(context.Background(), paths, output, Options{Bootstrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asm, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(asm), "@256\n") {
+		t.Fatalf("expected the bootstrap code first, got %s", asm[:20])
+	}
+	for _, expected := range []string{"// function Main.main 0", "// call Main.main 0", "(Sys.init$END)"} {
+		if !strings.Contains(string(asm), expected) {
+			t.Fatalf("expected %q in the assembly", expected)
+		}
+	}
+
+	// the same program translates to the same assembly
+	var again bytes.Buffer
+	sources := make([]Source, len(paths))
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		sources[i] = Source{Name: filepath.Base(path), Reader: f}
+	}
+	err = Translate(context.Background(), sources, &again, Options{Bootstrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != string(asm) {
+		t.Fatal("expected the same assembly from Translate and TranslateProgram")
+	}
+}
+
+func TestTranslate_FileLabels(t *testing.T) {
+	// a label outside any function doesn't collide with the function Main.LOOP
+	code := "label LOOP\ngoto LOOP\nfunction Main.LOOP 0\npush constant 0\nreturn\n"
+	var asm bytes.Buffer
+	err := Translate(context.Background(), []Source{{Name: "Main.vm", Reader: strings.NewReader(code)}}, &asm, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"(Main.$LOOP)", "(Main.LOOP)"} {
+		if strings.Count(asm.String(), label+"\n") != 1 {
+			t.Fatalf("expected %s once in the assembly", label)
+		}
+	}
+}
+
+func TestTranslateProgram_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			"parse error",
+			map[string]string{
+				"Main.vm": "function Main.main 0\npush constant 1\nreturn\n",
+				"Sys.vm":  "function Sys.init 0\npush segment 1\nreturn\n",
+			},
+			"Sys.vm:2:6: invalid segment segment",
+		},
+		{
+			"errors of every file",
+			map[string]string{
+				"Main.vm": "function Main.main 0\n\npop pointer 2\nreturn\n",
+				"Sys.vm":  "function Sys.init 0\npush\nreturn\n",
+			},
+			"Main.vm:3:13: pointer index 2 is out of the range 0 to 1\npop pointer 2\n            ^\nSys.vm:2:5: expected segment",
+		},
+		{
+			"error in the first of many files",
+			map[string]string{
+				"Main.vm": "function Main.main 0\nfoo\n",
+				"Sys.vm":  strings.Repeat("push constant 1\npop temp 0\n", 10000),
+				"Util.vm": strings.Repeat("push constant 2\npop temp 1\n", 10000),
+			},
+			"Main.vm:2:1: invalid command foo",
+		},
+		{
+			"link error",
+			map[string]string{
+				"Main.vm": "function Main.main 0\ncall Main.foo 0\nreturn\n",
+				"Sys.vm":  "function Sys.init 0\ncall Main.main 0\n",
+			},
+			"Main.vm:2:1: function Main.foo is not defined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, paths := writeFiles(t, tt.files)
+			output := filepath.Join(dir, "Program.asm")
+			err := TranslateProgram(context.Background(), paths, output, Options{Bootstrap: true, Shared: true, Link: true})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Fatalf("expected an error starting with %q, got %q", tt.expected, err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if !strings.HasSuffix(entry.Name(), ".vm") {
+					t.Fatalf("expected no output, got %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateProgram_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "Program.asm")
+	err := TranslateProgram(context.Background(), []string{filepath.Join(dir, "Missing.vm")}, output, Options{})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing file error, got %v", err)
+	}
+	if _, err := os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no output, got %v", err)
+	}
+}
+
+func TestTranslate_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source := Source{Name: "Main.vm", Reader: strings.NewReader(strings.Repeat("push constant 1\n", 1000))}
+	err := Translate(ctx, []Source{source}, &bytes.Buffer{}, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// failingWriter fails every write, like a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTranslate_WriteError(t *testing.T) {
+	source := Source{Name: "Main.vm", Reader: strings.NewReader(strings.Repeat("push constant 1\n", 10000))}
+	err := Translate(context.Background(), []Source{source}, failingWriter{}, Options{})
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
+
+// TestTranslate_Deterministic translates the ch8 programs with one and many threads, the
+// assembly must be the same.
+func TestTranslate_Deterministic(t *testing.T) {
+	for _, dir := range []string{"../../ch8/StaticsTest", "../../ch8/FibonacciElement"} {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.vm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		translate := func(procs int) string {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			sources := make([]Source, len(paths))
+			for i, path := range paths {
+				code, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sources[i] = Source{Name: filepath.Base(path), Reader: bytes.NewReader(code)}
+			}
+			var asm bytes.Buffer
+			err := Translate(context.Background(), sources, &asm, Options{Bootstrap: true, Shared: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return asm.String()
+		}
+		expected := translate(1)
+		for i := 0; i < 10; i++ {
+			if actual := translate(8); actual != expected {
+				t.Fatalf("%s: expected the same assembly with 1 and 8 threads", dir)
+			}
+		}
+	}
+}
+
+func TestTranslate_Optimized(t *testing.T) {
+	code := "function Sys.init 0\ncall Main.main 0\nfunction Main.main 0\npush constant 1\npush constant 2\nadd\nreturn\n" +
+		"function Main.unused 0\nreturn\n"
+	var stats *OptimizeStats
+	opts := Options{Optimize: true, Optimized: func(s OptimizeStats) { stats = &s }}
+	err := Translate(context.Background(), []Source{{Name: "Main.vm", Reader: strings.NewReader(code)}}, &bytes.Buffer{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := OptimizeStats{FunctionsBefore: 3, FunctionsAfter: 2, CommandsBefore: 9, CommandsAfter: 5}
+	if stats == nil || *stats != expected {
+		t.Fatalf("expected %s, got %v", expected, stats)
+	}
+}
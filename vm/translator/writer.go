@@ -6,10 +6,20 @@ import (
 )
 
 type Writer struct {
+	// counter numbers the labels the writer generates, which are prefixed by fileName so that
+	// files can be translated independently
 	counter  int64
 	fileName string
+	// function is the function being translated, which scopes its labels
+	function string
+	// shared makes call, return and the comparisons jump to the routines of SharedRoutines
+	// instead of inlining them
+	shared bool
 }
 
+// BootstrapFileName is the file name of the writer of the bootstrap code, it is no class name.
+const BootstrapFileName = "$Bootstrap.vm"
+
 func NewWriter(fileName string, counter int64) *Writer {
 	processed := strings.Replace(fileName, ".vm", ".", 1)
 	return &Writer{
@@ -21,6 +31,111 @@ func (w *Writer) Counter() int64 {
 	return w.counter
 }
 
+// SetSharedRoutines makes the writer translate call, return, eq, gt and lt to jumps to the
+// routines of SharedRoutines, which must then be part of the program once.
+func (w *Writer) SetSharedRoutines(shared bool) {
+	w.shared = shared
+}
+
+const (
+	callRoutine    = "$CALL"
+	returnRoutine  = "$RETURN"
+	routinesEnd    = "$ROUTINES_END"
+	compareRoutine = "$COMPARE_%s"
+)
+
+// SharedRoutines returns the routines shared by the call sites of a writer with shared routines.
+// The code starts by jumping over them, so it can be put anywhere between two commands.
+func SharedRoutines() []string {
+	res := make([]string, 0)
+	res = append(res, fmt.Sprintf("@%s", routinesEnd))
+	res = append(res, "0;JMP")
+
+	// call, R13 = nArgs, R14 = function address, D = return address
+	res = append(res, fmt.Sprintf("(%s)", callRoutine))
+	// push return address
+	res = append(res, "@SP")
+	res = append(res, "A=M")
+	res = append(res, "M=D")
+	res = append(res, "@SP")
+	res = append(res, "M=M+1")
+	res = append(res, pushSymbolContent("LCL")...)
+	res = append(res, pushSymbolContent("ARG")...)
+	res = append(res, pushSymbolContent("THIS")...)
+	res = append(res, pushSymbolContent("THAT")...)
+	// ARG = SP - 5 - nArgs
+	res = append(res, "@R13")
+	res = append(res, "D=M")
+	res = append(res, "@5")
+	res = append(res, "D=D+A")
+	res = append(res, "@SP")
+	res = append(res, "D=M-D")
+	res = append(res, "@ARG")
+	res = append(res, "M=D")
+	// LCL = SP
+	res = append(res, "@SP")
+	res = append(res, "D=M")
+	res = append(res, "@LCL")
+	res = append(res, "M=D")
+	// goto function
+	res = append(res, "@R14")
+	res = append(res, "A=M")
+	res = append(res, "0;JMP")
+
+	res = append(res, fmt.Sprintf("(%s)", returnRoutine))
+	res = append(res, returnCommand()...)
+
+	res = append(res, compareRoutineCommands("eq", "JEQ")...)
+	res = append(res, compareRoutineCommands("gt", "JLT")...)
+	res = append(res, compareRoutineCommands("lt", "JGT")...)
+
+	res = append(res, fmt.Sprintf("(%s)", routinesEnd))
+	return res
+}
+
+// compareRoutineCommands returns the routine of a comparison, D = return address. jump is the
+// condition on top - second, which is true when the comparison is.
+func compareRoutineCommands(command string, jump string) []string {
+	name := fmt.Sprintf(compareRoutine, command)
+	res := make([]string, 0)
+	res = append(res, fmt.Sprintf("(%s)", name))
+	res = append(res, "@R15")
+	res = append(res, "M=D")
+	res = append(res, "@SP")
+	res = append(res, "AM=M-1")
+	res = append(res, "D=M")
+	res = append(res, "A=A-1")
+	res = append(res, "D=D-M")
+	// false case -> *SP = 0
+	res = append(res, "M=0")
+	res = append(res, fmt.Sprintf("@%s_TRUE", name))
+	res = append(res, fmt.Sprintf("D;%s", jump))
+	res = append(res, "@R15")
+	res = append(res, "A=M")
+	res = append(res, "0;JMP")
+	// true case -> *SP = -1
+	res = append(res, fmt.Sprintf("(%s_TRUE)", name))
+	res = append(res, "@SP")
+	res = append(res, "A=M-1")
+	res = append(res, "M=-1")
+	res = append(res, "@R15")
+	res = append(res, "A=M")
+	res = append(res, "0;JMP")
+	return res
+}
+
+// jumpToRoutine returns the call site of a shared routine, which returns to the next command.
+func (w *Writer) jumpToRoutine(routine string) []string {
+	returnAddress := w.uniqueLabel("returnAddress", w.nextNum())
+	res := make([]string, 0)
+	res = append(res, fmt.Sprintf("@%s", returnAddress))
+	res = append(res, "D=A")
+	res = append(res, fmt.Sprintf("@%s", routine))
+	res = append(res, "0;JMP")
+	res = append(res, fmt.Sprintf("(%s)", returnAddress))
+	return res
+}
+
 func (w *Writer) Write(command VmCommand) ([]string, error) {
 	switch command.commandType {
 	case C_PUSH:
@@ -45,13 +160,42 @@ func (w *Writer) Write(command VmCommand) ([]string, error) {
 		return w.translateReturnCommand()
 	case C_CALL:
 		return w.translateCallCommand(command.Arg1(), command.Arg2())
+	case C_COPY:
+		return w.translateCopyCommand(command.sourceSegment, command.sourceIndex, command.Arg1(), command.Arg2())
+	case C_IF_NOT:
+		return w.translateIfNotCommand(command.Arg1())
 	default:
 		return []string{}, fmt.Errorf("invalid command: %s", command)
 	}
 }
+
+// Bootstrap returns the code starting a program: it sets SP to 256 and calls Sys.init. Sys.init
+// shouldn't return, but when it does the program halts in a loop instead of running the code
+// that follows.
+func (w *Writer) Bootstrap() ([]string, error) {
+	res := make([]string, 0)
+	// SP = 256
+	res = append(res, "@256")
+	res = append(res, "D=A")
+	res = append(res, "@SP")
+	res = append(res, "M=D")
+	// call Sys.init 0, the frame it saves is the one Sys.init returns to
+	call, err := w.translateCallCommand("Sys.init", 0)
+	if err != nil {
+		return res, err
+	}
+	res = append(res, call...)
+	halt := w.fileName + "$HALT"
+	res = append(res, fmt.Sprintf("(%s)", halt))
+	res = append(res, fmt.Sprintf("@%s", halt))
+	res = append(res, "0;JMP")
+	return res, nil
+}
+
 func (w *Writer) translateFunctionCommand(functionName string, locals int64) ([]string, error) {
 	// function SimpleFunction.test 2
 	res := make([]string, 0)
+	w.function = functionName
 	res = append(res, fmt.Sprintf("(%s)", functionName))
 	count := int64(0)
 	for count < locals {
@@ -71,6 +215,12 @@ func (w *Writer) nextNum() int64 {
 	return num
 }
 
+// uniqueLabel returns the label name numbered num of the file, such as Main.$NEXT3. The `$` keeps
+// it from colliding with function names.
+func (w *Writer) uniqueLabel(name string, num int64) string {
+	return fmt.Sprintf("%s$%s%d", w.fileName, name, num)
+}
+
 func pushSymbolContent(symbol string) []string {
 	res := make([]string, 0)
 	res = append(res, fmt.Sprintf("@%s", symbol))
@@ -86,7 +236,19 @@ func pushSymbolContent(symbol string) []string {
 func (w *Writer) translateCallCommand(functionName string, args int64) ([]string, error) {
 	// call
 	res := make([]string, 0)
-	returnAddress := fmt.Sprintf("returnAddress%d", w.nextNum())
+	if w.shared {
+		res = append(res, fmt.Sprintf("@%d", args))
+		res = append(res, "D=A")
+		res = append(res, "@R13")
+		res = append(res, "M=D")
+		res = append(res, fmt.Sprintf("@%s", functionName))
+		res = append(res, "D=A")
+		res = append(res, "@R14")
+		res = append(res, "M=D")
+		res = append(res, w.jumpToRoutine(callRoutine)...)
+		return res, nil
+	}
+	returnAddress := w.uniqueLabel("returnAddress", w.nextNum())
 	// push return Address
 	res = append(res, fmt.Sprintf("@%s", returnAddress))
 	res = append(res, "D=A")
@@ -133,6 +295,13 @@ func (w *Writer) translateCallCommand(functionName string, args int64) ([]string
 }
 
 func (w *Writer) translateReturnCommand() ([]string, error) {
+	if w.shared {
+		return []string{fmt.Sprintf("@%s", returnRoutine), "0;JMP"}, nil
+	}
+	return returnCommand(), nil
+}
+
+func returnCommand() []string {
 	// return
 	res := make([]string, 0)
 	// R13 = endFrame, R14 = retAddress
@@ -192,13 +361,13 @@ func (w *Writer) translateReturnCommand() ([]string, error) {
 	res = append(res, "@R14")
 	res = append(res, "A=M")
 	res = append(res, "0;JMP")
-	return res, nil
+	return res
 }
 
 func (w *Writer) translateGotoCommand(label string) ([]string, error) {
 	// goto END                // otherwise, goto END
 	res := make([]string, 0)
-	l := computeLabelName(label)
+	l := w.labelName(label)
 
 	res = append(res, fmt.Sprintf("@%s", l))
 	res = append(res, "0;JMP")
@@ -206,11 +375,23 @@ func (w *Writer) translateGotoCommand(label string) ([]string, error) {
 	return res, nil
 }
 
+// translateIfNotCommand translates `not` followed by `if-goto label`, jumping when the top of
+// the stack x is such that !x isn't 0, that is when x isn't -1, not only when x is false.
+func (w *Writer) translateIfNotCommand(label string) ([]string, error) {
+	res := make([]string, 0)
+	res = append(res, "@SP")
+	res = append(res, "AM=M-1")
+	res = append(res, "D=M+1")
+	res = append(res, fmt.Sprintf("@%s", w.labelName(label)))
+	res = append(res, "D;JNE")
+	return res, nil
+}
+
 func (w *Writer) translateIfCommand(label string) ([]string, error) {
 	// TODO: Confirm `if n # 0, goto LOOP` is true??? or `if n == 0` ??
 	// if-goto LOOP        // if n # 0, goto LOOP
 	res := make([]string, 0)
-	l := computeLabelName(label)
+	l := w.labelName(label)
 
 	res = append(res, "@SP")
 	res = append(res, "AM=M-1")
@@ -221,13 +402,18 @@ func (w *Writer) translateIfCommand(label string) ([]string, error) {
 	return res, nil
 }
 
-func computeLabelName(label string) string {
-	return fmt.Sprintf("label_%s", label)
+// labelName returns the assembly label of a VM label, scoped to its function as
+// `Main.main$LOOP`, or to its file outside of functions.
+func (w *Writer) labelName(label string) string {
+	if w.function == "" {
+		return w.fileName + "$" + label
+	}
+	return w.function + "$" + label
 }
 
 func (w *Writer) translateLabelCommand(label string) ([]string, error) {
 	res := make([]string, 0)
-	l := computeLabelName(label)
+	l := w.labelName(label)
 	res = append(res, fmt.Sprintf("(%s)", l))
 
 	return res, nil
@@ -235,6 +421,9 @@ func (w *Writer) translateLabelCommand(label string) ([]string, error) {
 
 func (w *Writer) translateArithmeticCommand(command string) ([]string, error) {
 	res := make([]string, 0)
+	if w.shared && (command == "eq" || command == "gt" || command == "lt") {
+		return w.jumpToRoutine(fmt.Sprintf(compareRoutine, command)), nil
+	}
 	switch command {
 	case "add":
 		// SP--
@@ -265,87 +454,84 @@ func (w *Writer) translateArithmeticCommand(command string) ([]string, error) {
 		res = append(res, "M=-M")
 	case "eq":
 		// if top0 == top1 -> -1 else 0
-		num := w.counter
-		w.counter += 1
+		num := w.nextNum()
 
 		res = append(res, "@SP")
 		res = append(res, "AM=M-1")
 		res = append(res, "D=M")
 		res = append(res, "A=A-1")
 		res = append(res, "D=D-M")
-		res = append(res, fmt.Sprintf("@BRANCH%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "D;JEQ")
 
 		// false case -> *SP = 0
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=0")
-		res = append(res, fmt.Sprintf("@NEXT%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("NEXT", num)))
 		res = append(res, "0;JMP")
 
 		// true case -> *SP = -1
-		res = append(res, fmt.Sprintf("(BRANCH%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=-1")
 
-		res = append(res, fmt.Sprintf("(NEXT%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("NEXT", num)))
 	case "gt":
 		// looks like VM also don't handle overflow safely, let's consider overflow later!
 		// if top0 > top1 -> -1 else 0
 
-		num := w.counter
-		w.counter += 1
+		num := w.nextNum()
 
 		res = append(res, "@SP")
 		res = append(res, "AM=M-1")
 		res = append(res, "D=M")
 		res = append(res, "A=A-1")
 		res = append(res, "D=D-M")
-		res = append(res, fmt.Sprintf("@BRANCH%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "D;JLT")
 
 		// false case -> *SP = 0
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=0")
-		res = append(res, fmt.Sprintf("@NEXT%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("NEXT", num)))
 		res = append(res, "0;JMP")
 
 		// true case -> *SP = -1
-		res = append(res, fmt.Sprintf("(BRANCH%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=-1")
 
-		res = append(res, fmt.Sprintf("(NEXT%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("NEXT", num)))
 
 	case "lt":
-		num := w.counter
-		w.counter += 1
+		num := w.nextNum()
 
 		res = append(res, "@SP")
 		res = append(res, "AM=M-1")
 		res = append(res, "D=M")
 		res = append(res, "A=A-1")
 		res = append(res, "D=D-M")
-		res = append(res, fmt.Sprintf("@BRANCH%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "D;JGT")
 
 		// false case -> *SP = 0
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=0")
-		res = append(res, fmt.Sprintf("@NEXT%d", num))
+		res = append(res, fmt.Sprintf("@%s", w.uniqueLabel("NEXT", num)))
 		res = append(res, "0;JMP")
 
 		// true case -> *SP = -1
-		res = append(res, fmt.Sprintf("(BRANCH%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("BRANCH", num)))
 		res = append(res, "@SP")
 		res = append(res, "A=M-1")
 		res = append(res, "M=-1")
 
-		res = append(res, fmt.Sprintf("(NEXT%d)", num))
+		res = append(res, fmt.Sprintf("(%s)", w.uniqueLabel("NEXT", num)))
 
 	case "and":
 		res = append(res, "@SP")
@@ -396,8 +582,45 @@ func buildPopWithSegmentPointer(label string, arg2 int64) []string {
 const TempOffset = int64(5)
 
 func (w *Writer) translatePopCommand(arg1 string, arg2 int64) ([]string, error) {
+	res, err := w.segmentAddress(arg1, arg2)
+	if err != nil {
+		return res, err
+	}
+	// sp--
+	res = append(res, "@SP")
+	res = append(res, "M=M-1")
+	// *addr = *sp
+	res = append(res, "@SP")
+	res = append(res, "A=M")
+	res = append(res, "D=M")
+	res = append(res, "@R13")
+	res = append(res, "A=M")
+	res = append(res, "M=D")
+	return res, nil
+}
+
+// translateCopyCommand translates `push source sourceIndex` followed by `pop arg1 arg2`, without
+// going through the stack.
+func (w *Writer) translateCopyCommand(source string, sourceIndex int64, arg1 string, arg2 int64) ([]string, error) {
+	res, err := w.segmentAddress(arg1, arg2)
+	if err != nil {
+		return res, err
+	}
+	load, err := w.segmentValue(source, sourceIndex)
+	if err != nil {
+		return res, err
+	}
+	res = append(res, load...)
+	res = append(res, "@R13")
+	res = append(res, "A=M")
+	res = append(res, "M=D")
+	return res, nil
+}
+
+// segmentAddress returns the code setting R13 to the address of the segment arg1 at arg2.
+func (w *Writer) segmentAddress(arg1 string, arg2 int64) ([]string, error) {
 	res := make([]string, 0)
-	err := checkSegment(arg1)
+	err := checkSegment(arg1, arg2)
 	// keep addr at R13
 	if err != nil {
 		return res, err
@@ -447,23 +670,29 @@ func (w *Writer) translatePopCommand(arg1 string, arg2 int64) ([]string, error)
 		res = append(res, "@R13")
 		res = append(res, "M=D")
 	}
+	return res, nil
+}
 
-	// sp--
-	res = append(res, "@SP")
-	res = append(res, "M=M-1")
-	// *addr = *sp
+func (w *Writer) translatePushCommand(arg1 string, arg2 int64) ([]string, error) {
+	res, err := w.segmentValue(arg1, arg2)
+	if err != nil {
+		return res, err
+	}
+	// *sp = whatever
 	res = append(res, "@SP")
 	res = append(res, "A=M")
-	res = append(res, "D=M")
-	res = append(res, "@R13")
-	res = append(res, "A=M")
 	res = append(res, "M=D")
+	// sp++
+	res = append(res, "@SP")
+	res = append(res, "M=M+1")
+
 	return res, nil
 }
 
-func (w *Writer) translatePushCommand(arg1 string, arg2 int64) ([]string, error) {
+// segmentValue returns the code setting D to the value of the segment arg1 at arg2.
+func (w *Writer) segmentValue(arg1 string, arg2 int64) ([]string, error) {
 	res := make([]string, 0)
-	err := checkSegment(arg1)
+	err := checkSegment(arg1, arg2)
 	if err != nil {
 		return res, err
 	}
@@ -509,14 +738,5 @@ func (w *Writer) translatePushCommand(arg1 string, arg2 int64) ([]string, error)
 		res = append(res, fmt.Sprintf("@%s", label))
 		res = append(res, "D=M")
 	}
-
-	// *sp = whatever
-	res = append(res, "@SP")
-	res = append(res, "A=M")
-	res = append(res, "M=D")
-	// sp++
-	res = append(res, "@SP")
-	res = append(res, "M=M+1")
-
 	return res, nil
 }
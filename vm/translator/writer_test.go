@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"hack/assembler"
+	"hack/compiler"
+	"hack/cpu"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compileJack compiles the standard library and the .jack files of dir to VM code by file name.
+func compileJack(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	osPaths, err := filepath.Glob("../../os/*.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[string][]byte)
+	for _, p := range append(osPaths, paths...) {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		class, err := compiler.NewFileEngine(p, f).CompileClass()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err = compiler.NewVmWriter(&buf, class).Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[strings.TrimSuffix(filepath.Base(p), ".jack")+".vm"] = buf.Bytes()
+	}
+	return res
+}
+
+// translateVM translates the VM files and returns the commands of the assembly and how many
+// instructions they make.
+func translateVM(t *testing.T, files map[string][]byte, opts Options) ([]assembler.Command, int) {
+	t.Helper()
+	sources := make([]Source, 0, len(files))
+	for name, code := range files {
+		sources = append(sources, Source{Name: name, Reader: bytes.NewReader(code)})
+	}
+	var asm bytes.Buffer
+	err := Translate(context.Background(), sources, &asm, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := assembler.Parse(strings.Split(strings.TrimSuffix(asm.String(), "\n"), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, command := range commands {
+		if command.CommandType != assembler.LabelDeclarationCommandType {
+			count++
+		}
+	}
+	return commands, count
+}
+
+// TestTranslate_SharedRoutines translates ch11's ConvertToBin with the standard library, which
+// only fits in the ROM with the shared routines, and runs it on the CPU.
+func TestTranslate_SharedRoutines(t *testing.T) {
+	files := compileJack(t, "../../ch11/ConvertToBin")
+	_, inlined := translateVM(t, files, Options{Bootstrap: true})
+	commands, shared := translateVM(t, files, Options{Bootstrap: true, Shared: true})
+	if shared >= inlined {
+		t.Fatalf("expected fewer than %d instructions with the shared routines, got %d", inlined, shared)
+	}
+	if inlined <= cpu.ROMSize || shared > cpu.ROMSize {
+		t.Fatalf("expected only the shared program to fit in the ROM, got %d and %d instructions", inlined, shared)
+	}
+
+	instructions, err := assembler.Translate(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cpu.New()
+	err = c.LoadInstructions(instructions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := int16(0b0110_0000_1100_0101)
+	_ = c.Poke(8000, value)
+	_, err = c.Run(2000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		bit, _ := c.Peek(8001 + i)
+		if expected := int16(uint16(value) >> i & 1); bit != expected {
+			t.Fatalf("expected bit %d of %016b at RAM[%d], got %d", i, uint16(value), 8001+i, bit)
+		}
+	}
+}